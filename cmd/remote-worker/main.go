@@ -0,0 +1,241 @@
+// remote-worker - эталонный удаленный воркер, работающий по lease протоколу:
+// POST /lease, POST /tasks/{id}/heartbeat, POST /tasks/{id}/complete|fail.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type leasedTask struct {
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	Queue          string          `json:"queue"`
+	Payload        json.RawMessage `json:"payload"`
+	ContentType    string          `json:"content_type"`
+	Attempt        int             `json:"attempt"`
+	LeaseExpiresAt time.Time       `json:"lease_expires_at"`
+}
+
+type client struct {
+	baseURL  string
+	apiKey   string
+	workerID string
+	http     *http.Client
+}
+
+func (c *client) post(ctx context.Context, path string, body interface{}, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var msg bytes.Buffer
+		msg.ReadFrom(resp.Body)
+		return fmt.Errorf("%s: %d %s", path, resp.StatusCode, strings.TrimSpace(msg.String()))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func (c *client) lease(ctx context.Context, types, queues []string, max int, timeout time.Duration) ([]leasedTask, error) {
+	var resp struct {
+		Tasks []leasedTask `json:"tasks"`
+	}
+	err := c.post(ctx, "/lease", map[string]interface{}{
+		"worker_id":          c.workerID,
+		"types":              types,
+		"queues":             queues,
+		"max":                max,
+		"visibility_timeout": timeout.String(),
+	}, &resp)
+	return resp.Tasks, err
+}
+
+func (c *client) heartbeat(ctx context.Context, id string, timeout time.Duration) error {
+	return c.post(ctx, "/tasks/"+url.PathEscape(id)+"/heartbeat", map[string]string{
+		"worker_id":          c.workerID,
+		"visibility_timeout": timeout.String(),
+	}, nil)
+}
+
+func (c *client) finish(ctx context.Context, id string, taskErr error) error {
+	if taskErr != nil {
+		return c.post(ctx, "/tasks/"+url.PathEscape(id)+"/fail", map[string]string{
+			"worker_id": c.workerID,
+			"error":     taskErr.Error(),
+		}, nil)
+	}
+	return c.post(ctx, "/tasks/"+url.PathEscape(id)+"/complete", map[string]string{"worker_id": c.workerID}, nil)
+}
+
+// process - место для реальной логики. Здесь имитируется работа.
+func process(ctx context.Context, task leasedTask) error {
+	select {
+	case <-time.After(time.Duration(100+rand.Intn(400)) * time.Millisecond):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func envOr(key, defaultValue string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+func main() {
+	hostname, _ := os.Hostname()
+
+	server := flag.String("server", envOr("SERVER_URL", "http://127.0.0.1:9000"), "queue server URL")
+	apiKey := flag.String("api-key", os.Getenv("API_KEY"), "API key with worker scope")
+	workerID := flag.String("id", envOr("WORKER_ID", fmt.Sprintf("%s-%d", hostname, os.Getpid())), "worker id")
+	types := flag.String("types", envOr("TASK_TYPES", ""), "comma separated task types")
+	queues := flag.String("queues", envOr("QUEUES", ""), "comma separated queues, empty for all")
+	concurrency := flag.Int("concurrency", 4, "tasks processed in parallel")
+	timeout := flag.Duration("visibility-timeout", 30*time.Second, "lease visibility timeout")
+	poll := flag.Duration("poll-interval", time.Second, "delay between empty leases")
+	flag.Parse()
+
+	if *types == "" {
+		fmt.Fprintln(os.Stderr, "at least one task type is required (-types)")
+		os.Exit(2)
+	}
+	// Аренда продлевается раз в треть таймаута
+	if *timeout < time.Second {
+		fmt.Fprintln(os.Stderr, "visibility timeout must be at least 1s (-visibility-timeout)")
+		os.Exit(2)
+	}
+
+	c := &client{
+		baseURL:  strings.TrimRight(*server, "/"),
+		apiKey:   *apiKey,
+		workerID: *workerID,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+	logger := slog.With("worker_id", *workerID)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	slots := make(chan struct{}, *concurrency)
+	var wg sync.WaitGroup
+
+	logger.Info("Remote worker started", "server", c.baseURL, "types", *types)
+	for ctx.Err() == nil {
+		free := *concurrency - len(slots)
+		if free == 0 {
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+
+		tasks, err := c.lease(ctx, splitList(*types), splitList(*queues), free, *timeout)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("Lease failed", "error", err)
+		}
+		if len(tasks) == 0 {
+			select {
+			case <-time.After(*poll):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		for _, task := range tasks {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(task leasedTask) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				run(c, task, *timeout, logger)
+			}(task)
+		}
+	}
+
+	// Дорабатываем уже арендованные задачи
+	wg.Wait()
+	logger.Info("Remote worker stopped")
+}
+
+func run(c *client, task leasedTask, timeout time.Duration, logger *slog.Logger) {
+	logger = logger.With("task_id", task.ID, "type", task.Type, "queue", task.Queue, "attempt", task.Attempt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Продлеваем аренду, пока задача выполняется
+	go func() {
+		ticker := time.NewTicker(timeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.heartbeat(ctx, task.ID, timeout); err != nil && ctx.Err() == nil {
+					logger.Warn("Heartbeat failed, abandoning task", "error", err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	taskErr := process(ctx, task)
+	if ctx.Err() != nil {
+		return
+	}
+	cancel()
+
+	if err := c.finish(context.Background(), task.ID, taskErr); err != nil {
+		logger.Error("Failed to report result", "error", err)
+		return
+	}
+	if taskErr != nil {
+		logger.Warn("Task failed", "error", taskErr)
+	} else {
+		logger.Info("Task completed")
+	}
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// runCommand выполняет подкоманду CLI против работающего сервера:
+//
+//	taskqueue export [-status failed] [-o tasks.jsonl]
+//	taskqueue import [-mode skip|overwrite|fail] tasks.jsonl
+func runCommand(args []string) int {
+	var err error
+	switch args[0] {
+	case "export":
+		err = exportCommand(args[1:])
+	case "import":
+		err = importCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected export or import\n", args[0])
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+type commandClient struct {
+	server string
+	apiKey string
+}
+
+func (c *commandClient) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.server, "server", envOr("SERVER_URL", "http://127.0.0.1:9000"), "queue server URL")
+	fs.StringVar(&c.apiKey, "api-key", os.Getenv("API_KEY"), "API key with admin scope")
+}
+
+func (c *commandClient) do(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(c.server, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var msg bytes.Buffer
+		msg.ReadFrom(resp.Body)
+		return nil, fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(msg.String()))
+	}
+	return resp, nil
+}
+
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var client commandClient
+	client.register(fs)
+	output := fs.String("o", "-", "output file, - for stdout")
+	filters := make(map[string]*string)
+	for _, name := range []string{"status", "queue", "type", "tenant", "q"} {
+		filters[name] = fs.String(name, "", "filter by "+name)
+	}
+	fs.Parse(args)
+
+	query := url.Values{}
+	for name, value := range filters {
+		if *value != "" {
+			query.Set(name, *value)
+		}
+	}
+
+	resp, err := client.do(http.MethodGet, "/admin/export?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	out := io.Writer(os.Stdout)
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+func importCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var client commandClient
+	client.register(fs)
+	mode := fs.String("mode", "skip", "conflict mode for existing ids: skip, overwrite, fail")
+	fs.Parse(args)
+
+	in := io.Reader(os.Stdin)
+	if path := fs.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	resp, err := client.do(http.MethodPost, "/admin/import?mode="+url.QueryEscape(*mode), in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
+func envOr(key, defaultValue string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
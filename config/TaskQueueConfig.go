@@ -2,14 +2,127 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"time"
 )
 
 type Config struct {
 	Workers   int
 	QueueSize int
 	Port      string
+	// Адрес HTTP сервера
+	ListenAddr string
+
+	RetryPolicy        string
+	QueueRetryPolicies string
+
+	// Лимиты арендаторов: "weight=1,concurrency=0,..." и "tenant:spec;..."
+	TenantDefaultLimits string
+	TenantLimits        string
+
+	// Типы задач, которые выполняют удаленные воркеры
+	RemoteTaskTypes string
+
+	VisibilityTimeout time.Duration
+	ReaperInterval    time.Duration
+
+	// Паник и аварий воркеров до карантина задачи, 0 - без карантина
+	PoisonThreshold int
+
+	// Circuit breaker по умолчанию ("rate=0.5,min=10,...", пусто - выключен)
+	// и для отдельных ключей ("key:spec;...")
+	CircuitBreaker  string
+	CircuitBreakers string
+
+	// Поведение при заполненной очереди: reject, block, throttle, spill
+	OverflowMode   string
+	EnqueueMaxWait time.Duration
+	SpillDir       string
+	SpillMaxBytes  int
+
+	RetentionDoneTTL      time.Duration
+	RetentionFailedTTL    time.Duration
+	RetentionCancelledTTL time.Duration
+	RetentionMaxTasks     int
+	RetentionArchiveDir   string
+	RetentionInterval     time.Duration
+
+	LogFormat string
+	LogLevel  string
+
+	// Логи попыток в памяти: строк на попытку и число задач
+	TaskLogLines int
+	TaskLogTasks int
+
+	TracingExporter string
+	TracingFile     string
+
+	AuthMode    string
+	APIKeys     string
+	APIKeysFile string
+
+	JWKSURL        string
+	JWKSFile       string
+	JWKSRefresh    time.Duration
+	JWTIssuer      string
+	JWTAudience    string
+	JWTTenantClaim string
+	JWTScopeClaim  string
+
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSClientCAFile     string
+	TLSClientAuth       string
+	TLSClientIdentities string
+	TLSClientScopes     string
+	TLSReloadInterval   time.Duration
+
+	// Кластерный режим включается CLUSTER_NODE_ID. Peers: "id=http://host:port,..."
+	// (все узлы, включая этот); Forward: proxy или redirect
+	ClusterNodeID          string
+	ClusterPeers           string
+	ClusterDataDir         string
+	ClusterSecret          string
+	ClusterForward         string
+	ClusterElectionTimeout time.Duration
+	// Примененных записей лога до снапшота и усечения
+	ClusterSnapshotEntries int
+
+	// Шардирование включается SHARD_NODE_ID. Состав статический (SHARD_PEERS
+	// "id=http://host:port,...") или gossip (SHARD_SEEDS и SHARD_ADVERTISE_URL)
+	ShardNodeID            string
+	ShardPeers             string
+	ShardSeeds             string
+	ShardAdvertiseURL      string
+	ShardSecret            string
+	ShardVirtualNodes      int
+	ShardGossipInterval    time.Duration
+	ShardRebalanceInterval time.Duration
+
+	// Слушатель протокола Redis (пусто - выключен). Списки - очереди задач
+	// типа RespTaskType, который автоматически становится удаленным
+	RespAddr       string
+	RespTaskType   string
+	RespMaxRetries int
+
+	// JSON с шаблонами команд для задач exec (пусто - тип exec не обрабатывается)
+	ExecCommandsFile string
+
+	// Хосты для задач http через запятую (пусто - тип http не обрабатывается)
+	HTTPTaskAllowedHosts    string
+	HTTPTaskTimeout         time.Duration
+	HTTPTaskMaxResponseSize int
+	HTTPTaskMaxRetryAfter   time.Duration
+
+	// Каталог JSON Schema payload <task_type>.json
+	SchemasDir string
+
+	// Каталог WebAssembly модулей <task_type>.wasm (пусто - плагины выключены)
+	PluginsDir           string
+	PluginMemoryLimit    int
+	PluginTimeout        time.Duration
+	PluginReloadInterval time.Duration
 }
 
 func LoadConfig() Config {
@@ -21,6 +134,100 @@ func LoadConfig() Config {
 		Workers:   workers,
 		QueueSize: queueSize,
 		Port:      port,
+
+		ListenAddr: getEnvString("LISTEN_ADDR", "127.0.0.1:9000"),
+
+		RetryPolicy:        getEnvString("RETRY_POLICY", "exponential:1s:5m"),
+		QueueRetryPolicies: getEnvString("QUEUE_RETRY_POLICIES", ""),
+
+		TenantDefaultLimits: getEnvString("TENANT_DEFAULT_LIMITS", "weight=1"),
+		TenantLimits:        getEnvString("TENANT_LIMITS", ""),
+
+		RemoteTaskTypes: getEnvString("REMOTE_TASK_TYPES", ""),
+
+		VisibilityTimeout: getEnvDuration("VISIBILITY_TIMEOUT", 10*time.Minute),
+		ReaperInterval:    getEnvDuration("REAPER_INTERVAL", time.Second),
+
+		PoisonThreshold: getEnvInt("POISON_THRESHOLD", 3),
+
+		CircuitBreaker:  getEnvString("CIRCUIT_BREAKER", ""),
+		CircuitBreakers: getEnvString("CIRCUIT_BREAKERS", ""),
+
+		OverflowMode:   getEnvString("OVERFLOW_MODE", "reject"),
+		EnqueueMaxWait: getEnvDuration("ENQUEUE_MAX_WAIT", 30*time.Second),
+		SpillDir:       getEnvString("SPILL_DIR", "spill"),
+		SpillMaxBytes:  getEnvInt("SPILL_MAX_BYTES", 1<<30),
+
+		RetentionDoneTTL:      getEnvDuration("RETENTION_DONE_TTL", 24*time.Hour),
+		RetentionFailedTTL:    getEnvDuration("RETENTION_FAILED_TTL", 7*24*time.Hour),
+		RetentionCancelledTTL: getEnvDuration("RETENTION_CANCELLED_TTL", 7*24*time.Hour),
+		RetentionMaxTasks:     getEnvInt("RETENTION_MAX_TASKS", 0),
+		RetentionArchiveDir:   getEnvString("RETENTION_ARCHIVE_DIR", ""),
+		RetentionInterval:     getEnvDuration("RETENTION_INTERVAL", time.Minute),
+
+		LogFormat: getEnvString("LOG_FORMAT", "text"),
+		LogLevel:  getEnvString("LOG_LEVEL", "info"),
+
+		TaskLogLines: getEnvInt("TASK_LOG_LINES", 1000),
+		TaskLogTasks: getEnvInt("TASK_LOG_TASKS", 10000),
+
+		TracingExporter: getEnvString("TRACING_EXPORTER", "none"),
+		TracingFile:     getEnvString("TRACING_FILE", "traces.jsonl"),
+
+		AuthMode:    getEnvString("AUTH_MODE", "apikey"),
+		APIKeys:     getEnvString("API_KEYS", ""),
+		APIKeysFile: getEnvString("API_KEYS_FILE", ""),
+
+		JWKSURL:        getEnvString("JWKS_URL", ""),
+		JWKSFile:       getEnvString("JWKS_FILE", ""),
+		JWKSRefresh:    getEnvDuration("JWKS_REFRESH", 10*time.Minute),
+		JWTIssuer:      getEnvString("JWT_ISSUER", ""),
+		JWTAudience:    getEnvString("JWT_AUDIENCE", ""),
+		JWTTenantClaim: getEnvString("JWT_TENANT_CLAIM", "tenant"),
+		JWTScopeClaim:  getEnvString("JWT_SCOPE_CLAIM", "scope"),
+
+		TLSCertFile:         getEnvString("TLS_CERT_FILE", ""),
+		TLSKeyFile:          getEnvString("TLS_KEY_FILE", ""),
+		TLSClientCAFile:     getEnvString("TLS_CLIENT_CA_FILE", ""),
+		TLSClientAuth:       getEnvString("TLS_CLIENT_AUTH", "none"),
+		TLSClientIdentities: getEnvString("TLS_CLIENT_IDENTITIES", ""),
+		TLSClientScopes:     getEnvString("TLS_CLIENT_SCOPES", "enqueue,read"),
+		TLSReloadInterval:   getEnvDuration("TLS_RELOAD_INTERVAL", time.Minute),
+
+		ClusterNodeID:          getEnvString("CLUSTER_NODE_ID", ""),
+		ClusterPeers:           getEnvString("CLUSTER_PEERS", ""),
+		ClusterDataDir:         getEnvString("CLUSTER_DATA_DIR", ""),
+		ClusterSecret:          getEnvString("CLUSTER_SECRET", ""),
+		ClusterForward:         getEnvString("CLUSTER_FORWARD", "proxy"),
+		ClusterElectionTimeout: getEnvDuration("CLUSTER_ELECTION_TIMEOUT", time.Second),
+		ClusterSnapshotEntries: getEnvInt("CLUSTER_SNAPSHOT_ENTRIES", 4096),
+
+		ShardNodeID:            getEnvString("SHARD_NODE_ID", ""),
+		ShardPeers:             getEnvString("SHARD_PEERS", ""),
+		ShardSeeds:             getEnvString("SHARD_SEEDS", ""),
+		ShardAdvertiseURL:      getEnvString("SHARD_ADVERTISE_URL", ""),
+		ShardSecret:            getEnvString("SHARD_SECRET", ""),
+		ShardVirtualNodes:      getEnvInt("SHARD_VNODES", 64),
+		ShardGossipInterval:    getEnvDuration("SHARD_GOSSIP_INTERVAL", time.Second),
+		ShardRebalanceInterval: getEnvDuration("SHARD_REBALANCE_INTERVAL", 30*time.Second),
+
+		RespAddr:       getEnvString("RESP_ADDR", ""),
+		RespTaskType:   getEnvString("RESP_TASK_TYPE", "redis"),
+		RespMaxRetries: getEnvInt("RESP_MAX_RETRIES", 3),
+
+		ExecCommandsFile: getEnvString("EXEC_COMMANDS_FILE", ""),
+
+		HTTPTaskAllowedHosts:    getEnvString("HTTP_TASK_ALLOWED_HOSTS", ""),
+		HTTPTaskTimeout:         getEnvDuration("HTTP_TASK_TIMEOUT", 30*time.Second),
+		HTTPTaskMaxResponseSize: getEnvInt("HTTP_TASK_MAX_RESPONSE_BYTES", 1<<20),
+		HTTPTaskMaxRetryAfter:   getEnvDuration("HTTP_TASK_MAX_RETRY_AFTER", time.Hour),
+
+		SchemasDir: getEnvString("SCHEMAS_DIR", ""),
+
+		PluginsDir:           getEnvString("PLUGINS_DIR", ""),
+		PluginMemoryLimit:    getEnvInt("PLUGIN_MEMORY_LIMIT_BYTES", 64<<20),
+		PluginTimeout:        getEnvDuration("PLUGIN_TIMEOUT", 30*time.Second),
+		PluginReloadInterval: getEnvDuration("PLUGIN_RELOAD_INTERVAL", 5*time.Second),
 	}
 }
 
@@ -28,7 +235,7 @@ func getEnvInt(key string, defaultValue int) int {
 	if env := os.Getenv(key); env != "" {
 		var value int
 		if n, err := fmt.Sscanf(env, "%d", &value); err == nil && n != 1 {
-			log.Printf("Invalid %s value, using default: %d", key, defaultValue)
+			slog.Warn("Invalid config value, using default", "key", key, "default", defaultValue)
 		} else if err == nil {
 			return value
 		}
@@ -42,3 +249,14 @@ func getEnvString(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if env := os.Getenv(key); env != "" {
+		value, err := time.ParseDuration(env)
+		if err == nil {
+			return value
+		}
+		slog.Warn("Invalid config value, using default", "key", key, "default", defaultValue)
+	}
+	return defaultValue
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	ErrMissingCredentials = errors.New("missing bearer token")
+	ErrInvalidCredentials = errors.New("invalid api key")
+)
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type APIKey struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+	Queues []string `json:"queues"`
+	Tenant string   `json:"tenant"`
+}
+
+type APIKeyStore struct {
+	// Ключи храним только в виде хэшей
+	keys map[[sha256.Size]byte]*Principal
+}
+
+func NewAPIKeyStore(keys []APIKey) (*APIKeyStore, error) {
+	store := &APIKeyStore{keys: make(map[[sha256.Size]byte]*Principal)}
+	for i, k := range keys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("api key #%d: empty key", i+1)
+		}
+		name := k.Name
+		if name == "" {
+			name = fmt.Sprintf("key#%d", i+1)
+		}
+
+		principal := &Principal{Name: name, Tenant: k.Tenant, Queues: k.Queues}
+		for _, s := range k.Scopes {
+			scope, ok := ParseScope(s)
+			if !ok {
+				return nil, fmt.Errorf("api key %s: unknown scope %q", name, s)
+			}
+			principal.Scopes = append(principal.Scopes, scope)
+		}
+
+		hash := sha256.Sum256([]byte(k.Key))
+		if _, exists := store.keys[hash]; exists {
+			return nil, fmt.Errorf("api key %s: duplicate key", name)
+		}
+		store.keys[hash] = principal
+	}
+	return store, nil
+}
+
+// LoadAPIKeys собирает ключи из переменной окружения и файла.
+// Формат переменной: "key:scope1,scope2[:queue1,queue2[:tenant]];key2:...".
+// Файл - JSON массив объектов APIKey.
+func LoadAPIKeys(spec, file string) (*APIKeyStore, error) {
+	keys, err := parseAPIKeySpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read api keys file: %v", err)
+		}
+		var fileKeys []APIKey
+		if err := json.Unmarshal(data, &fileKeys); err != nil {
+			return nil, fmt.Errorf("parse api keys file: %v", err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	return NewAPIKeyStore(keys)
+}
+
+func parseAPIKeySpec(spec string) ([]APIKey, error) {
+	var keys []APIKey
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 4 {
+			return nil, fmt.Errorf("invalid api key entry #%d", len(keys)+1)
+		}
+		key := APIKey{Key: parts[0], Scopes: splitList(parts[1])}
+		if len(parts) >= 3 {
+			key.Queues = splitList(parts[2])
+		}
+		if len(parts) == 4 {
+			key.Tenant = strings.TrimSpace(parts[3])
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (s *APIKeyStore) Len() int {
+	return len(s.keys)
+}
+
+func (s *APIKeyStore) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := BearerToken(r)
+	if !ok {
+		return nil, ErrMissingCredentials
+	}
+	principal, exists := s.keys[sha256.Sum256([]byte(token))]
+	if !exists {
+		return nil, ErrInvalidCredentials
+	}
+	return principal, nil
+}
+
+func BearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
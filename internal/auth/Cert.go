@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+type CertIdentity struct {
+	// Совпадение по Common Name или полному subject ("CN=worker-1,O=billing")
+	Subject string   `json:"subject"`
+	Name    string   `json:"name"`
+	Tenant  string   `json:"tenant"`
+	Scopes  []string `json:"scopes"`
+	Queues  []string `json:"queues"`
+}
+
+// CertAuthenticator сопоставляет проверенный клиентский сертификат с Principal.
+// Сертификаты без записи в identities получают имя из CN, tenant из O
+// и scopes по умолчанию.
+type CertAuthenticator struct {
+	identities    map[string]*Principal
+	defaultScopes []Scope
+}
+
+func NewCertAuthenticator(identities []CertIdentity, defaultScopes []string) (*CertAuthenticator, error) {
+	a := &CertAuthenticator{identities: make(map[string]*Principal)}
+
+	for _, s := range defaultScopes {
+		scope, ok := ParseScope(s)
+		if !ok {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+		a.defaultScopes = append(a.defaultScopes, scope)
+	}
+
+	for _, id := range identities {
+		if id.Subject == "" {
+			return nil, fmt.Errorf("client identity without subject")
+		}
+		principal := &Principal{Name: id.Name, Tenant: id.Tenant, Queues: id.Queues}
+		if principal.Name == "" {
+			principal.Name = id.Subject
+		}
+		for _, s := range id.Scopes {
+			scope, ok := ParseScope(s)
+			if !ok {
+				return nil, fmt.Errorf("client identity %s: unknown scope %q", id.Subject, s)
+			}
+			principal.Scopes = append(principal.Scopes, scope)
+		}
+		a.identities[id.Subject] = principal
+	}
+	return a, nil
+}
+
+func LoadCertAuthenticator(file string, defaultScopes []string) (*CertAuthenticator, error) {
+	var identities []CertIdentity
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read client identities: %v", err)
+		}
+		if err := json.Unmarshal(data, &identities); err != nil {
+			return nil, fmt.Errorf("parse client identities: %v", err)
+		}
+	}
+	return NewCertAuthenticator(identities, defaultScopes)
+}
+
+func (a *CertAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	// VerifiedChains заполняется только если сертификат проверен по CA
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrMissingCredentials
+	}
+	return a.principalFor(r.TLS.VerifiedChains[0][0]), nil
+}
+
+func (a *CertAuthenticator) principalFor(cert *x509.Certificate) *Principal {
+	if p, exists := a.identities[cert.Subject.String()]; exists {
+		return p
+	}
+	if p, exists := a.identities[cert.Subject.CommonName]; exists {
+		return p
+	}
+
+	principal := &Principal{Name: cert.Subject.CommonName, Scopes: a.defaultScopes}
+	if len(cert.Subject.Organization) > 0 {
+		principal.Tenant = cert.Subject.Organization[0]
+	}
+	return principal
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var publicPaths = map[string]bool{
+	"/healthz": true,
+	"/ui":      true,
+	// Обмен между узлами шардов, защищен общим секретом
+	"/shard/gossip":  true,
+	"/shard/handoff": true,
+}
+
+// Статика дашборда (данные он получает через API с ключом пользователя)
+// и RPC узлов кластера, защищенные общим секретом
+var publicPrefixes = []string{"/ui/", "/raft/"}
+
+func Middleware(authenticator Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clean := path.Clean(r.URL.Path)
+		if publicPaths[clean] || hasPublicPrefix(clean) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			Audit(r, "", err.Error())
+			w.Header().Set("WWW-Authenticate", `Bearer realm="taskqueue"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func hasPublicPrefix(p string) bool {
+	for _, prefix := range publicPrefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type chain []Authenticator
+
+// Chain пробует аутентификаторы по порядку. Следующий вызывается,
+// только если у запроса нет учетных данных для предыдущего.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+func (c chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		principal, err := a.Authenticate(r)
+		if errors.Is(err, ErrMissingCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrMissingCredentials
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"TaskQueue/internal/logging"
+)
+
+type Scope string
+
+const (
+	ScopeEnqueue Scope = "enqueue"
+	ScopeRead    Scope = "read"
+	ScopeWorker  Scope = "worker"
+	ScopeAdmin   Scope = "admin"
+)
+
+func ParseScope(s string) (Scope, bool) {
+	switch Scope(s) {
+	case ScopeEnqueue, ScopeRead, ScopeWorker, ScopeAdmin:
+		return Scope(s), true
+	}
+	return "", false
+}
+
+type Principal struct {
+	Name   string
+	Tenant string
+	Scopes []Scope
+	// Пустой список - доступ ко всем очередям
+	Queues []string
+}
+
+func (p *Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) CanAccessQueue(queue string) bool {
+	if len(p.Queues) == 0 {
+		return true
+	}
+	for _, q := range p.Queues {
+		if q == queue {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessTenant - ключ без арендатора видит задачи всех арендаторов
+func (p *Principal) CanAccessTenant(tenant string) bool {
+	return p.Tenant == "" || p.Tenant == tenant
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+func Audit(r *http.Request, name, reason string) {
+	if name == "" {
+		name = "anonymous"
+	}
+	slog.Warn("Access denied", "audit", true, "method", r.Method, "path", r.URL.Path,
+		"principal", name, "remote_addr", r.RemoteAddr, "reason", reason,
+		"request_id", logging.RequestIDFromContext(r.Context()))
+}
@@ -0,0 +1,238 @@
+// Package cluster - кластерный режим: хранилище задач реплицируется через raft,
+// задачи выполняет только пул лидера, ведомые пересылают ему запросы на запись.
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"TaskQueue/internal/raft"
+	"TaskQueue/internal/repository"
+)
+
+// ForwardedHeader помечает запрос, уже пересланный другим узлом
+const ForwardedHeader = "X-TaskQueue-Forwarded-By"
+
+// ForwardMode - как ведомый обрабатывает запросы, требующие лидера
+type ForwardMode string
+
+const (
+	// ForwardProxy проксирует запрос лидеру
+	ForwardProxy ForwardMode = "proxy"
+	// ForwardRedirect отвечает 307 с адресом лидера
+	ForwardRedirect ForwardMode = "redirect"
+)
+
+func ParseForwardMode(s string) (ForwardMode, error) {
+	switch ForwardMode(s) {
+	case "", ForwardProxy:
+		return ForwardProxy, nil
+	case ForwardRedirect:
+		return ForwardRedirect, nil
+	}
+	return "", fmt.Errorf("unknown forward mode %q", s)
+}
+
+// ParsePeers разбирает "n1=http://10.0.0.1:9000,n2=http://10.0.0.2:9000,..."
+func ParsePeers(spec string) (map[string]string, error) {
+	peers := make(map[string]string)
+	for _, field := range strings.Split(spec, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, addr, found := strings.Cut(field, "=")
+		if !found || id == "" || addr == "" {
+			return nil, fmt.Errorf("invalid cluster peer %q", field)
+		}
+		if _, err := url.Parse(addr); err != nil {
+			return nil, fmt.Errorf("peer %s: %v", id, err)
+		}
+		if _, exists := peers[id]; exists {
+			return nil, fmt.Errorf("duplicate cluster peer %q", id)
+		}
+		peers[id] = strings.TrimRight(addr, "/")
+	}
+	return peers, nil
+}
+
+type Config struct {
+	NodeID string
+	// Базовые URL API всех узлов, включая этот
+	Peers             map[string]string
+	DataDir           string
+	Secret            string
+	Forward           ForwardMode
+	ElectionTimeout   time.Duration
+	HeartbeatInterval time.Duration
+	SnapshotEntries   int
+}
+
+// Pool переключается при смене лидера (service.QueueService)
+type Pool interface {
+	Suspend()
+	Resume()
+}
+
+type Cluster struct {
+	cfg     Config
+	node    *raft.Node
+	repo    *repository.ReplicatedTaskRepository
+	proxies map[string]*httputil.ReverseProxy
+
+	// Сериализует получение лидерства, потеря обрабатывается без ожидания
+	promoteMu sync.Mutex
+}
+
+func New(cfg Config) (*Cluster, error) {
+	if n := len(cfg.Peers); n < 3 || n > 5 {
+		slog.Warn("Cluster should have 3 to 5 nodes", "nodes", n)
+	}
+	if cfg.Secret == "" {
+		return nil, errors.New("cluster secret is required")
+	}
+	node, err := raft.NewNode(raft.Config{
+		ID:                cfg.NodeID,
+		Peers:             cfg.Peers,
+		DataDir:           cfg.DataDir,
+		Secret:            cfg.Secret,
+		ElectionTimeout:   cfg.ElectionTimeout,
+		HeartbeatInterval: cfg.HeartbeatInterval,
+		SnapshotEntries:   cfg.SnapshotEntries,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cluster{
+		cfg:     cfg,
+		node:    node,
+		repo:    repository.NewReplicatedTaskRepository(node),
+		proxies: make(map[string]*httputil.ReverseProxy),
+	}
+	for id, addr := range cfg.Peers {
+		target, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("peer %s: %v", id, err)
+		}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		// Без буферизации, чтобы SSE /events доходили сразу
+		proxy.FlushInterval = -1
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Warn("Failed to forward request to leader", "leader", id, "path", r.URL.Path, "error", err)
+			writeNoLeader(w)
+		}
+		c.proxies[id] = proxy
+	}
+	return c, nil
+}
+
+// Repository - реплицируемое хранилище для сервиса и сборщика
+func (c *Cluster) Repository() *repository.ReplicatedTaskRepository {
+	return c.repo
+}
+
+func (c *Cluster) Node() *raft.Node {
+	return c.node
+}
+
+// Start запускает raft; пул должен быть создан в резерве (queue.WithStandby)
+func (c *Cluster) Start(pool Pool) {
+	c.repo.OnLeadershipChange(func(leader bool) {
+		if !leader {
+			pool.Suspend()
+			return
+		}
+		c.promoteMu.Lock()
+		defer c.promoteMu.Unlock()
+		if !c.repo.IsLeader() {
+			return
+		}
+		pool.Resume()
+		// Лидерство могло смениться, пока пул принимал задачи
+		if !c.repo.IsLeader() {
+			pool.Suspend()
+		}
+	})
+	c.node.Start(c.repo)
+}
+
+func (c *Cluster) Stop() {
+	c.node.Stop()
+}
+
+// Register добавляет RPC raft и GET /cluster/status
+func (c *Cluster) Register(mux *http.ServeMux) {
+	rpc := c.node.Handler()
+	mux.Handle("POST /raft/vote", rpc)
+	mux.Handle("POST /raft/append", rpc)
+	mux.Handle("POST /raft/snapshot", rpc)
+	mux.HandleFunc("GET /cluster/status", c.StatusHandler)
+}
+
+type Status struct {
+	raft.Status
+	// Узел лидер и применил весь лог прошлых сроков
+	Writable bool              `json:"writable"`
+	Peers    map[string]string `json:"peers"`
+}
+
+func (c *Cluster) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Status{
+		Status:   c.node.Status(),
+		Writable: c.repo.IsLeader(),
+		Peers:    c.cfg.Peers,
+	})
+}
+
+// leaderOnly - запрос изменяет задачи или читает состояние пула лидера.
+// Остальные чтения обслуживаются из реплики и могут слегка отставать.
+func leaderOnly(r *http.Request) bool {
+	p := r.URL.Path
+	switch {
+	case p == "/healthz" || p == "/metrics" || p == "/cluster/status" || p == "/ui":
+		return false
+	case strings.HasPrefix(p, "/raft/") || strings.HasPrefix(p, "/ui/"):
+		return false
+	case r.Method != http.MethodGet && r.Method != http.MethodHead:
+		return true
+	}
+	return p == "/events" || p == "/stats" || strings.HasSuffix(p, "/logs") || strings.HasPrefix(p, "/admin/")
+}
+
+// Middleware пересылает лидеру запросы, которые ведомый обработать не может
+func (c *Cluster) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !leaderOnly(r) || c.repo.IsLeader() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		leaderID, addr := c.node.Leader()
+		// Лидер неизвестен, еще не готов или запрос уже пересылали
+		if leaderID == "" || leaderID == c.cfg.NodeID || r.Header.Get(ForwardedHeader) != "" {
+			writeNoLeader(w)
+			return
+		}
+
+		if c.cfg.Forward == ForwardRedirect {
+			http.Redirect(w, r, addr+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+			return
+		}
+		r.Header.Set(ForwardedHeader, c.cfg.NodeID)
+		c.proxies[leaderID].ServeHTTP(w, r)
+	})
+}
+
+func writeNoLeader(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "No cluster leader available", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/logging"
+	"TaskQueue/internal/service"
+	"TaskQueue/queue"
+)
+
+// BreakersHandler отдает состояние circuit breakers (scope admin)
+func (c *HTTPController) BreakersHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeAdmin, "") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"breakers": c.queueService.Breakers(),
+	})
+}
+
+// ResetBreakerHandler вручную закрывает breaker и возвращает отложенные задачи в очередь
+func (c *HTTPController) ResetBreakerHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeAdmin, "") {
+		return
+	}
+
+	key := r.PathValue("key")
+	if err := c.queueService.ResetBreaker(key); err != nil {
+		if errors.Is(err, queue.ErrBreakerNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Circuit breaker operation", "audit", true, "breaker", key, "state", queue.BreakerClosed,
+		"principal", principalName(r), "request_id", logging.RequestIDFromContext(r.Context()))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"key":   key,
+		"state": string(queue.BreakerClosed),
+	})
+}
+
+// SchemasHandler отдает типы задач со схемами payload (scope admin).
+// Схемы задаются только каталогом SCHEMAS_DIR, одинаковым на всех узлах.
+func (c *HTTPController) SchemasHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeAdmin, "") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"types": c.queueService.SchemaTypes(),
+	})
+}
+
+func (c *HTTPController) GetSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeAdmin, "") {
+		return
+	}
+
+	raw, exists := c.queueService.Schema(r.PathValue("type"))
+	if !exists {
+		http.Error(w, "Schema not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(raw)
+}
+
+// ExportHandler отдает задачи в формате JSONL: status, queue, type, tenant, q
+func (c *HTTPController) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeAdmin, "") {
+		return
+	}
+
+	query := r.URL.Query()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	count, err := c.queueService.Export(w, service.TaskFilter{
+		Status:  query.Get("status"),
+		Queue:   query.Get("queue"),
+		Type:    query.Get("type"),
+		Tenant:  query.Get("tenant"),
+		Query:   query.Get("q"),
+		Allowed: accessFunc(r),
+	})
+	if err != nil {
+		// Заголовки уже отправлены, клиент увидит оборванный поток
+		slog.Warn("Export interrupted", "exported", count, "error", err)
+		return
+	}
+	slog.Info("Tasks exported", "audit", true, "exported", count,
+		"principal", principalName(r), "request_id", logging.RequestIDFromContext(r.Context()))
+}
+
+// ImportHandler загружает задачи из JSONL; mode=skip|overwrite|fail для существующих id
+func (c *HTTPController) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeAdmin, "") {
+		return
+	}
+
+	mode, err := service.ParseConflictMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := c.queueService.Import(r.Body, mode, accessFunc(r))
+	if err != nil {
+		var importErr *service.ImportError
+		switch {
+		case errors.Is(err, service.ErrImportConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrImportForbidden):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.As(err, &importErr):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("Tasks imported", "audit", true, "mode", mode, "imported", result.Imported,
+		"principal", principalName(r), "request_id", logging.RequestIDFromContext(r.Context()))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func principalName(r *http.Request) string {
+	if p, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return p.Name
+	}
+	return "anonymous"
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/logging"
+	"TaskQueue/internal/service"
+	"TaskQueue/queue"
+)
+
+// ListTasksHandler ищет задачи: status, queue, type, q (подстрока ID), limit
+func (c *HTTPController) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeRead, "") {
+		return
+	}
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	tasks := c.queueService.ListTasks(service.TaskFilter{
+		Status:  query.Get("status"),
+		Queue:   query.Get("queue"),
+		Type:    query.Get("type"),
+		Tenant:  query.Get("tenant"),
+		Query:   query.Get("q"),
+		Allowed: accessFunc(r),
+		Limit:   limit,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"tasks": tasks})
+}
+
+func (c *HTTPController) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeRead, "") {
+		return
+	}
+
+	stats := c.queueService.Stats(accessFunc(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+func (c *HTTPController) CancelHandler(w http.ResponseWriter, r *http.Request) {
+	c.operate(w, r, "cancelled", c.queueService.Cancel)
+}
+
+func (c *HTTPController) ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	c.operate(w, r, "queued", c.queueService.Replay)
+}
+
+func (c *HTTPController) ReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	c.operate(w, r, "queued", c.queueService.Release)
+}
+
+// operate выполняет действие оператора над задачей (scope admin)
+func (c *HTTPController) operate(w http.ResponseWriter, r *http.Request, status string, action func(id string) error) {
+	id := r.PathValue("id")
+	task, exists := c.queueService.GetTask(id)
+	if !exists || !canAccessTask(r, task) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !authorize(w, r, auth.ScopeAdmin, task.Queue) {
+		return
+	}
+
+	if err := action(id); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, queue.ErrTaskFinished), errors.Is(err, queue.ErrTaskNotReplayed),
+			errors.Is(err, queue.ErrTaskNotQuarantined):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			writeEnqueueError(w, err)
+		}
+		return
+	}
+
+	slog.Info("Task operation", "audit", true, "task_id", id, "status", status,
+		"principal", principalName(r), "request_id", logging.RequestIDFromContext(r.Context()))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":     id,
+		"status": status,
+	})
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"TaskQueue/internal/auth"
+)
+
+const (
+	eventBuffer       = 64
+	eventKeepAlive    = 15 * time.Second
+	eventStreamFormat = "event: %s\ndata: %s\n\n"
+)
+
+// EventsHandler отдает поток событий задач (Server-Sent Events).
+// Фильтры: task_id, queue, tenant. Видны только разрешенные ключу очереди
+// и собственный арендатор.
+func (c *HTTPController) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeRead, "") {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	taskID := r.URL.Query().Get("task_id")
+	queue := r.URL.Query().Get("queue")
+	tenant := r.URL.Query().Get("tenant")
+	allowed := accessFunc(r)
+	if queue != "" && !canAccessQueue(r, queue) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	stream, unsubscribe := c.queueService.Subscribe(eventBuffer)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+			if (taskID != "" && event.TaskID != taskID) ||
+				(queue != "" && event.Queue != queue) ||
+				(tenant != "" && event.Tenant != tenant) ||
+				!allowed(event.Queue, event.Tenant) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, eventStreamFormat, event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/model"
+	"TaskQueue/queue"
+)
+
+const maxLeaseBatch = 100
+
+type leaseRequest struct {
+	WorkerID          string         `json:"worker_id"`
+	Types             []string       `json:"types"`
+	Queues            []string       `json:"queues"`
+	Max               int            `json:"max"`
+	VisibilityTimeout model.Duration `json:"visibility_timeout"`
+}
+
+type leaseUpdateRequest struct {
+	WorkerID          string         `json:"worker_id"`
+	VisibilityTimeout model.Duration `json:"visibility_timeout"`
+	Error             string         `json:"error"`
+	// Только для /progress
+	Progress   *float64        `json:"progress"`
+	Message    string          `json:"message"`
+	Checkpoint json.RawMessage `json:"checkpoint"`
+}
+
+func (c *HTTPController) LeaseHandler(w http.ResponseWriter, r *http.Request) {
+	var req leaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.WorkerID == "" || len(req.Types) == 0 {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if req.Max <= 0 {
+		req.Max = 1
+	}
+	if req.Max > maxLeaseBatch {
+		req.Max = maxLeaseBatch
+	}
+
+	if !authorize(w, r, auth.ScopeWorker, "") {
+		return
+	}
+	queues, ok := allowedQueues(w, r, req.Queues)
+	if !ok {
+		return
+	}
+
+	tasks := c.queueService.Lease(queue.LeaseRequest{
+		WorkerID:          req.WorkerID,
+		Types:             req.Types,
+		Queues:            queues,
+		Max:               req.Max,
+		VisibilityTimeout: time.Duration(req.VisibilityTimeout),
+		Tenant:            principalTenant(r),
+		Principal:         principalName(r),
+	})
+
+	if tasks == nil {
+		tasks = []queue.LeasedTask{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tasks": tasks})
+}
+
+func (c *HTTPController) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := c.decodeLeaseUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	expiresAt, err := c.queueService.Heartbeat(r.PathValue("id"), leaseHolder(r, req), time.Duration(req.VisibilityTimeout))
+	if err != nil {
+		writeLeaseError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":               r.PathValue("id"),
+		"lease_expires_at": expiresAt,
+	})
+}
+
+// ProgressHandler сохраняет прогресс и чекпоинт удаленной попытки и продлевает аренду
+func (c *HTTPController) ProgressHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := c.decodeLeaseUpdate(w, r)
+	if !ok {
+		return
+	}
+	if req.Progress == nil && req.Checkpoint == nil {
+		http.Error(w, "Missing progress or checkpoint", http.StatusBadRequest)
+		return
+	}
+	if string(req.Checkpoint) == "null" {
+		http.Error(w, "Checkpoint must not be null", http.StatusBadRequest)
+		return
+	}
+
+	expiresAt, err := c.queueService.Progress(r.PathValue("id"), leaseHolder(r, req), queue.ProgressUpdate{
+		Percent:           req.Progress,
+		Message:           req.Message,
+		Checkpoint:        req.Checkpoint,
+		VisibilityTimeout: time.Duration(req.VisibilityTimeout),
+	})
+	if err != nil {
+		writeLeaseError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":               r.PathValue("id"),
+		"lease_expires_at": expiresAt,
+	})
+}
+
+func (c *HTTPController) CompleteHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := c.decodeLeaseUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.queueService.Complete(r.PathValue("id"), leaseHolder(r, req)); err != nil {
+		writeLeaseError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *HTTPController) FailHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := c.decodeLeaseUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.queueService.Fail(r.PathValue("id"), leaseHolder(r, req), req.Error); err != nil {
+		writeLeaseError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *HTTPController) decodeLeaseUpdate(w http.ResponseWriter, r *http.Request) (leaseUpdateRequest, bool) {
+	var req leaseUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return req, false
+	}
+	if req.WorkerID == "" {
+		http.Error(w, "Missing worker_id", http.StatusBadRequest)
+		return req, false
+	}
+	if !authorize(w, r, auth.ScopeWorker, "") {
+		return req, false
+	}
+	// Чужие очереди и арендаторы неотличимы от задачи без аренды
+	if task, exists := c.queueService.GetTask(r.PathValue("id")); exists && !canAccessTask(r, task) {
+		writeLeaseError(w, queue.ErrTaskNotLeased)
+		return req, false
+	}
+	return req, true
+}
+
+// leaseHolder - аренда принадлежит паре worker_id и ключ запроса
+func leaseHolder(r *http.Request, req leaseUpdateRequest) queue.LeaseHolder {
+	return queue.LeaseHolder{WorkerID: req.WorkerID, Principal: principalName(r)}
+}
+
+func writeLeaseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, queue.ErrTaskNotLeased):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, queue.ErrLeaseNotHeld), errors.Is(err, queue.ErrLeaseExpired):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// allowedQueues сужает запрошенные очереди до разрешенных ключу
+func allowedQueues(w http.ResponseWriter, r *http.Request, requested []string) ([]string, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok || len(principal.Queues) == 0 {
+		return requested, true
+	}
+	if len(requested) == 0 {
+		return principal.Queues, true
+	}
+	for _, q := range requested {
+		if !principal.CanAccessQueue(q) {
+			auth.Audit(r, principal.Name, "queue "+q+" not allowed")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return nil, false
+		}
+	}
+	return requested, true
+}
+
+// principalTenant - арендатор ключа; пустой для ключей без арендатора
+func principalTenant(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return principal.Tenant
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"TaskQueue/internal/auth"
+)
+
+// LogsHandler отдает строки попытки в формате JSON Lines.
+// attempt по умолчанию - последняя попытка; follow=true держит соединение
+// до завершения попытки.
+func (c *HTTPController) LogsHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeRead, "") {
+		return
+	}
+
+	attempt := 0
+	if value := r.URL.Query().Get("attempt"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid attempt", http.StatusBadRequest)
+			return
+		}
+		attempt = n
+	}
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+
+	taskID := r.PathValue("id")
+	task, exists := c.queueService.GetTask(taskID)
+	if !exists || !canAccessTask(r, task) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	buffer, attempt, exists := c.queueService.TaskLogs(taskID, attempt)
+	if !exists {
+		http.Error(w, "Logs not found", http.StatusNotFound)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Task-Attempt", strconv.Itoa(attempt))
+	w.Header().Set("X-Log-Lines-Dropped", strconv.Itoa(buffer.Dropped()))
+
+	encoder := json.NewEncoder(w)
+	offset := 0
+	for {
+		lines, next, closed, wait := buffer.Read(offset)
+		for _, line := range lines {
+			if err := encoder.Encode(line); err != nil {
+				return
+			}
+		}
+		offset = next
+
+		if !follow || closed || flusher == nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-wait:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -1,13 +1,31 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/logging"
+	"TaskQueue/internal/metrics"
 	"TaskQueue/internal/model"
+	"TaskQueue/internal/schema"
 	"TaskQueue/internal/service"
+	"TaskQueue/internal/tracing"
+	"TaskQueue/queue"
 )
 
+const TenantHeader = "X-Tenant-ID"
+
 type HTTPController struct {
 	queueService service.QueueService
 }
@@ -30,13 +48,59 @@ func (c *HTTPController) EnqueueHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if task.ID == "" || task.Payload == "" || task.MaxRetries <= 0 {
+	if task.ID == "" || len(task.Payload) == 0 || string(task.Payload) == "null" || task.MaxRetries <= 0 {
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
+	if !model.IsJSONContentType(task.ContentType) {
+		// Тело другого типа содержимого передается JSON строкой
+		var body string
+		if err := json.Unmarshal(task.Payload, &body); err != nil {
+			http.Error(w, "Payload with content_type "+task.ContentType+" must be a JSON string", http.StatusBadRequest)
+			return
+		}
+	}
 
-	if err := c.queueService.Enqueue(&task); err != nil {
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	if task.RetryPolicy != nil {
+		if err := task.RetryPolicy.Validate(); err != nil {
+			http.Error(w, "Invalid retry_policy: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if task.Queue == "" {
+		task.Queue = model.DefaultQueue
+	}
+	task.RequestID = logging.RequestIDFromContext(r.Context())
+	task.Tenant = resolveTenant(r)
+
+	if !authorize(w, r, auth.ScopeEnqueue, task.Queue) {
+		return
+	}
+
+	ctx := r.Context()
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		timeout, err := parseTimeout(value)
+		if err != nil {
+			http.Error(w, "Invalid timeout", http.StatusBadRequest)
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "enqueue", trace.WithAttributes(
+		attribute.String("task.id", task.ID),
+		attribute.String("task.queue", task.Queue),
+	))
+	defer span.End()
+	tracing.Inject(ctx, &task)
+
+	if err := c.queueService.EnqueueContext(ctx, &task); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		writeEnqueueError(w, err)
 		return
 	}
 
@@ -65,8 +129,12 @@ func (c *HTTPController) StatusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status, exists := c.queueService.GetTaskStatus(id)
-	if !exists {
+	if !authorize(w, r, auth.ScopeRead, "") {
+		return
+	}
+
+	task, exists := c.queueService.GetTask(id)
+	if !exists || !canAccessTask(r, task) {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -74,6 +142,128 @@ func (c *HTTPController) StatusHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"id":     id,
-		"status": status,
+		"status": task.GetStatus(),
 	})
 }
+
+// TaskHandler отдает полное состояние задачи, включая прогресс
+func (c *HTTPController) TaskHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeRead, "") {
+		return
+	}
+
+	task, exists := c.queueService.GetTask(r.PathValue("id"))
+	if !exists || !canAccessTask(r, task) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task.Snapshot())
+}
+
+func (c *HTTPController) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.ScopeRead, "") {
+		return
+	}
+	metrics.Default.Handler().ServeHTTP(w, r)
+}
+
+// writeEnqueueError: несоответствие схеме - 400 с ошибками полей, переполнение
+// с оценкой и лимиты арендатора - 429 и Retry-After, дубликат - 409, остальное - 503
+func writeEnqueueError(w http.ResponseWriter, err error) {
+	var schemaErr *schema.ValidationError
+	var fullErr *queue.QueueFullError
+	var limitErr *queue.TenantLimitError
+	switch {
+	case errors.As(err, &schemaErr):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "payload does not match schema",
+			"type":   schemaErr.TaskType,
+			"errors": schemaErr.Errors,
+		})
+	case errors.As(err, &fullErr):
+		writeTooManyRequests(w, err, fullErr.RetryAfter)
+	case errors.As(err, &limitErr):
+		writeTooManyRequests(w, err, limitErr.RetryAfter)
+	case errors.Is(err, service.ErrTaskExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+func writeTooManyRequests(w http.ResponseWriter, err error, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, err.Error(), http.StatusTooManyRequests)
+}
+
+// parseTimeout принимает длительность Go ("5s") или число секунд
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return 0, errors.New("negative timeout")
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, errors.New("invalid timeout")
+	}
+	return timeout, nil
+}
+
+// authorize проверяет scope и очередь текущего ключа.
+// Без аутентификации (ключи не настроены) доступ открыт.
+func authorize(w http.ResponseWriter, r *http.Request, scope auth.Scope, queue string) bool {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		return true
+	}
+
+	if !principal.HasScope(scope) {
+		auth.Audit(r, principal.Name, "missing scope "+string(scope))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+
+	if queue != "" && !principal.CanAccessQueue(queue) {
+		auth.Audit(r, principal.Name, "queue "+queue+" not allowed")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+func canAccessQueue(r *http.Request, queue string) bool {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	return !ok || principal.CanAccessQueue(queue)
+}
+
+func canAccessTask(r *http.Request, task *model.Task) bool {
+	return accessFunc(r)(task.Queue, task.Tenant)
+}
+
+// accessFunc - видимость задач для текущего ключа: разрешенные очереди
+// и собственный арендатор
+func accessFunc(r *http.Request) service.AccessFunc {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	return func(queue, tenant string) bool {
+		return !ok || (principal.CanAccessQueue(queue) && principal.CanAccessTenant(tenant))
+	}
+}
+
+// resolveTenant: арендатор ключа, иначе заголовок X-Tenant-ID, иначе default
+func resolveTenant(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok && principal.Tenant != "" {
+		return principal.Tenant
+	}
+	if tenant := strings.TrimSpace(r.Header.Get(TenantHeader)); tenant != "" {
+		return tenant
+	}
+	return model.DefaultTenant
+}
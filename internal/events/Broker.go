@@ -0,0 +1,70 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"TaskQueue/internal/model"
+)
+
+const (
+	TypeStatus   = "status"
+	TypeProgress = "progress"
+)
+
+type Event struct {
+	Type     string              `json:"type"`
+	TaskID   string              `json:"task_id"`
+	TaskType string              `json:"task_type"`
+	Queue    string              `json:"queue"`
+	Tenant   string              `json:"tenant,omitempty"`
+	Status   string              `json:"status,omitempty"`
+	Attempt  int                 `json:"attempt,omitempty"`
+	Progress *model.TaskProgress `json:"progress,omitempty"`
+	Time     time.Time           `json:"time"`
+}
+
+// Broker рассылает события подписчикам. Медленный подписчик
+// теряет события, а не блокирует воркеры.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[chan Event]struct{})}
+}
+
+func (b *Broker) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *Broker) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			eventsDropped.Inc()
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package events
+
+import "TaskQueue/internal/metrics"
+
+var eventsDropped = metrics.Default.Counter("taskqueue_events_dropped_total",
+	"Events dropped for slow subscribers.")
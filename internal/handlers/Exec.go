@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"TaskQueue/internal/model"
+	"TaskQueue/queue"
+)
+
+// ExecTaskType - тип задач, которые запускают команды из EXEC_COMMANDS_FILE
+const ExecTaskType = "exec"
+
+const (
+	defaultExecTimeout   = 5 * time.Minute
+	defaultKillGrace     = 5 * time.Second
+	defaultMaxOutputSize = 1 << 20
+)
+
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExecCommand - шаблон команды. Аргументы передаются процессу напрямую,
+// без shell: значение параметра заполняет только свой аргумент.
+type ExecCommand struct {
+	// Абсолютный путь к программе, поиск по PATH не выполняется
+	Path string `json:"path"`
+	// Аргументы с подстановками {param}
+	Args   []string             `json:"args"`
+	Params map[string]ExecParam `json:"params"`
+	// Рабочий каталог; пусто - временный каталог на попытку
+	Dir string `json:"dir"`
+	// Переменные окружения сервера, которые получает команда
+	Env []string `json:"env"`
+	// Фиксированные переменные окружения
+	SetEnv    map[string]string `json:"set_env"`
+	Timeout   model.Duration    `json:"timeout"`
+	KillGrace model.Duration    `json:"kill_grace"`
+	Limits    ExecLimits        `json:"limits"`
+	// Байт stdout и stderr, попадающих в логи задачи
+	MaxOutputBytes int `json:"max_output_bytes"`
+}
+
+type ExecParam struct {
+	// Регулярное выражение для всего значения. Без него запрещены
+	// значения, начинающиеся с "-", чтобы нельзя было подставить флаг.
+	Pattern string `json:"pattern"`
+	// nil - параметр обязателен
+	Default *string `json:"default"`
+
+	pattern *regexp.Regexp
+}
+
+// ExecLimits - rlimits процесса (только Linux); 0 - без ограничения
+type ExecLimits struct {
+	CPUSeconds  uint64 `json:"cpu_seconds"`
+	MemoryBytes uint64 `json:"memory_bytes"`
+	OpenFiles   uint64 `json:"open_files"`
+}
+
+func (l ExecLimits) empty() bool {
+	return l == ExecLimits{}
+}
+
+// ExecPayload - payload задачи exec
+type ExecPayload struct {
+	Command string            `json:"command"`
+	Args    map[string]string `json:"args"`
+}
+
+// ExecResult сохраняется как результат задачи
+type ExecResult struct {
+	ExitCode   int    `json:"exit_code"`
+	Signal     string `json:"signal,omitempty"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type ExecHandler struct {
+	commands map[string]*ExecCommand
+}
+
+// LoadExecCommands читает JSON объект "имя -> ExecCommand"
+func LoadExecCommands(file string) (*ExecHandler, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read exec commands: %v", err)
+	}
+	var commands map[string]*ExecCommand
+	if err := json.Unmarshal(data, &commands); err != nil {
+		return nil, fmt.Errorf("parse exec commands: %v", err)
+	}
+	return NewExecHandler(commands)
+}
+
+func NewExecHandler(commands map[string]*ExecCommand) (*ExecHandler, error) {
+	for name, command := range commands {
+		if err := command.compile(); err != nil {
+			return nil, fmt.Errorf("exec command %s: %v", name, err)
+		}
+	}
+	return &ExecHandler{commands: commands}, nil
+}
+
+func (c *ExecCommand) compile() error {
+	if !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path %q must be absolute", c.Path)
+	}
+	if !c.Limits.empty() && !limitsSupported {
+		return errors.New("resource limits are supported only on linux")
+	}
+	for name, param := range c.Params {
+		if param.Pattern != "" {
+			compiled, err := regexp.Compile("^(?:" + param.Pattern + ")$")
+			if err != nil {
+				return fmt.Errorf("param %s: %v", name, err)
+			}
+			param.pattern = compiled
+			c.Params[name] = param
+		}
+	}
+	for _, arg := range c.Args {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(arg, -1) {
+			if _, declared := c.Params[match[1]]; !declared {
+				return fmt.Errorf("argument %q uses undeclared param %s", arg, match[1])
+			}
+		}
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = model.Duration(defaultExecTimeout)
+	}
+	if c.KillGrace <= 0 {
+		c.KillGrace = model.Duration(defaultKillGrace)
+	}
+	if c.MaxOutputBytes <= 0 {
+		c.MaxOutputBytes = defaultMaxOutputSize
+	}
+	return nil
+}
+
+// argv подставляет параметры payload в шаблон аргументов
+func (c *ExecCommand) argv(values map[string]string) ([]string, error) {
+	var unknown []string
+	for name := range values {
+		if _, declared := c.Params[name]; !declared {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("undeclared args: %s", strings.Join(unknown, ", "))
+	}
+
+	resolved := make(map[string]string, len(c.Params))
+	for name, param := range c.Params {
+		value, set := values[name]
+		if !set {
+			if param.Default == nil {
+				return nil, fmt.Errorf("missing arg %s", name)
+			}
+			value = *param.Default
+		}
+		if err := param.validate(value); err != nil {
+			return nil, fmt.Errorf("arg %s: %v", name, err)
+		}
+		resolved[name] = value
+	}
+
+	argv := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		argv[i] = placeholderPattern.ReplaceAllStringFunc(arg, func(placeholder string) string {
+			return resolved[placeholder[1:len(placeholder)-1]]
+		})
+	}
+	return argv, nil
+}
+
+func (p ExecParam) validate(value string) error {
+	if strings.ContainsRune(value, 0) {
+		return errors.New("contains NUL byte")
+	}
+	if p.pattern != nil {
+		if !p.pattern.MatchString(value) {
+			return fmt.Errorf("does not match %q", p.Pattern)
+		}
+		return nil
+	}
+	if strings.HasPrefix(value, "-") {
+		return errors.New("must not start with '-'")
+	}
+	return nil
+}
+
+func (c *ExecCommand) environ() []string {
+	// Не nil: пустой список не наследует окружение сервера
+	env := []string{}
+	for _, name := range c.Env {
+		if value, set := os.LookupEnv(name); set {
+			env = append(env, name+"="+value)
+		}
+	}
+	for name, value := range c.SetEnv {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
+
+// Handle - queue.Handler задач exec. Ненулевой код выхода, таймаут
+// и отмена считаются неудачной попыткой, неверный payload - постоянной ошибкой.
+func (h *ExecHandler) Handle(ctx context.Context, task *model.Task) error {
+	var payload ExecPayload
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return queue.Permanent(fmt.Errorf("invalid exec payload: %v", err))
+	}
+	command, exists := h.commands[payload.Command]
+	if !exists {
+		return queue.Permanent(fmt.Errorf("unknown exec command %q", payload.Command))
+	}
+	argv, err := command.argv(payload.Args)
+	if err != nil {
+		return queue.Permanent(fmt.Errorf("exec command %s: %v", payload.Command, err))
+	}
+
+	dir := command.Dir
+	if dir == "" {
+		if dir, err = os.MkdirTemp("", "taskqueue-exec-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(command.Timeout))
+	defer cancel()
+
+	logger := queue.LoggerFromContext(ctx)
+	stdout := newLineWriter(logger, "stdout", command.MaxOutputBytes)
+	stderr := newLineWriter(logger, "stderr", command.MaxOutputBytes)
+
+	cmd := exec.CommandContext(ctx, command.Path, argv...)
+	cmd.Dir = dir
+	cmd.Env = command.environ()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	grace := time.Duration(command.KillGrace)
+	cmd.Cancel = func() error { return terminate(cmd, grace) }
+	// Потомки, унаследовавшие stdout, не должны держать попытку бесконечно
+	cmd.WaitDelay = grace + time.Second
+
+	logger.Info("Starting command", "command", payload.Command, "path", command.Path, "args", argv, "dir", dir)
+	started := time.Now()
+	if err := start(cmd, command.Limits); err != nil {
+		return fmt.Errorf("start %s: %v", payload.Command, err)
+	}
+	waitErr := cmd.Wait()
+	killGroup(cmd)
+	stdout.Close()
+	stderr.Close()
+
+	result := ExecResult{
+		ExitCode:   cmd.ProcessState.ExitCode(),
+		Signal:     exitSignal(cmd.ProcessState),
+		TimedOut:   errors.Is(ctx.Err(), context.DeadlineExceeded),
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if err := queue.ReporterFromContext(ctx).Result(result); err != nil {
+		logger.Warn("Failed to save command result", "error", err)
+	}
+	logger.Info("Command finished", "exit_code", result.ExitCode, "signal", result.Signal, "duration_ms", result.DurationMs)
+
+	switch {
+	case result.TimedOut:
+		return fmt.Errorf("command %s timed out after %s", payload.Command, time.Duration(command.Timeout))
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case result.Signal != "":
+		return fmt.Errorf("command %s killed by %s", payload.Command, result.Signal)
+	case result.ExitCode != 0:
+		return fmt.Errorf("command %s exited with code %d", payload.Command, result.ExitCode)
+	case waitErr != nil:
+		return waitErr
+	}
+	return nil
+}
+
+// lineWriter пишет вывод команды в лог попытки построчно
+type lineWriter struct {
+	logger  *slog.Logger
+	stream  string
+	limit   int
+	written int
+	buf     bytes.Buffer
+}
+
+func newLineWriter(logger *slog.Logger, stream string, limit int) *lineWriter {
+	return &lineWriter{logger: logger, stream: stream, limit: limit}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.written >= w.limit {
+		return n, nil
+	}
+	if remaining := w.limit - w.written; len(p) > remaining {
+		p = p[:remaining]
+	}
+	w.written += len(p)
+	w.buf.Write(p)
+
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Неполная строка ждет продолжения
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			break
+		}
+		w.emit(strings.TrimRight(line, "\r\n"))
+	}
+	if w.written >= w.limit {
+		w.flush()
+		w.logger.Warn("Command output truncated", "stream", w.stream, "limit_bytes", w.limit)
+	}
+	return n, nil
+}
+
+func (w *lineWriter) Close() error {
+	w.flush()
+	return nil
+}
+
+func (w *lineWriter) flush() {
+	if w.buf.Len() > 0 {
+		w.emit(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+func (w *lineWriter) emit(line string) {
+	if w.stream == "stderr" {
+		w.logger.Warn(line, "stream", w.stream)
+		return
+	}
+	w.logger.Info(line, "stream", w.stream)
+}
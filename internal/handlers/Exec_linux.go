@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const limitsSupported = true
+
+// start запускает команду в своей группе процессов. С лимитами процесс
+// стартует под ptrace и останавливается на execve: rlimits применяются
+// до первой инструкции программы, затем трассировка снимается.
+func start(cmd *exec.Cmd, limits ExecLimits) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if limits.empty() {
+		return cmd.Start()
+	}
+
+	// Ожидать и отпускать трассируемый процесс может только поток, который его запустил
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cmd.SysProcAttr.Ptrace = true
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pid := cmd.Process.Pid
+
+	err := waitForExecStop(pid)
+	if err == nil {
+		err = applyLimits(pid, limits)
+	}
+	if detachErr := syscall.PtraceDetach(pid); err == nil && detachErr != nil {
+		err = fmt.Errorf("ptrace detach: %v", detachErr)
+	}
+	if err != nil {
+		syscall.Kill(-pid, syscall.SIGKILL)
+		cmd.Wait()
+		return err
+	}
+	return nil
+}
+
+func waitForExecStop(pid int) error {
+	var status syscall.WaitStatus
+	for {
+		_, err := syscall.Wait4(pid, &status, 0, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("wait for exec: %v", err)
+		}
+		if !status.Stopped() {
+			return fmt.Errorf("process exited before limits were applied")
+		}
+		return nil
+	}
+}
+
+func applyLimits(pid int, limits ExecLimits) error {
+	for _, limit := range []struct {
+		resource int
+		value    uint64
+		name     string
+	}{
+		{unix.RLIMIT_CPU, limits.CPUSeconds, "cpu"},
+		{unix.RLIMIT_AS, limits.MemoryBytes, "memory"},
+		{unix.RLIMIT_NOFILE, limits.OpenFiles, "open files"},
+	} {
+		if limit.value == 0 {
+			continue
+		}
+		rlimit := unix.Rlimit{Cur: limit.value, Max: limit.value}
+		if err := unix.Prlimit(pid, limit.resource, &rlimit, nil); err != nil {
+			return fmt.Errorf("set %s limit: %v", limit.name, err)
+		}
+	}
+	return nil
+}
+
+// terminate посылает группе SIGTERM, а через grace - SIGKILL
+func terminate(cmd *exec.Cmd, grace time.Duration) error {
+	pid := cmd.Process.Pid
+	if err := syscall.Kill(-pid, syscall.SIGTERM); err != nil {
+		return cmd.Process.Kill()
+	}
+	time.AfterFunc(grace, func() { syscall.Kill(-pid, syscall.SIGKILL) })
+	return nil
+}
+
+// killGroup добивает потомков, переживших основной процесс
+func killGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+func exitSignal(state *os.ProcessState) string {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal().String()
+	}
+	return ""
+}
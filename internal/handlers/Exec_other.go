@@ -0,0 +1,26 @@
+//go:build !linux
+
+package handlers
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Лимиты ресурсов и группы процессов есть только в Linux сборке
+const limitsSupported = false
+
+func start(cmd *exec.Cmd, limits ExecLimits) error {
+	return cmd.Start()
+}
+
+func terminate(cmd *exec.Cmd, grace time.Duration) error {
+	return cmd.Process.Kill()
+}
+
+func killGroup(cmd *exec.Cmd) {}
+
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"TaskQueue/internal/model"
+	"TaskQueue/queue"
+)
+
+// HTTPTaskType - тип задач, которые выполняют HTTP запрос из payload
+const HTTPTaskType = "http"
+
+const (
+	defaultHTTPTimeout   = 30 * time.Second
+	defaultMaxRetryAfter = time.Hour
+	maxRedirects         = 10
+)
+
+// Коды ответа по умолчанию: остальные считаются постоянной ошибкой
+var (
+	defaultSuccessCodes = []string{"2xx"}
+	defaultRetryCodes   = []string{"408", "425", "429", "5xx"}
+)
+
+type HTTPConfig struct {
+	// Разрешенные хосты: "api.internal" (любой порт), "api.internal:8443",
+	// "*.svc.local" (поддомены), IPv6 только в скобках: "[::1]", "[::1]:8443"
+	AllowedHosts []string
+	Timeout      time.Duration
+	// Байт тела ответа, сохраняемых в результате
+	MaxResponseBytes int
+	// Верхняя граница задержки из Retry-After
+	MaxRetryAfter time.Duration
+	// nil - http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// HTTPPayload - payload задачи http
+type HTTPPayload struct {
+	// По умолчанию GET
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Строка отправляется как есть, остальные JSON значения - как application/json
+	Body    json.RawMessage `json:"body"`
+	Timeout model.Duration  `json:"timeout"`
+	// Переопределяют классификацию кодов ответа: "2xx", "404"
+	SuccessCodes []string `json:"success_codes"`
+	RetryCodes   []string `json:"retry_codes"`
+}
+
+// HTTPResult сохраняется как результат задачи
+type HTTPResult struct {
+	StatusCode    int               `json:"status_code"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          string            `json:"body,omitempty"`
+	BodyTruncated bool              `json:"body_truncated,omitempty"`
+	DurationMs    int64             `json:"duration_ms"`
+}
+
+var errTargetNotAllowed = errors.New("target is not allowed")
+
+type HTTPHandler struct {
+	config  HTTPConfig
+	allowed []allowedHost
+	client  *http.Client
+}
+
+// allowedHost - разобранный элемент списка хостов, пустой port - любой
+type allowedHost struct {
+	pattern string
+	port    string
+}
+
+func parseAllowedHost(host string) (allowedHost, error) {
+	if host == "" || host == "*." || strings.Contains(host, "/") {
+		return allowedHost{}, errors.New("empty host or path")
+	}
+	// Шаблон допускается только как "*." в начале: "*" и "*example.com"
+	// пропустили бы любой хост или evilexample.com
+	if strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		return allowedHost{}, errors.New("wildcard must be a leading \"*.\"")
+	}
+	if strings.HasPrefix(host, "[") {
+		if strings.HasSuffix(host, "]") {
+			return allowedHost{pattern: host[1 : len(host)-1]}, nil
+		}
+		pattern, port, err := net.SplitHostPort(host)
+		if err != nil {
+			return allowedHost{}, err
+		}
+		return allowedHost{pattern: pattern, port: port}, validPort(port)
+	}
+	switch strings.Count(host, ":") {
+	case 0:
+		return allowedHost{pattern: host}, nil
+	case 1:
+		pattern, port, _ := strings.Cut(host, ":")
+		if pattern == "" {
+			return allowedHost{}, errors.New("empty host")
+		}
+		return allowedHost{pattern: pattern, port: port}, validPort(port)
+	}
+	return allowedHost{}, errors.New("IPv6 address must be in brackets")
+}
+
+func validPort(port string) error {
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+func NewHTTPHandler(config HTTPConfig) (*HTTPHandler, error) {
+	if len(config.AllowedHosts) == 0 {
+		return nil, errors.New("no allowed hosts")
+	}
+	config.AllowedHosts = append([]string(nil), config.AllowedHosts...)
+	allowed := make([]allowedHost, len(config.AllowedHosts))
+	for i, host := range config.AllowedHosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		parsed, err := parseAllowedHost(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed host %q: %v", config.AllowedHosts[i], err)
+		}
+		config.AllowedHosts[i] = host
+		allowed[i] = parsed
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultHTTPTimeout
+	}
+	if config.MaxResponseBytes <= 0 {
+		config.MaxResponseBytes = defaultMaxOutputSize
+	}
+	if config.MaxRetryAfter <= 0 {
+		config.MaxRetryAfter = defaultMaxRetryAfter
+	}
+
+	h := &HTTPHandler{config: config, allowed: allowed}
+	h.client = &http.Client{
+		Transport: config.Transport,
+		// Редирект не должен увести запрос за пределы списка хостов
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return h.checkURL(req.URL)
+		},
+	}
+	return h, nil
+}
+
+func (h *HTTPHandler) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", errTargetNotAllowed, u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	for _, allowed := range h.allowed {
+		if allowed.port != "" && allowed.port != port {
+			continue
+		}
+		if suffix, wildcard := strings.CutPrefix(allowed.pattern, "*."); wildcard {
+			if strings.HasSuffix(host, "."+suffix) {
+				return nil
+			}
+		} else if host == allowed.pattern {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %s", errTargetNotAllowed, u.Host)
+}
+
+// Handle - queue.Handler задач http. Код ответа из retry_codes - неудачная
+// попытка (с задержкой из Retry-After), прочие неуспешные - постоянная ошибка.
+func (h *HTTPHandler) Handle(ctx context.Context, task *model.Task) error {
+	var payload HTTPPayload
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return queue.Permanent(fmt.Errorf("invalid http payload: %v", err))
+	}
+	req, err := h.newRequest(ctx, payload)
+	if err != nil {
+		return queue.Permanent(err)
+	}
+	successCodes, retryCodes := payload.SuccessCodes, payload.RetryCodes
+	if successCodes == nil {
+		successCodes = defaultSuccessCodes
+	}
+	if retryCodes == nil {
+		retryCodes = defaultRetryCodes
+	}
+	for _, code := range append(append([]string{}, successCodes...), retryCodes...) {
+		if !validStatusPattern(code) {
+			return queue.Permanent(fmt.Errorf("invalid status code pattern %q", code))
+		}
+	}
+
+	timeout := h.config.Timeout
+	if payload.Timeout > 0 {
+		timeout = time.Duration(payload.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	logger := queue.LoggerFromContext(ctx)
+	target := req.Method + " " + req.URL.Redacted()
+	logger.Info("Sending request", "method", req.Method, "url", req.URL.Redacted())
+
+	started := time.Now()
+	resp, err := h.client.Do(req)
+	if err != nil {
+		if errors.Is(err, errTargetNotAllowed) {
+			return queue.Permanent(fmt.Errorf("%s: %v", target, err))
+		}
+		if ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s: %v", target, err)
+	}
+	defer resp.Body.Close()
+
+	body, truncated, err := readLimited(resp.Body, h.config.MaxResponseBytes)
+	if err != nil {
+		return fmt.Errorf("%s: read response: %v", target, err)
+	}
+	result := HTTPResult{
+		StatusCode:    resp.StatusCode,
+		Headers:       make(map[string]string, len(resp.Header)),
+		Body:          string(body),
+		BodyTruncated: truncated,
+		DurationMs:    time.Since(started).Milliseconds(),
+	}
+	for name, values := range resp.Header {
+		result.Headers[name] = strings.Join(values, ", ")
+	}
+	if err := queue.ReporterFromContext(ctx).Result(result); err != nil {
+		logger.Warn("Failed to save response", "error", err)
+	}
+	logger.Info("Response received", "status", resp.StatusCode, "duration_ms", result.DurationMs,
+		"body_bytes", len(body), "body_truncated", truncated)
+
+	switch {
+	case matchStatus(successCodes, resp.StatusCode):
+		return nil
+	case matchStatus(retryCodes, resp.StatusCode):
+		err := fmt.Errorf("%s: status %d", target, resp.StatusCode)
+		if delay := h.retryAfter(resp.Header.Get("Retry-After")); delay > 0 {
+			return queue.RetryAfter(err, delay)
+		}
+		return err
+	}
+	return queue.Permanent(fmt.Errorf("%s: status %d", target, resp.StatusCode))
+}
+
+func (h *HTTPHandler) newRequest(ctx context.Context, payload HTTPPayload) (*http.Request, error) {
+	method := strings.ToUpper(payload.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	u, err := url.Parse(payload.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %v", err)
+	}
+	if u.User != nil {
+		return nil, errors.New("credentials in url are not allowed, use headers")
+	}
+	if err := h.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	isJSON := false
+	if len(payload.Body) > 0 && string(payload.Body) != "null" {
+		var text string
+		if err := json.Unmarshal(payload.Body, &text); err == nil {
+			body = strings.NewReader(text)
+		} else {
+			body = bytes.NewReader(payload.Body)
+			isJSON = true
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range payload.Headers {
+		req.Header.Set(name, value)
+	}
+	if isJSON && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "TaskQueue")
+	}
+	return req, nil
+}
+
+// retryAfter разбирает Retry-After в секундах или HTTP-дате
+func (h *HTTPHandler) retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		delay = time.Until(at).Round(time.Second)
+	}
+	if delay < 0 {
+		return 0
+	}
+	if delay > h.config.MaxRetryAfter {
+		return h.config.MaxRetryAfter
+	}
+	return delay
+}
+
+func readLimited(r io.Reader, limit int) ([]byte, bool, error) {
+	body, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(body) > limit {
+		return body[:limit], true, nil
+	}
+	return body, false, nil
+}
+
+func validStatusPattern(pattern string) bool {
+	if len(pattern) != 3 {
+		return false
+	}
+	if pattern[1:] == "xx" {
+		return pattern[0] >= '1' && pattern[0] <= '5'
+	}
+	code, err := strconv.Atoi(pattern)
+	return err == nil && code >= 100 && code <= 599
+}
+
+func matchStatus(patterns []string, status int) bool {
+	code := strconv.Itoa(status)
+	for _, pattern := range patterns {
+		if pattern == code || (pattern[1:] == "xx" && pattern[0] == code[0]) {
+			return true
+		}
+	}
+	return false
+}
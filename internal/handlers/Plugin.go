@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"TaskQueue/internal/model"
+	"TaskQueue/queue"
+)
+
+const (
+	pluginExt = ".wasm"
+	// Модуль импорта хост-функций
+	pluginHostModule = "taskqueue"
+
+	defaultPluginMemory  = 64 << 20
+	defaultPluginTimeout = 30 * time.Second
+	wasmPageSize         = 64 << 10
+)
+
+// Типы встроенных обработчиков, их нельзя заменить модулем
+var reservedPluginTypes = map[string]bool{
+	ExecTaskType: true,
+	HTTPTaskType: true,
+}
+
+// Коды возврата handle
+const (
+	pluginOK        = 0
+	pluginRetry     = 1
+	pluginPermanent = 2
+)
+
+// Registry - реестр обработчиков по типу задачи (queue.WorkerPool, service.QueueService)
+type Registry interface {
+	RegisterHandler(taskType string, handler queue.Handler)
+}
+
+type PluginConfig struct {
+	// Каталог с модулями <task_type>.wasm
+	Dir string
+	// Лимит линейной памяти экземпляра
+	MemoryLimitBytes int
+	// Лимит времени попытки
+	Timeout time.Duration
+}
+
+// Plugins загружает WebAssembly модули из каталога и регистрирует их как
+// обработчики задач. Каждая попытка выполняется в новом экземпляре модуля.
+type Plugins struct {
+	cfg      PluginConfig
+	registry Registry
+	runtime  wazero.Runtime
+
+	mu         sync.RWMutex
+	plugins    map[string]*plugin
+	registered map[string]bool
+	// Время изменения файлов, которые не удалось загрузить
+	failed map[string]time.Time
+}
+
+type plugin struct {
+	compiled wazero.CompiledModule
+	modTime  time.Time
+	size     int64
+	// Попытки, которые еще используют модуль после замены
+	active sync.WaitGroup
+}
+
+// pluginCall - состояние вызова, доступное хост-функциям через контекст
+type pluginCall struct {
+	logger *slog.Logger
+	result []byte
+	err    string
+}
+
+type pluginCallKey struct{}
+
+func NewPlugins(cfg PluginConfig, registry Registry) (*Plugins, error) {
+	if cfg.MemoryLimitBytes <= 0 {
+		cfg.MemoryLimitBytes = defaultPluginMemory
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultPluginTimeout
+	}
+	pages := (cfg.MemoryLimitBytes + wasmPageSize - 1) / wasmPageSize
+	if pages > 65536 {
+		return nil, fmt.Errorf("memory limit %d exceeds 4GiB", cfg.MemoryLimitBytes)
+	}
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(pages)).
+		WithCloseOnContextDone(true))
+	if err := instantiateHost(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+
+	p := &Plugins{
+		cfg:        cfg,
+		registry:   registry,
+		runtime:    runtime,
+		plugins:    make(map[string]*plugin),
+		registered: make(map[string]bool),
+		failed:     make(map[string]time.Time),
+	}
+	if err := p.Reload(); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+	return p, nil
+}
+
+func instantiateHost(ctx context.Context, runtime wazero.Runtime) error {
+	// WASI без файловой системы и окружения: stdout и stderr идут в логи задачи
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		return fmt.Errorf("instantiate wasi: %v", err)
+	}
+	_, err := runtime.NewHostModuleBuilder(pluginHostModule).
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) {
+		call := ctx.Value(pluginCallKey{}).(*pluginCall)
+		call.result = readMemory(m, ptr, size)
+	}).Export("set_result").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) {
+		call := ctx.Value(pluginCallKey{}).(*pluginCall)
+		call.err = string(readMemory(m, ptr, size))
+	}).Export("set_error").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, level, ptr, size uint32) {
+		call := ctx.Value(pluginCallKey{}).(*pluginCall)
+		message := string(readMemory(m, ptr, size))
+		switch level {
+		case 0:
+			call.logger.Debug(message)
+		case 2:
+			call.logger.Warn(message)
+		case 3:
+			call.logger.Error(message)
+		default:
+			call.logger.Info(message)
+		}
+	}).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		return fmt.Errorf("instantiate host module: %v", err)
+	}
+	return nil
+}
+
+func readMemory(m api.Module, ptr, size uint32) []byte {
+	data, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		panic(fmt.Errorf("out of bounds memory access [%d, %d)", ptr, ptr+size))
+	}
+	return append([]byte(nil), data...)
+}
+
+// Reload загружает новые и измененные модули. Ошибка одного модуля
+// логируется и не мешает остальным: он продолжает работать в предыдущей
+// версии. Ошибка возвращается, только если каталог не читается.
+func (p *Plugins) Reload() error {
+	entries, err := os.ReadDir(p.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("read plugins dir: %v", err)
+	}
+
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		taskType, isPlugin := strings.CutSuffix(entry.Name(), pluginExt)
+		if !isPlugin || entry.IsDir() || taskType == "" {
+			continue
+		}
+		present[taskType] = true
+		info, err := entry.Info()
+		if err != nil {
+			slog.Error("Plugin load failed", "task_type", taskType, "error", err)
+			continue
+		}
+		p.mu.RLock()
+		current := p.plugins[taskType]
+		failedAt, failed := p.failed[taskType]
+		p.mu.RUnlock()
+		if current != nil && current.modTime.Equal(info.ModTime()) && current.size == info.Size() ||
+			failed && failedAt.Equal(info.ModTime()) {
+			continue
+		}
+		if err := p.load(taskType, filepath.Join(p.cfg.Dir, entry.Name()), info); err != nil {
+			p.mu.Lock()
+			p.failed[taskType] = info.ModTime()
+			p.mu.Unlock()
+			slog.Error("Plugin load failed", "task_type", taskType, "error", err)
+		}
+	}
+
+	p.mu.Lock()
+	for taskType := range p.failed {
+		if !present[taskType] {
+			delete(p.failed, taskType)
+		}
+	}
+	for taskType, old := range p.plugins {
+		if !present[taskType] {
+			delete(p.plugins, taskType)
+			go p.release(old)
+			slog.Info("Plugin unloaded", "task_type", taskType)
+		}
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *Plugins) load(taskType, file string, info os.FileInfo) error {
+	if reservedPluginTypes[taskType] {
+		return fmt.Errorf("task type %q is reserved for built-in handler", taskType)
+	}
+	binary, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	compiled, err := p.runtime.CompileModule(ctx, binary)
+	if err != nil {
+		return err
+	}
+	if err := validatePlugin(compiled); err != nil {
+		compiled.Close(ctx)
+		return err
+	}
+
+	p.mu.Lock()
+	old := p.plugins[taskType]
+	p.plugins[taskType] = &plugin{compiled: compiled, modTime: info.ModTime(), size: info.Size()}
+	delete(p.failed, taskType)
+	register := !p.registered[taskType]
+	p.registered[taskType] = true
+	p.mu.Unlock()
+
+	if old != nil {
+		go p.release(old)
+	}
+	if register {
+		p.registry.RegisterHandler(taskType, p.handler(taskType))
+	}
+	slog.Info("Plugin loaded", "task_type", taskType, "file", file, "reloaded", old != nil)
+	return nil
+}
+
+// release закрывает замененный модуль после завершения его попыток
+func (p *Plugins) release(old *plugin) {
+	old.active.Wait()
+	old.compiled.Close(context.Background())
+}
+
+func validatePlugin(compiled wazero.CompiledModule) error {
+	if _, exists := compiled.ExportedMemories()["memory"]; !exists {
+		return errors.New(`module must export "memory"`)
+	}
+	i32 := api.ValueTypeI32
+	for name, signature := range map[string][2][]api.ValueType{
+		"alloc":  {{i32}, {i32}},
+		"handle": {{i32, i32}, {i32}},
+	} {
+		fn, exists := compiled.ExportedFunctions()[name]
+		if !exists {
+			return fmt.Errorf("module must export %q", name)
+		}
+		if !equalTypes(fn.ParamTypes(), signature[0]) || !equalTypes(fn.ResultTypes(), signature[1]) {
+			return fmt.Errorf("%s must have signature (%s) -> (%s)", name,
+				typeNames(signature[0]), typeNames(signature[1]))
+		}
+	}
+	return nil
+}
+
+func equalTypes(a, b []api.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func typeNames(types []api.ValueType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = api.ValueTypeName(t)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Types возвращает типы задач загруженных модулей
+func (p *Plugins) Types() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	types := make([]string, 0, len(p.plugins))
+	for taskType := range p.plugins {
+		types = append(types, taskType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// acquire возвращает текущую версию модуля и отмечает попытку
+func (p *Plugins) acquire(taskType string) *plugin {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	current := p.plugins[taskType]
+	if current != nil {
+		current.active.Add(1)
+	}
+	return current
+}
+
+func (p *Plugins) handler(taskType string) queue.Handler {
+	return func(ctx context.Context, task *model.Task) error {
+		current := p.acquire(taskType)
+		if current == nil {
+			// Модуль удален: задача ждет его возвращения в пределах повторов
+			return fmt.Errorf("plugin %s is not loaded", taskType)
+		}
+		defer current.active.Done()
+		return p.run(ctx, current, taskType, task)
+	}
+}
+
+func (p *Plugins) run(ctx context.Context, current *plugin, taskType string, task *model.Task) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	logger := queue.LoggerFromContext(ctx)
+	stdout := newLineWriter(logger, "stdout", defaultMaxOutputSize)
+	stderr := newLineWriter(logger, "stderr", defaultMaxOutputSize)
+	defer stdout.Close()
+	defer stderr.Close()
+
+	call := &pluginCall{logger: logger}
+	ctx = context.WithValue(ctx, pluginCallKey{}, call)
+
+	module, err := p.runtime.InstantiateModule(ctx, current.compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStdout(stdout).
+		WithStderr(stderr))
+	if err != nil {
+		return p.callError(ctx, taskType, "instantiate", err)
+	}
+	defer module.Close(context.Background())
+
+	payload := task.PayloadBytes()
+	results, err := module.ExportedFunction("alloc").Call(ctx, uint64(len(payload)))
+	if err != nil {
+		return p.callError(ctx, taskType, "alloc", err)
+	}
+	ptr := uint32(results[0])
+	if !module.Memory().Write(ptr, payload) {
+		return fmt.Errorf("plugin %s: alloc returned out of bounds pointer %d", taskType, ptr)
+	}
+	results, err = module.ExportedFunction("handle").Call(ctx, uint64(ptr), uint64(len(payload)))
+	if err != nil {
+		return p.callError(ctx, taskType, "handle", err)
+	}
+
+	if call.result != nil {
+		var result any = string(call.result)
+		if json.Valid(call.result) {
+			result = json.RawMessage(call.result)
+		}
+		if err := queue.ReporterFromContext(ctx).Result(result); err != nil {
+			logger.Warn("Failed to save plugin result", "error", err)
+		}
+	}
+
+	code := int32(results[0])
+	message := call.err
+	if message == "" {
+		message = fmt.Sprintf("plugin %s returned code %d", taskType, code)
+	}
+	switch code {
+	case pluginOK:
+		return nil
+	case pluginPermanent:
+		return queue.Permanent(errors.New(message))
+	}
+	return errors.New(message)
+}
+
+func (p *Plugins) callError(ctx context.Context, taskType, function string, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("plugin %s timed out after %s", taskType, p.cfg.Timeout)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	}
+	return fmt.Errorf("plugin %s: %s: %v", taskType, function, err)
+}
+
+// Watch проверяет каталог раз в interval и перезагружает измененные модули
+func (p *Plugins) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := p.Reload(); err != nil {
+				slog.Error("Plugin reload failed", "error", err)
+			}
+		}
+	}
+}
+
+func (p *Plugins) Close() error {
+	return p.runtime.Close(context.Background())
+}
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+type RetryStrategy string
+
+const (
+	RetryExponential        RetryStrategy = "exponential"
+	RetryLinear             RetryStrategy = "linear"
+	RetryFixed              RetryStrategy = "fixed"
+	RetryDecorrelatedJitter RetryStrategy = "decorrelated_jitter"
+)
+
+// Duration в JSON задается строкой ("1s", "5m") или числом секунд
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+type RetryPolicy struct {
+	Strategy  RetryStrategy `json:"strategy"`
+	BaseDelay Duration      `json:"base_delay"`
+	// 0 - без ограничения
+	MaxDelay   Duration `json:"max_delay,omitempty"`
+	MaxElapsed Duration `json:"max_elapsed,omitempty"`
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Strategy:  RetryExponential,
+		BaseDelay: Duration(time.Second),
+		MaxDelay:  Duration(5 * time.Minute),
+	}
+}
+
+func (p RetryPolicy) Validate() error {
+	switch p.Strategy {
+	case RetryExponential, RetryLinear, RetryFixed, RetryDecorrelatedJitter:
+	default:
+		return fmt.Errorf("unknown retry strategy %q", p.Strategy)
+	}
+	if p.BaseDelay <= 0 {
+		return fmt.Errorf("base_delay must be positive")
+	}
+	if p.MaxDelay < 0 || p.MaxElapsed < 0 {
+		return fmt.Errorf("max_delay and max_elapsed must not be negative")
+	}
+	if p.MaxDelay > 0 && p.MaxDelay < p.BaseDelay {
+		return fmt.Errorf("max_delay must not be less than base_delay")
+	}
+	return nil
+}
+
+// NextDelay возвращает задержку перед повтором. retries - число уже
+// неудачных попыток (от 1), previous - предыдущая задержка.
+func (p RetryPolicy) NextDelay(retries int, previous time.Duration) time.Duration {
+	base := time.Duration(p.BaseDelay)
+	// Без MaxDelay задержка насыщается на максимуме Duration вместо переполнения
+	limit := time.Duration(math.MaxInt64)
+	if p.MaxDelay > 0 {
+		limit = time.Duration(p.MaxDelay)
+	}
+	if base >= limit {
+		return limit
+	}
+	var delay time.Duration
+
+	switch p.Strategy {
+	case RetryLinear:
+		if retries > 0 && base > limit/time.Duration(retries) {
+			return limit
+		}
+		delay = base * time.Duration(retries)
+	case RetryFixed:
+		delay = base
+	case RetryDecorrelatedJitter:
+		// sleep = random_between(base, previous * 3)
+		if previous < base {
+			previous = base
+		}
+		upper := limit
+		if previous <= limit/3 {
+			upper = previous * 3
+		}
+		span := int64(upper - base)
+		if span < math.MaxInt64 {
+			span++
+		}
+		delay = base + time.Duration(rand.Int63n(span))
+	default:
+		// base * 2^retries плюс до 50% джиттера
+		shift := retries
+		if shift > 30 {
+			shift = 30
+		}
+		if base > limit>>uint(shift) {
+			return limit
+		}
+		delay = base * time.Duration(1<<uint(shift))
+		if half := int64(delay / 2); half > 0 {
+			jitter := time.Duration(rand.Int63n(half))
+			if jitter > limit-delay {
+				return limit
+			}
+			delay += jitter
+		}
+	}
+
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// ParseRetryPolicy разбирает "strategy:base[:max_delay[:max_elapsed]]"
+func ParseRetryPolicy(spec string) (RetryPolicy, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 4 {
+		return RetryPolicy{}, fmt.Errorf("invalid retry policy %q", spec)
+	}
+
+	policy := RetryPolicy{Strategy: RetryStrategy(parts[0])}
+	durations := []*Duration{&policy.BaseDelay, &policy.MaxDelay, &policy.MaxElapsed}
+	for i, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			return RetryPolicy{}, fmt.Errorf("invalid retry policy %q: %v", spec, err)
+		}
+		*durations[i] = Duration(d)
+	}
+	return policy, policy.Validate()
+}
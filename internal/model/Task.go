@@ -1,20 +1,98 @@
 package model
 
-import "sync"
+import (
+	"encoding/json"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultQueue  = "default"
+	DefaultType   = "default"
+	DefaultTenant = "default"
+	// Тип содержимого payload по умолчанию
+	ContentTypeJSON = "application/json"
+)
 
 type Task struct {
-	ID         string `json:"id"`
-	Payload    string `json:"payload"`
-	MaxRetries int    `json:"max_retries"`
-	Retries    int    `json:"-"`
-	Status     string `json:"status"`
-	mu         sync.Mutex
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Queue  string `json:"queue"`
+	Tenant string `json:"tenant,omitempty"`
+	// Любое JSON значение. При типе содержимого, отличном от JSON (text/plain
+	// и т.п.), payload - JSON строка с телом
+	Payload     json.RawMessage `json:"payload"`
+	ContentType string          `json:"content_type,omitempty"`
+	// Ключ circuit breaker (внешняя зависимость), по умолчанию - тип задачи
+	Resource string `json:"resource,omitempty"`
+	// Ключ шардирования, по умолчанию - очередь
+	PartitionKey string `json:"partition_key,omitempty"`
+	MaxRetries   int    `json:"max_retries"`
+	Retries      int    `json:"-"`
+	Status       string `json:"status"`
+	RequestID    string `json:"request_id,omitempty"`
+	// W3C trace context (traceparent, tracestate)
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	// Переопределяет политику повторов очереди
+	RetryPolicy    *RetryPolicy  `json:"retry_policy,omitempty"`
+	LastRetryDelay time.Duration `json:"-"`
+	CreatedAt      time.Time     `json:"created_at"`
+	EnqueuedAt     time.Time     `json:"enqueued_at"`
+	FinishedAt     time.Time     `json:"-"`
+	// Время следующей попытки, пока задача ждет повтора
+	RetryAt time.Time `json:"-"`
+	// Поколение постановки в очередь: растет при отмене и повторном запуске,
+	// отложенные возвраты прежнего поколения отбрасываются
+	Generation uint64 `json:"-"`
+	// Аренда удаленным воркером; LeasePrincipal - ключ, которым она получена
+	LeaseOwner     string    `json:"-"`
+	LeasePrincipal string    `json:"-"`
+	LeaseExpiresAt time.Time `json:"-"`
+	// Последний отчет о прогрессе и чекпоинт, переживающий повторы
+	Progress   *TaskProgress   `json:"-"`
+	Checkpoint json.RawMessage `json:"-"`
+	// Результат последней попытки, который сохранил обработчик
+	Result json.RawMessage `json:"-"`
+	// Ошибки последних попыток и счетчики паник и аварий воркеров. Клиент
+	// их не задает, наружу они отдаются только через TaskSnapshot
+	AttemptErrors []AttemptError `json:"-"`
+	Panics        int            `json:"-"`
+	Crashes       int            `json:"-"`
+	mu            sync.Mutex
+}
+
+// MaxAttemptErrors - сколько последних ошибок попыток хранится в задаче
+const MaxAttemptErrors = 20
+
+type AttemptError struct {
+	Attempt int       `json:"attempt"`
+	Error   string    `json:"error"`
+	Stack   string    `json:"stack,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+type TaskProgress struct {
+	Percent   float64   `json:"percent"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsTerminalStatus - задача больше не будет выполняться
+func IsTerminalStatus(status string) bool {
+	return status == "done" || status == "failed" || status == "cancelled"
 }
 
 func (t *Task) SetStatus(status string) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.Status = status
+	if IsTerminalStatus(status) {
+		t.FinishedAt = time.Now()
+	} else {
+		t.FinishedAt = time.Time{}
+	}
 }
 
 func (t *Task) IncrementRetries() int {
@@ -24,6 +102,49 @@ func (t *Task) IncrementRetries() int {
 	return t.Retries
 }
 
+// ResetForReplay начинает выполнение заново: счетчик попыток, прогресс
+// и результат сбрасываются, чекпоинт сохраняется
+func (t *Task) ResetForReplay() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Retries = 0
+	t.LastRetryDelay = 0
+	t.Progress = nil
+	t.Result = nil
+}
+
+func (t *Task) RecordAttemptError(attemptError AttemptError) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.AttemptErrors = append(t.AttemptErrors, attemptError)
+	if extra := len(t.AttemptErrors) - MaxAttemptErrors; extra > 0 {
+		t.AttemptErrors = append([]AttemptError(nil), t.AttemptErrors[extra:]...)
+	}
+}
+
+// RecordPanic и RecordCrash возвращают общее число паник и аварий
+func (t *Task) RecordPanic() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Panics++
+	return t.Panics + t.Crashes
+}
+
+func (t *Task) RecordCrash() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Crashes++
+	return t.Panics + t.Crashes
+}
+
+// ResetPoison сбрасывает счетчики после ручного освобождения из карантина
+func (t *Task) ResetPoison() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Panics = 0
+	t.Crashes = 0
+}
+
 func (t *Task) GetStatus() string {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -35,3 +156,254 @@ func (t *Task) GetRetries() int {
 	defer t.mu.Unlock()
 	return t.Retries
 }
+
+func (t *Task) MarkEnqueued() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.EnqueuedAt = time.Now()
+	t.RetryAt = time.Time{}
+}
+
+func (t *Task) SetRetryAt(retryAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.RetryAt = retryAt
+}
+
+func (t *Task) GetRetryAt() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.RetryAt
+}
+
+func (t *Task) SetLastRetryDelay(delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.LastRetryDelay = delay
+}
+
+func (t *Task) GetLastRetryDelay() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.LastRetryDelay
+}
+
+func (t *Task) GetGeneration() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Generation
+}
+
+func (t *Task) NextGeneration() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Generation++
+	return t.Generation
+}
+
+func (t *Task) GetEnqueuedAt() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.EnqueuedAt
+}
+
+func (t *Task) GetFinishedAt() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.FinishedAt
+}
+
+// IsFinishedAt проверяет, что задача все еще завершена в момент finishedAt
+func (t *Task) IsFinishedAt(finishedAt time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return IsTerminalStatus(t.Status) && t.FinishedAt.Equal(finishedAt)
+}
+
+func (t *Task) SetProgress(percent float64, message string) TaskProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	progress := TaskProgress{Percent: percent, Message: message, UpdatedAt: time.Now()}
+	t.Progress = &progress
+	return progress
+}
+
+func (t *Task) GetProgress() (TaskProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Progress == nil {
+		return TaskProgress{}, false
+	}
+	return *t.Progress, true
+}
+
+func (t *Task) SetCheckpoint(data json.RawMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Checkpoint = append(json.RawMessage(nil), data...)
+}
+
+func (t *Task) GetCheckpoint() json.RawMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append(json.RawMessage(nil), t.Checkpoint...)
+}
+
+func (t *Task) SetResult(data json.RawMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Result = append(json.RawMessage(nil), data...)
+}
+
+func (t *Task) GetResult() json.RawMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append(json.RawMessage(nil), t.Result...)
+}
+
+// IsJSONContentType сообщает, что payload с этим типом содержимого хранится как есть
+func IsJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json"))
+}
+
+// TextPayload кодирует тело не-JSON типа содержимого в payload
+func TextPayload(body string) json.RawMessage {
+	payload, _ := json.Marshal(body)
+	return payload
+}
+
+// PayloadBody возвращает тело payload: JSON как есть или значение строки для
+// других типов содержимого
+func PayloadBody(payload json.RawMessage, contentType string) []byte {
+	if !IsJSONContentType(contentType) {
+		var body string
+		if json.Unmarshal(payload, &body) == nil {
+			return []byte(body)
+		}
+	}
+	return payload
+}
+
+func (t *Task) PayloadBytes() []byte {
+	return PayloadBody(t.Payload, t.ContentType)
+}
+
+// TaskSnapshot - согласованная копия состояния задачи для выдачи и архивации
+type TaskSnapshot struct {
+	ID           string            `json:"id"`
+	Type         string            `json:"type"`
+	Queue        string            `json:"queue"`
+	Tenant       string            `json:"tenant,omitempty"`
+	Payload      json.RawMessage   `json:"payload"`
+	ContentType  string            `json:"content_type,omitempty"`
+	Resource     string            `json:"resource,omitempty"`
+	PartitionKey string            `json:"partition_key,omitempty"`
+	MaxRetries   int               `json:"max_retries"`
+	Retries      int               `json:"retries"`
+	Status       string            `json:"status"`
+	RequestID    string            `json:"request_id,omitempty"`
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	RetryPolicy  *RetryPolicy      `json:"retry_policy,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	EnqueuedAt   time.Time         `json:"enqueued_at"`
+	FinishedAt   *time.Time        `json:"finished_at,omitempty"`
+	RetryAt      *time.Time        `json:"retry_at,omitempty"`
+	Progress     *TaskProgress     `json:"progress,omitempty"`
+	Checkpoint   json.RawMessage   `json:"checkpoint,omitempty"`
+	Result       json.RawMessage   `json:"result,omitempty"`
+	// Задержка последнего повтора, от нее считается следующая
+	LastRetryDelay Duration `json:"last_retry_delay,omitempty"`
+	// Ошибки попыток, паники и аварии воркеров
+	AttemptErrors []AttemptError `json:"attempt_errors,omitempty"`
+	Panics        int            `json:"panics,omitempty"`
+	Crashes       int            `json:"crashes,omitempty"`
+}
+
+func (t *Task) Snapshot() TaskSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := TaskSnapshot{
+		ID:             t.ID,
+		Type:           t.Type,
+		Queue:          t.Queue,
+		Tenant:         t.Tenant,
+		Payload:        t.Payload,
+		ContentType:    t.ContentType,
+		Resource:       t.Resource,
+		PartitionKey:   t.PartitionKey,
+		MaxRetries:     t.MaxRetries,
+		Retries:        t.Retries,
+		Status:         t.Status,
+		RequestID:      t.RequestID,
+		TraceContext:   t.TraceContext,
+		RetryPolicy:    t.RetryPolicy,
+		CreatedAt:      t.CreatedAt,
+		EnqueuedAt:     t.EnqueuedAt,
+		Panics:         t.Panics,
+		Crashes:        t.Crashes,
+		LastRetryDelay: Duration(t.LastRetryDelay),
+	}
+	if len(t.AttemptErrors) > 0 {
+		snapshot.AttemptErrors = append([]AttemptError(nil), t.AttemptErrors...)
+	}
+	if !t.FinishedAt.IsZero() {
+		finishedAt := t.FinishedAt
+		snapshot.FinishedAt = &finishedAt
+	}
+	if !t.RetryAt.IsZero() {
+		retryAt := t.RetryAt
+		snapshot.RetryAt = &retryAt
+	}
+	if t.Progress != nil {
+		progress := *t.Progress
+		snapshot.Progress = &progress
+	}
+	if len(t.Checkpoint) > 0 {
+		snapshot.Checkpoint = append(json.RawMessage(nil), t.Checkpoint...)
+	}
+	if len(t.Result) > 0 {
+		snapshot.Result = append(json.RawMessage(nil), t.Result...)
+	}
+	return snapshot
+}
+
+// TaskFromSnapshot восстанавливает задачу из снимка (импорт). Аренда не переносится.
+func TaskFromSnapshot(s TaskSnapshot) *Task {
+	task := &Task{
+		ID:             s.ID,
+		Type:           s.Type,
+		Queue:          s.Queue,
+		Tenant:         s.Tenant,
+		Payload:        s.Payload,
+		ContentType:    s.ContentType,
+		Resource:       s.Resource,
+		PartitionKey:   s.PartitionKey,
+		MaxRetries:     s.MaxRetries,
+		Retries:        s.Retries,
+		Status:         s.Status,
+		RequestID:      s.RequestID,
+		TraceContext:   s.TraceContext,
+		RetryPolicy:    s.RetryPolicy,
+		LastRetryDelay: time.Duration(s.LastRetryDelay),
+		CreatedAt:      s.CreatedAt,
+		EnqueuedAt:     s.EnqueuedAt,
+		Progress:       s.Progress,
+		Checkpoint:     s.Checkpoint,
+		Result:         s.Result,
+		AttemptErrors:  s.AttemptErrors,
+		Panics:         s.Panics,
+		Crashes:        s.Crashes,
+	}
+	if s.FinishedAt != nil {
+		task.FinishedAt = *s.FinishedAt
+	}
+	if s.RetryAt != nil {
+		task.RetryAt = *s.RetryAt
+	}
+	return task
+}
@@ -0,0 +1,18 @@
+package raft
+
+import "TaskQueue/internal/metrics"
+
+var (
+	raftRole = metrics.Default.Gauge("taskqueue_raft_role",
+		"Raft role of the node: 0 follower, 1 candidate, 2 leader.", "node")
+	raftTerm = metrics.Default.Gauge("taskqueue_raft_term",
+		"Current raft term.", "node")
+	raftLastIndex = metrics.Default.Gauge("taskqueue_raft_last_index",
+		"Index of the last entry in the raft log.", "node")
+	raftCommitIndex = metrics.Default.Gauge("taskqueue_raft_commit_index",
+		"Index of the last committed raft entry.", "node")
+	raftSnapshotIndex = metrics.Default.Gauge("taskqueue_raft_snapshot_index",
+		"Index of the last raft entry included in the snapshot.", "node")
+	raftElections = metrics.Default.Counter("taskqueue_raft_elections_total",
+		"Elections started by the node.", "node")
+)
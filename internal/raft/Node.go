@@ -0,0 +1,837 @@
+// Package raft - минимальная реализация Raft (выборы лидера, репликация лога
+// и снапшоты) поверх HTTP/JSON. Состав кластера статический.
+package raft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotLeader      = errors.New("not the raft leader")
+	ErrLeadershipLost = errors.New("leadership lost before commit")
+	ErrStopped        = errors.New("raft node stopped")
+)
+
+// Максимум записей в одном AppendEntries
+const maxBatch = 256
+
+// DefaultSnapshotEntries - сколько примененных записей накапливается до снапшота
+const DefaultSnapshotEntries = 4096
+
+type Role int
+
+const (
+	Follower Role = iota
+	Candidate
+	Leader
+)
+
+func (r Role) String() string {
+	switch r {
+	case Candidate:
+		return "candidate"
+	case Leader:
+		return "leader"
+	}
+	return "follower"
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+type Entry struct {
+	Index int64 `json:"index"`
+	Term  int64 `json:"term"`
+	// Пустая команда - no-op, которым новый лидер фиксирует записи прошлых сроков
+	Command json.RawMessage `json:"command,omitempty"`
+}
+
+// FSM получает закоммиченные записи по порядку из одной горутины
+type FSM interface {
+	Apply(entry Entry)
+	// LeadershipChanged: leader=true - после применения всех записей прошлых сроков,
+	// leader=false - после потери лидерства
+	LeadershipChanged(leader bool, term int64)
+	// Snapshot - состояние после всех примененных записей; после него
+	// примененная часть лога удаляется
+	Snapshot() (json.RawMessage, error)
+	// Restore заменяет состояние снапшотом (при старте и от лидера)
+	Restore(snapshot json.RawMessage) error
+}
+
+// Snapshot - состояние FSM после записи Index
+type Snapshot struct {
+	Index int64           `json:"index"`
+	Term  int64           `json:"term"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+type Config struct {
+	ID string
+	// Базовые URL всех узлов кластера, включая этот: id -> http://host:port
+	Peers map[string]string
+	// Каталог для лога и состояния; пустой - только в памяти
+	DataDir string
+	// Таймаут выборов случайный в [ElectionTimeout, 2*ElectionTimeout)
+	ElectionTimeout   time.Duration
+	HeartbeatInterval time.Duration
+	// Общий секрет для RPC между узлами (заголовок X-Raft-Secret), обязателен
+	Secret string
+	// Примененных записей до снапшота и усечения лога, по умолчанию DefaultSnapshotEntries
+	SnapshotEntries int
+	Client          *http.Client
+}
+
+type waiter struct {
+	term int64
+	done chan error
+}
+
+type Node struct {
+	cfg     Config
+	client  *http.Client
+	storage *storage
+
+	mu       sync.Mutex
+	fsm      FSM
+	role     Role
+	term     int64
+	votedFor string
+	leaderID string
+	// log[0] - граница снапшота (Index и Term последней вошедшей в него записи)
+	log         []Entry
+	snapshot    Snapshot
+	commitIndex int64
+	lastApplied int64
+	deadline    time.Time
+
+	nextIndex  map[string]int64
+	matchIndex map[string]int64
+	waiters    map[int64]waiter
+
+	// Индекс no-op текущего лидерства и признак, что о лидерстве уже сообщено FSM
+	leaderNoop    int64
+	announced     bool
+	pendingDemote bool
+	// Снапшот от лидера, который applier должен передать FSM
+	pendingRestore *Snapshot
+
+	applyCh   chan struct{}
+	replicate map[string]chan struct{}
+	stop      chan struct{}
+	stopOnce  sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewNode(cfg Config) (*Node, error) {
+	if _, exists := cfg.Peers[cfg.ID]; !exists {
+		return nil, fmt.Errorf("node %s is not listed in peers", cfg.ID)
+	}
+	// RPC изменяют реплицируемое состояние: без секрета узел не запускается
+	if cfg.Secret == "" {
+		return nil, errors.New("raft secret is required")
+	}
+	if cfg.SnapshotEntries <= 0 {
+		cfg.SnapshotEntries = DefaultSnapshotEntries
+	}
+	if cfg.ElectionTimeout <= 0 {
+		cfg.ElectionTimeout = time.Second
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = cfg.ElectionTimeout / 5
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.ElectionTimeout}
+	}
+
+	n := &Node{
+		cfg:        cfg,
+		client:     client,
+		log:        []Entry{{}},
+		nextIndex:  make(map[string]int64),
+		matchIndex: make(map[string]int64),
+		waiters:    make(map[int64]waiter),
+		applyCh:    make(chan struct{}, 1),
+		replicate:  make(map[string]chan struct{}),
+		stop:       make(chan struct{}),
+	}
+	for id := range cfg.Peers {
+		if id != cfg.ID {
+			n.replicate[id] = make(chan struct{}, 1)
+		}
+	}
+
+	if cfg.DataDir != "" {
+		storage, state, snapshot, entries, err := openStorage(cfg.DataDir)
+		if err != nil {
+			return nil, err
+		}
+		n.storage = storage
+		n.term = state.Term
+		n.votedFor = state.VotedFor
+		n.snapshot = snapshot
+		n.log = append([]Entry{{Index: snapshot.Index, Term: snapshot.Term}}, entries...)
+	}
+	return n, nil
+}
+
+func (n *Node) ID() string {
+	return n.cfg.ID
+}
+
+// Start восстанавливает fsm из снапшота и запускает выборы, репликацию
+// и применение записей
+func (n *Node) Start(fsm FSM) {
+	n.mu.Lock()
+	n.fsm = fsm
+	if n.snapshot.Index > 0 {
+		if err := fsm.Restore(n.snapshot.Data); err != nil {
+			slog.Error("Failed to restore raft snapshot", "node", n.cfg.ID, "index", n.snapshot.Index, "error", err)
+		}
+		n.commitIndex = n.snapshot.Index
+		n.lastApplied = n.snapshot.Index
+	}
+	n.resetDeadline()
+	term, lastIndex := n.term, n.lastIndex()
+	n.mu.Unlock()
+
+	n.wg.Add(2)
+	go n.ticker()
+	go n.applier()
+	for id := range n.replicate {
+		n.wg.Add(1)
+		go n.replicator(id)
+	}
+	slog.Info("Raft node started", "node", n.cfg.ID, "term", term, "last_index", lastIndex)
+}
+
+func (n *Node) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.stop)
+		n.wg.Wait()
+
+		n.mu.Lock()
+		for index, w := range n.waiters {
+			w.done <- ErrStopped
+			delete(n.waiters, index)
+		}
+		n.mu.Unlock()
+		if n.storage != nil {
+			n.storage.close()
+		}
+	})
+}
+
+// Propose добавляет команду в лог и ждет ее коммита и применения на этом узле
+func (n *Node) Propose(ctx context.Context, command []byte) error {
+	n.mu.Lock()
+	if n.role != Leader {
+		n.mu.Unlock()
+		return ErrNotLeader
+	}
+	entry := Entry{Index: n.lastIndex() + 1, Term: n.term, Command: command}
+	if err := n.appendLocked(entry); err != nil {
+		n.mu.Unlock()
+		return err
+	}
+	done := make(chan error, 1)
+	n.waiters[entry.Index] = waiter{term: entry.Term, done: done}
+	n.advanceCommit()
+	n.mu.Unlock()
+	n.notifyReplicators()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		n.mu.Lock()
+		delete(n.waiters, entry.Index)
+		n.mu.Unlock()
+		return ctx.Err()
+	case <-n.stop:
+		return ErrStopped
+	}
+}
+
+type Status struct {
+	ID            string `json:"id"`
+	Role          Role   `json:"role"`
+	Term          int64  `json:"term"`
+	Leader        string `json:"leader,omitempty"`
+	LeaderAddr    string `json:"leader_addr,omitempty"`
+	LastIndex     int64  `json:"last_index"`
+	CommitIndex   int64  `json:"commit_index"`
+	LastApplied   int64  `json:"last_applied"`
+	SnapshotIndex int64  `json:"snapshot_index"`
+}
+
+func (n *Node) Status() Status {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return Status{
+		ID:            n.cfg.ID,
+		Role:          n.role,
+		Term:          n.term,
+		Leader:        n.leaderID,
+		LeaderAddr:    n.cfg.Peers[n.leaderID],
+		LastIndex:     n.lastIndex(),
+		CommitIndex:   n.commitIndex,
+		LastApplied:   n.lastApplied,
+		SnapshotIndex: n.firstIndex(),
+	}
+}
+
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.role == Leader
+}
+
+// Leader возвращает id и адрес известного лидера
+func (n *Node) Leader() (string, string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.leaderID, n.cfg.Peers[n.leaderID]
+}
+
+// Вызываются под mu
+
+// firstIndex - индекс последней записи снапшота; записи до него удалены
+func (n *Node) firstIndex() int64 {
+	return n.log[0].Index
+}
+
+func (n *Node) entry(index int64) Entry {
+	return n.log[index-n.firstIndex()]
+}
+
+func (n *Node) lastIndex() int64 {
+	return n.log[len(n.log)-1].Index
+}
+
+func (n *Node) lastTerm() int64 {
+	return n.log[len(n.log)-1].Term
+}
+
+func (n *Node) quorum() int {
+	return len(n.cfg.Peers)/2 + 1
+}
+
+func (n *Node) resetDeadline() {
+	timeout := n.cfg.ElectionTimeout + time.Duration(rand.Int63n(int64(n.cfg.ElectionTimeout)))
+	n.deadline = time.Now().Add(timeout)
+}
+
+func (n *Node) appendLocked(entries ...Entry) error {
+	if n.storage != nil {
+		if err := n.storage.append(entries); err != nil {
+			return err
+		}
+	}
+	n.log = append(n.log, entries...)
+	raftLastIndex.Set(float64(n.lastIndex()), n.cfg.ID)
+	return nil
+}
+
+func (n *Node) persistState() {
+	if n.storage == nil {
+		return
+	}
+	if err := n.storage.saveState(persistentState{Term: n.term, VotedFor: n.votedFor}); err != nil {
+		slog.Error("Failed to persist raft state", "node", n.cfg.ID, "error", err)
+	}
+}
+
+func (n *Node) setRole(role Role) {
+	n.role = role
+	raftRole.Set(float64(role), n.cfg.ID)
+	raftTerm.Set(float64(n.term), n.cfg.ID)
+}
+
+// stepDown переводит узел в follower, при большем сроке - сбрасывает голос
+func (n *Node) stepDown(term int64) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.persistState()
+	}
+	if n.role == Leader {
+		slog.Info("Raft leadership lost", "node", n.cfg.ID, "term", n.term)
+	}
+	if n.announced {
+		n.announced = false
+		n.pendingDemote = true
+		n.signalApply()
+	}
+	n.setRole(Follower)
+}
+
+func (n *Node) signalApply() {
+	select {
+	case n.applyCh <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Node) notifyReplicators() {
+	for _, ch := range n.replicate {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ticker следит за таймаутом выборов
+func (n *Node) ticker() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.cfg.HeartbeatInterval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+		}
+		n.mu.Lock()
+		if n.role != Leader && time.Now().After(n.deadline) {
+			n.startElection()
+		}
+		n.mu.Unlock()
+	}
+}
+
+// startElection вызывается под mu
+func (n *Node) startElection() {
+	n.term++
+	n.votedFor = n.cfg.ID
+	n.leaderID = ""
+	n.persistState()
+	n.setRole(Candidate)
+	n.resetDeadline()
+	raftElections.Inc(n.cfg.ID)
+	slog.Debug("Raft election started", "node", n.cfg.ID, "term", n.term)
+
+	votes := 1
+	if votes >= n.quorum() {
+		n.becomeLeader()
+		return
+	}
+
+	req := voteRequest{
+		Term:         n.term,
+		CandidateID:  n.cfg.ID,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.lastTerm(),
+	}
+	for id := range n.replicate {
+		go func(peer string) {
+			var resp voteResponse
+			if err := n.call(peer, "/raft/vote", req, &resp); err != nil {
+				return
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if resp.Term > n.term {
+				n.stepDown(resp.Term)
+				return
+			}
+			if n.role != Candidate || n.term != req.Term || !resp.VoteGranted {
+				return
+			}
+			votes++
+			if votes >= n.quorum() {
+				n.becomeLeader()
+			}
+		}(id)
+	}
+}
+
+// becomeLeader вызывается под mu
+func (n *Node) becomeLeader() {
+	n.setRole(Leader)
+	n.leaderID = n.cfg.ID
+	for id := range n.replicate {
+		n.nextIndex[id] = n.lastIndex() + 1
+		n.matchIndex[id] = 0
+	}
+	noop := Entry{Index: n.lastIndex() + 1, Term: n.term}
+	if err := n.appendLocked(noop); err != nil {
+		slog.Error("Failed to append raft no-op", "node", n.cfg.ID, "error", err)
+		n.stepDown(n.term)
+		return
+	}
+	n.leaderNoop = noop.Index
+	slog.Info("Raft leader elected", "node", n.cfg.ID, "term", n.term)
+	n.advanceCommit()
+	n.notifyReplicators()
+}
+
+// advanceCommit коммитит записи текущего срока, реплицированные на большинство.
+// Вызывается под mu.
+func (n *Node) advanceCommit() {
+	if n.role != Leader {
+		return
+	}
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		if n.entry(index).Term != n.term {
+			break
+		}
+		replicas := 1
+		for _, match := range n.matchIndex {
+			if match >= index {
+				replicas++
+			}
+		}
+		if replicas >= n.quorum() {
+			n.commitIndex = index
+			raftCommitIndex.Set(float64(index), n.cfg.ID)
+			n.signalApply()
+			return
+		}
+	}
+}
+
+// replicator отправляет записи и heartbeat одному узлу
+func (n *Node) replicator(peer string) {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-n.replicate[peer]:
+		case <-ticker.C:
+		}
+		for n.replicateTo(peer) {
+		}
+	}
+}
+
+// replicateTo отправляет один AppendEntries. true - есть что отправить еще.
+func (n *Node) replicateTo(peer string) bool {
+	n.mu.Lock()
+	if n.role != Leader {
+		n.mu.Unlock()
+		return false
+	}
+	next := n.nextIndex[peer]
+	if next < 1 {
+		next = 1
+	}
+	if next <= n.firstIndex() {
+		// Нужные узлу записи уже в снапшоте
+		n.mu.Unlock()
+		return n.sendSnapshot(peer)
+	}
+	last := n.lastIndex()
+	if last-next+1 > maxBatch {
+		last = next + maxBatch - 1
+	}
+	req := appendRequest{
+		Term:         n.term,
+		LeaderID:     n.cfg.ID,
+		PrevLogIndex: next - 1,
+		PrevLogTerm:  n.entry(next - 1).Term,
+		Entries:      append([]Entry(nil), n.log[next-n.firstIndex():last-n.firstIndex()+1]...),
+		LeaderCommit: n.commitIndex,
+	}
+	n.mu.Unlock()
+
+	var resp appendResponse
+	if err := n.call(peer, "/raft/append", req, &resp); err != nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if resp.Term > n.term {
+		n.stepDown(resp.Term)
+		return false
+	}
+	if n.role != Leader || n.term != req.Term {
+		return false
+	}
+	if resp.Success {
+		match := req.PrevLogIndex + int64(len(req.Entries))
+		if match > n.matchIndex[peer] {
+			n.matchIndex[peer] = match
+		}
+		n.nextIndex[peer] = n.matchIndex[peer] + 1
+		n.advanceCommit()
+	} else {
+		// Откатываемся к концу лога узла или на одну запись назад
+		next := min(n.nextIndex[peer]-1, resp.LastIndex+1)
+		n.nextIndex[peer] = max(next, 1)
+	}
+	return n.nextIndex[peer] <= n.lastIndex()
+}
+
+// sendSnapshot передает узлу снапшот лидера. true - есть что отправить еще.
+func (n *Node) sendSnapshot(peer string) bool {
+	n.mu.Lock()
+	if n.role != Leader {
+		n.mu.Unlock()
+		return false
+	}
+	req := snapshotRequest{Term: n.term, LeaderID: n.cfg.ID, Snapshot: n.snapshot}
+	n.mu.Unlock()
+
+	var resp snapshotResponse
+	if err := n.call(peer, "/raft/snapshot", req, &resp); err != nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if resp.Term > n.term {
+		n.stepDown(resp.Term)
+		return false
+	}
+	if n.role != Leader || n.term != req.Term {
+		return false
+	}
+	if req.Snapshot.Index > n.matchIndex[peer] {
+		n.matchIndex[peer] = req.Snapshot.Index
+	}
+	n.nextIndex[peer] = n.matchIndex[peer] + 1
+	n.advanceCommit()
+	return n.nextIndex[peer] <= n.lastIndex()
+}
+
+// compact снимает снапшот FSM и удаляет примененные записи из лога.
+// Вызывается из applier, поэтому состояние FSM соответствует lastApplied.
+func (n *Node) compact() {
+	n.mu.Lock()
+	index := n.lastApplied
+	if index-n.firstIndex() < int64(n.cfg.SnapshotEntries) {
+		n.mu.Unlock()
+		return
+	}
+	term := n.entry(index).Term
+	n.mu.Unlock()
+
+	data, err := n.fsm.Snapshot()
+	if err != nil {
+		slog.Error("Failed to take raft snapshot", "node", n.cfg.ID, "index", index, "error", err)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	// Пока снимали снапшот, лидер мог прислать более новый
+	if index <= n.firstIndex() || n.pendingRestore != nil {
+		return
+	}
+	if err := n.installLocked(Snapshot{Index: index, Term: term, Data: data}); err != nil {
+		slog.Error("Failed to save raft snapshot", "node", n.cfg.ID, "index", index, "error", err)
+		return
+	}
+	slog.Debug("Raft log compacted", "node", n.cfg.ID, "snapshot_index", index, "entries", len(n.log)-1)
+}
+
+// installLocked сохраняет снапшот и оставляет в логе только записи после него
+func (n *Node) installLocked(snapshot Snapshot) error {
+	var rest []Entry
+	if snapshot.Index < n.lastIndex() && snapshot.Index >= n.firstIndex() &&
+		n.entry(snapshot.Index).Term == snapshot.Term {
+		rest = append(rest, n.log[snapshot.Index-n.firstIndex()+1:]...)
+	}
+	if n.storage != nil {
+		if err := n.storage.saveSnapshot(snapshot); err != nil {
+			return err
+		}
+		if err := n.storage.rewrite(rest); err != nil {
+			return err
+		}
+	}
+	n.snapshot = snapshot
+	n.log = append([]Entry{{Index: snapshot.Index, Term: snapshot.Term}}, rest...)
+	raftSnapshotIndex.Set(float64(snapshot.Index), n.cfg.ID)
+	raftLastIndex.Set(float64(n.lastIndex()), n.cfg.ID)
+	return nil
+}
+
+// applier применяет закоммиченные записи и сообщает FSM о смене лидерства
+func (n *Node) applier() {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-n.applyCh:
+		}
+
+		for {
+			n.mu.Lock()
+			if n.pendingDemote {
+				n.pendingDemote = false
+				term := n.term
+				n.mu.Unlock()
+				n.fsm.LeadershipChanged(false, term)
+				continue
+			}
+			if restore := n.pendingRestore; restore != nil {
+				n.pendingRestore = nil
+				n.mu.Unlock()
+				if err := n.fsm.Restore(restore.Data); err != nil {
+					slog.Error("Failed to restore raft snapshot", "node", n.cfg.ID, "index", restore.Index, "error", err)
+				}
+				n.mu.Lock()
+				n.lastApplied = max(n.lastApplied, restore.Index)
+				n.mu.Unlock()
+				continue
+			}
+			if n.lastApplied >= n.commitIndex {
+				n.mu.Unlock()
+				n.compact()
+				break
+			}
+			n.lastApplied++
+			entry := n.entry(n.lastApplied)
+			w, hasWaiter := n.waiters[entry.Index]
+			delete(n.waiters, entry.Index)
+			promote := n.role == Leader && !n.announced && entry.Index == n.leaderNoop && entry.Term == n.term
+			if promote {
+				n.announced = true
+			}
+			n.mu.Unlock()
+
+			if len(entry.Command) > 0 {
+				n.fsm.Apply(entry)
+			}
+			if hasWaiter {
+				if entry.Term == w.term {
+					w.done <- nil
+				} else {
+					w.done <- ErrLeadershipLost
+				}
+			}
+			if promote {
+				n.fsm.LeadershipChanged(true, entry.Term)
+			}
+		}
+	}
+}
+
+func (n *Node) handleVote(req voteRequest) voteResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term < n.term {
+		return voteResponse{Term: n.term}
+	}
+	if req.Term > n.term {
+		n.stepDown(req.Term)
+	}
+	upToDate := req.LastLogTerm > n.lastTerm() ||
+		(req.LastLogTerm == n.lastTerm() && req.LastLogIndex >= n.lastIndex())
+	if (n.votedFor == "" || n.votedFor == req.CandidateID) && upToDate {
+		n.votedFor = req.CandidateID
+		n.persistState()
+		n.resetDeadline()
+		return voteResponse{Term: n.term, VoteGranted: true}
+	}
+	return voteResponse{Term: n.term}
+}
+
+func (n *Node) handleAppend(req appendRequest) appendResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term < n.term {
+		return appendResponse{Term: n.term, LastIndex: n.lastIndex()}
+	}
+	if req.Term > n.term || n.role != Follower {
+		n.stepDown(req.Term)
+	}
+	n.leaderID = req.LeaderID
+	n.resetDeadline()
+
+	if req.PrevLogIndex > n.lastIndex() {
+		return appendResponse{Term: n.term, LastIndex: n.lastIndex()}
+	}
+	// Записи до снапшота закоммичены и совпадают с лидером
+	if req.PrevLogIndex >= n.firstIndex() && n.entry(req.PrevLogIndex).Term != req.PrevLogTerm {
+		return appendResponse{Term: n.term, LastIndex: req.PrevLogIndex - 1}
+	}
+
+	var fresh []Entry
+	for i, entry := range req.Entries {
+		if entry.Index <= n.firstIndex() {
+			continue
+		}
+		if entry.Index <= n.lastIndex() {
+			if n.entry(entry.Index).Term == entry.Term {
+				continue
+			}
+			// Конфликт: отбрасываем хвост, он не мог быть закоммичен
+			n.log = n.log[:entry.Index-n.firstIndex()]
+			if n.storage != nil {
+				if err := n.storage.rewrite(n.log[1:]); err != nil {
+					slog.Error("Failed to truncate raft log", "node", n.cfg.ID, "error", err)
+					return appendResponse{Term: n.term, LastIndex: n.lastIndex()}
+				}
+			}
+		}
+		fresh = req.Entries[i:]
+		break
+	}
+	if len(fresh) > 0 {
+		if err := n.appendLocked(fresh...); err != nil {
+			slog.Error("Failed to append raft entries", "node", n.cfg.ID, "error", err)
+			return appendResponse{Term: n.term, LastIndex: n.lastIndex()}
+		}
+	}
+
+	lastNew := req.PrevLogIndex + int64(len(req.Entries))
+	if req.LeaderCommit > n.commitIndex {
+		n.commitIndex = min(req.LeaderCommit, lastNew)
+		raftCommitIndex.Set(float64(n.commitIndex), n.cfg.ID)
+		n.signalApply()
+	}
+	return appendResponse{Term: n.term, Success: true, LastIndex: n.lastIndex()}
+}
+
+func (n *Node) handleSnapshot(req snapshotRequest) snapshotResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term < n.term {
+		return snapshotResponse{Term: n.term}
+	}
+	if req.Term > n.term || n.role != Follower {
+		n.stepDown(req.Term)
+	}
+	n.leaderID = req.LeaderID
+	n.resetDeadline()
+
+	// Уже применено или ждет применения
+	if req.Snapshot.Index <= n.commitIndex {
+		return snapshotResponse{Term: n.term}
+	}
+	if err := n.installLocked(req.Snapshot); err != nil {
+		slog.Error("Failed to install raft snapshot", "node", n.cfg.ID, "index", req.Snapshot.Index, "error", err)
+		return snapshotResponse{Term: n.term}
+	}
+	n.commitIndex = req.Snapshot.Index
+	raftCommitIndex.Set(float64(n.commitIndex), n.cfg.ID)
+	snapshot := req.Snapshot
+	n.pendingRestore = &snapshot
+	n.signalApply()
+	return snapshotResponse{Term: n.term}
+}
@@ -0,0 +1,183 @@
+package raft
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// persistentState - срок и голос, которые нельзя терять между перезапусками
+type persistentState struct {
+	Term     int64  `json:"term"`
+	VotedFor string `json:"voted_for,omitempty"`
+}
+
+// storage хранит лог в JSONL (log.jsonl), состояние в state.json и последний
+// снапшот в snapshot.json. Каждая запись лога синхронизируется на диск до ответа лидеру.
+type storage struct {
+	dir string
+	log *os.File
+}
+
+func openStorage(dir string) (*storage, persistentState, Snapshot, []Entry, error) {
+	var state persistentState
+	var snapshot Snapshot
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, state, snapshot, nil, err
+	}
+
+	if err := readJSON(filepath.Join(dir, "state.json"), &state); err != nil {
+		return nil, state, snapshot, nil, fmt.Errorf("read raft state: %w", err)
+	}
+	if err := readJSON(filepath.Join(dir, "snapshot.json"), &snapshot); err != nil {
+		return nil, state, snapshot, nil, fmt.Errorf("read raft snapshot: %w", err)
+	}
+
+	entries, err := readLog(filepath.Join(dir, "log.jsonl"), snapshot.Index)
+	if err != nil {
+		return nil, state, snapshot, nil, err
+	}
+	s := &storage{dir: dir}
+	// Переписываем лог, чтобы отбросить оборванный хвост
+	if err := s.rewrite(entries); err != nil {
+		return nil, state, snapshot, nil, err
+	}
+	return s, state, snapshot, entries, nil
+}
+
+// readJSON читает файл в v; отсутствующий файл оставляет v нулевым
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// readLog читает записи после снапшота after. Записи до него могут остаться,
+// если узел упал между сохранением снапшота и усечением лога.
+func readLog(path string, after int64) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			slog.Warn("Dropping torn raft log tail", "path", path, "entries", len(entries))
+			break
+		}
+		if entry.Index <= after {
+			continue
+		}
+		if expected := after + int64(len(entries)) + 1; entry.Index != expected {
+			return nil, fmt.Errorf("raft log %s: expected index %d, got %d", path, expected, entry.Index)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func (s *storage) append(entries []Entry) error {
+	var buf []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if _, err := s.log.Write(buf); err != nil {
+		return err
+	}
+	return s.log.Sync()
+}
+
+// rewrite заменяет лог целиком (усечение при конфликте с лидером и после снапшота)
+func (s *storage) rewrite(entries []Entry) error {
+	path := filepath.Join(s.dir, "log.jsonl")
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(f)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	if s.log != nil {
+		s.log.Close()
+	}
+	s.log, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	return err
+}
+
+func (s *storage) saveState(state persistentState) error {
+	return s.writeFile("state.json", state)
+}
+
+func (s *storage) saveSnapshot(snapshot Snapshot) error {
+	return s.writeFile("snapshot.json", snapshot)
+}
+
+// writeFile атомарно заменяет файл JSON-представлением v
+func (s *storage) writeFile(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	return os.Rename(tmp, path)
+}
+
+func (s *storage) close() {
+	if s.log != nil {
+		s.log.Close()
+	}
+}
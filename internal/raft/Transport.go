@@ -0,0 +1,126 @@
+package raft
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SecretHeader - заголовок с общим секретом кластера
+const SecretHeader = "X-Raft-Secret"
+
+type voteRequest struct {
+	Term         int64  `json:"term"`
+	CandidateID  string `json:"candidate_id"`
+	LastLogIndex int64  `json:"last_log_index"`
+	LastLogTerm  int64  `json:"last_log_term"`
+}
+
+type voteResponse struct {
+	Term        int64 `json:"term"`
+	VoteGranted bool  `json:"vote_granted"`
+}
+
+type appendRequest struct {
+	Term         int64   `json:"term"`
+	LeaderID     string  `json:"leader_id"`
+	PrevLogIndex int64   `json:"prev_log_index"`
+	PrevLogTerm  int64   `json:"prev_log_term"`
+	Entries      []Entry `json:"entries,omitempty"`
+	LeaderCommit int64   `json:"leader_commit"`
+}
+
+type appendResponse struct {
+	Term    int64 `json:"term"`
+	Success bool  `json:"success"`
+	// Последний индекс лога узла, чтобы лидер быстрее нашел точку расхождения
+	LastIndex int64 `json:"last_index"`
+}
+
+// snapshotRequest - InstallSnapshot для узла, отставшего дальше снапшота лидера
+type snapshotRequest struct {
+	Term     int64    `json:"term"`
+	LeaderID string   `json:"leader_id"`
+	Snapshot Snapshot `json:"snapshot"`
+}
+
+type snapshotResponse struct {
+	Term int64 `json:"term"`
+}
+
+// Handler обслуживает RPC узла: POST /raft/vote, /raft/append и /raft/snapshot
+func (n *Node) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /raft/vote", func(w http.ResponseWriter, r *http.Request) {
+		var req voteRequest
+		if !n.decodeRPC(w, r, &req) {
+			return
+		}
+		writeRPC(w, n.handleVote(req))
+	})
+	mux.HandleFunc("POST /raft/append", func(w http.ResponseWriter, r *http.Request) {
+		var req appendRequest
+		if !n.decodeRPC(w, r, &req) {
+			return
+		}
+		writeRPC(w, n.handleAppend(req))
+	})
+	mux.HandleFunc("POST /raft/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		var req snapshotRequest
+		if !n.decodeRPC(w, r, &req) {
+			return
+		}
+		writeRPC(w, n.handleSnapshot(req))
+	})
+	return mux
+}
+
+func (n *Node) decodeRPC(w http.ResponseWriter, r *http.Request, v any) bool {
+	if n.cfg.Secret == "" ||
+		subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretHeader)), []byte(n.cfg.Secret)) != 1 {
+		http.Error(w, "Invalid raft secret", http.StatusUnauthorized)
+		return false
+	}
+	select {
+	case <-n.stop:
+		http.Error(w, ErrStopped.Error(), http.StatusServiceUnavailable)
+		return false
+	default:
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "Invalid raft request", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeRPC(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (n *Node) call(peer, path string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, strings.TrimRight(n.cfg.Peers[peer], "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(SecretHeader, n.cfg.Secret)
+
+	httpResp, err := n.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("raft %s on %s: %s", path, peer, httpResp.Status)
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
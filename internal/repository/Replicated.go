@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/raft"
+)
+
+// DefaultProposeTimeout - сколько изменение ждет коммита в raft
+const DefaultProposeTimeout = 5 * time.Second
+
+const (
+	opCreate = "create"
+	opUpdate = "update"
+	opDelete = "delete"
+)
+
+// replicatedCommand - запись raft лога с изменением хранилища
+type replicatedCommand struct {
+	Op     string              `json:"op"`
+	ID     string              `json:"id"`
+	Task   *model.TaskSnapshot `json:"task,omitempty"`
+	Origin string              `json:"origin"`
+	// Для delete: удалить, только если задача завершена в этот момент
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Срок лидерства, в котором создана запись
+	Epoch int64 `json:"epoch"`
+}
+
+// ReplicatedTaskRepository реплицирует изменения задач через raft лог.
+// Изменять хранилище может только лидер; ведомые получают задачи из лога.
+// На лидере объекты задач принадлежат пулу, поэтому его собственные записи
+// текущего срока при применении пропускаются, но учитываются в committed -
+// состоянии по логу, из которого строятся снапшоты и перестраивается хранилище.
+type ReplicatedTaskRepository struct {
+	node    *raft.Node
+	timeout time.Duration
+
+	mu    sync.RWMutex
+	tasks map[string]*model.Task
+	// Закоммиченные снимки задач, ровно как в логе
+	committed map[string]model.TaskSnapshot
+	// Ненулевой - узел лидер в этом сроке
+	epoch    int64
+	creating map[string]bool
+
+	// Вызывается при смене лидерства: true - после применения всего лога
+	onLeadership func(leader bool)
+}
+
+// NewReplicatedTaskRepository создает хранилище поверх node.
+// Его нужно передать в node.Start как FSM.
+func NewReplicatedTaskRepository(node *raft.Node) *ReplicatedTaskRepository {
+	return &ReplicatedTaskRepository{
+		node:      node,
+		timeout:   DefaultProposeTimeout,
+		tasks:     make(map[string]*model.Task),
+		committed: make(map[string]model.TaskSnapshot),
+		creating:  make(map[string]bool),
+	}
+}
+
+// OnLeadershipChange задает обработчик смены лидерства. Получение лидерства
+// обрабатывается асинхронно, потеря - синхронно до применения следующих записей.
+func (r *ReplicatedTaskRepository) OnLeadershipChange(fn func(leader bool)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onLeadership = fn
+}
+
+func (r *ReplicatedTaskRepository) IsLeader() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.epoch != 0
+}
+
+func (r *ReplicatedTaskRepository) propose(cmd replicatedCommand) error {
+	cmd.Origin = r.node.ID()
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	if err := r.node.Propose(ctx, data); err != nil {
+		return fmt.Errorf("replicate %s of task %s: %w", cmd.Op, cmd.ID, err)
+	}
+	return nil
+}
+
+func (r *ReplicatedTaskRepository) Create(task *model.Task) error {
+	r.mu.Lock()
+	if _, exists := r.tasks[task.ID]; exists || r.creating[task.ID] {
+		r.mu.Unlock()
+		return fmt.Errorf("task with id %s already exists", task.ID)
+	}
+	epoch := r.epoch
+	if epoch == 0 {
+		r.mu.Unlock()
+		return raft.ErrNotLeader
+	}
+	r.creating[task.ID] = true
+	r.mu.Unlock()
+
+	snapshot := task.Snapshot()
+	err := r.propose(replicatedCommand{Op: opCreate, ID: task.ID, Task: &snapshot, Epoch: epoch})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.creating, task.ID)
+	if err != nil {
+		return err
+	}
+	// После смены срока хранилище уже перестроено из лога
+	if r.epoch == epoch {
+		r.tasks[task.ID] = task
+	}
+	return nil
+}
+
+func (r *ReplicatedTaskRepository) GetByID(id string) (*model.Task, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	task, exists := r.tasks[id]
+	return task, exists
+}
+
+func (r *ReplicatedTaskRepository) Update(task *model.Task) error {
+	r.mu.RLock()
+	_, exists := r.tasks[task.ID]
+	epoch := r.epoch
+	r.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("task with id %s not found", task.ID)
+	}
+	if epoch == 0 {
+		return raft.ErrNotLeader
+	}
+
+	snapshot := task.Snapshot()
+	return r.propose(replicatedCommand{Op: opUpdate, ID: task.ID, Task: &snapshot, Epoch: epoch})
+}
+
+func (r *ReplicatedTaskRepository) Exists(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.tasks[id]
+	return exists
+}
+
+func (r *ReplicatedTaskRepository) Delete(id string) bool {
+	r.mu.RLock()
+	_, exists := r.tasks[id]
+	epoch := r.epoch
+	r.mu.RUnlock()
+	if !exists || epoch == 0 {
+		return false
+	}
+
+	if err := r.propose(replicatedCommand{Op: opDelete, ID: id, Epoch: epoch}); err != nil {
+		slog.Warn("Failed to replicate task deletion", "task_id", id, "error", err)
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.epoch == epoch {
+		delete(r.tasks, id)
+	}
+	return true
+}
+
+func (r *ReplicatedTaskRepository) DeleteFinished(id string, finishedAt time.Time) bool {
+	r.mu.RLock()
+	task, exists := r.tasks[id]
+	epoch := r.epoch
+	r.mu.RUnlock()
+	if !exists || epoch == 0 || !task.IsFinishedAt(finishedAt) {
+		return false
+	}
+
+	cmd := replicatedCommand{Op: opDelete, ID: id, FinishedAt: &finishedAt, Epoch: epoch}
+	if err := r.propose(cmd); err != nil {
+		slog.Warn("Failed to replicate task deletion", "task_id", id, "error", err)
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Задачу могли перезапустить, пока запись коммитилась
+	if current, ok := r.tasks[id]; r.epoch != epoch || !ok || !current.IsFinishedAt(finishedAt) {
+		return false
+	}
+	delete(r.tasks, id)
+	return true
+}
+
+func (r *ReplicatedTaskRepository) GetAll() map[string]*model.Task {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(map[string]*model.Task, len(r.tasks))
+	for id, task := range r.tasks {
+		result[id] = task
+	}
+	return result
+}
+
+// Apply реализует raft.FSM
+func (r *ReplicatedTaskRepository) Apply(entry raft.Entry) {
+	var cmd replicatedCommand
+	if err := json.Unmarshal(entry.Command, &cmd); err != nil {
+		slog.Error("Invalid replicated command", "index", entry.Index, "error", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Условное удаление проверяется по логу одинаково на всех узлах
+	if cmd.Op == opDelete && cmd.FinishedAt != nil && !committedFinishedAt(r.committed[cmd.ID], *cmd.FinishedAt) {
+		return
+	}
+	switch cmd.Op {
+	case opCreate, opUpdate:
+		if cmd.Task != nil {
+			r.committed[cmd.ID] = *cmd.Task
+		}
+	case opDelete:
+		delete(r.committed, cmd.ID)
+	}
+	if r.epoch != 0 && cmd.Epoch == r.epoch && cmd.Origin == r.node.ID() {
+		return
+	}
+	switch cmd.Op {
+	case opCreate, opUpdate:
+		if cmd.Task != nil {
+			r.tasks[cmd.ID] = model.TaskFromSnapshot(*cmd.Task)
+		}
+	case opDelete:
+		delete(r.tasks, cmd.ID)
+	}
+}
+
+func committedFinishedAt(snapshot model.TaskSnapshot, finishedAt time.Time) bool {
+	return model.IsTerminalStatus(snapshot.Status) && snapshot.FinishedAt != nil && snapshot.FinishedAt.Equal(finishedAt)
+}
+
+// Snapshot реализует raft.FSM
+func (r *ReplicatedTaskRepository) Snapshot() (json.RawMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return json.Marshal(r.committed)
+}
+
+// Restore реализует raft.FSM
+func (r *ReplicatedTaskRepository) Restore(snapshot json.RawMessage) error {
+	committed := make(map[string]model.TaskSnapshot)
+	if len(snapshot) > 0 {
+		if err := json.Unmarshal(snapshot, &committed); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committed = committed
+	r.rebuildLocked()
+	return nil
+}
+
+// rebuildLocked заменяет объекты задач закоммиченным состоянием, вызывается под mu
+func (r *ReplicatedTaskRepository) rebuildLocked() {
+	r.tasks = make(map[string]*model.Task, len(r.committed))
+	for id, snapshot := range r.committed {
+		r.tasks[id] = model.TaskFromSnapshot(snapshot)
+	}
+}
+
+// LeadershipChanged реализует raft.FSM
+func (r *ReplicatedTaskRepository) LeadershipChanged(leader bool, term int64) {
+	if leader {
+		r.mu.Lock()
+		r.epoch = term
+		hook := r.onLeadership
+		r.mu.Unlock()
+		slog.Info("Replicated repository is now writable", "node", r.node.ID(), "term", term)
+		if hook != nil {
+			// Хук пишет в хранилище и ждет коммита, а коммит применяется этой же горутиной
+			go hook(true)
+		}
+		return
+	}
+
+	// Локальные объекты лидера могли разойтись с логом - перестраиваем по логу
+	r.mu.Lock()
+	r.epoch = 0
+	r.rebuildLocked()
+	hook, count := r.onLeadership, len(r.tasks)
+	r.mu.Unlock()
+	slog.Info("Replicated repository is now read-only", "node", r.node.ID(), "tasks", count)
+	if hook != nil {
+		hook(false)
+	}
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"TaskQueue/internal/metrics"
+	"TaskQueue/internal/model"
+)
+
+var (
+	gcEvicted = metrics.Default.Counter("taskqueue_gc_evicted_total",
+		"Finished tasks removed by retention GC.", "status", "reason")
+	gcArchiveErrors = metrics.Default.Counter("taskqueue_gc_archive_errors_total",
+		"Failed attempts to archive evicted tasks.")
+)
+
+type RetentionPolicy struct {
+	// Время хранения по терминальному статусу, 0 - бессрочно
+	TTL map[string]time.Duration
+	// Максимум задач в хранилище, 0 - без ограничения.
+	// Вытесняются самые старые завершенные задачи.
+	MaxTasks int
+	// Каталог для gzip JSONL архивов, пусто - без архивации
+	ArchiveDir string
+	Interval   time.Duration
+}
+
+type Collector struct {
+	repo   TaskRepository
+	policy RetentionPolicy
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewCollector(repo TaskRepository, policy RetentionPolicy) *Collector {
+	if policy.Interval <= 0 {
+		policy.Interval = time.Minute
+	}
+	return &Collector{
+		repo:   repo,
+		policy: policy,
+		stop:   make(chan struct{}),
+	}
+}
+
+func (c *Collector) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.policy.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case now := <-ticker.C:
+				if _, err := c.Collect(now); err != nil {
+					slog.Error("Retention GC failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+func (c *Collector) Stop() {
+	close(c.stop)
+	c.wg.Wait()
+}
+
+type finishedTask struct {
+	task       *model.Task
+	status     string
+	finishedAt time.Time
+}
+
+// Collect удаляет задачи с истекшим сроком хранения и сверх лимита.
+// При ошибке архивации задачи не удаляются. Задача удаляется, только если
+// она не перезапущена после выборки.
+func (c *Collector) Collect(now time.Time) (int, error) {
+	// Реплицируемое хранилище изменяет только лидер
+	if leader, ok := c.repo.(interface{ IsLeader() bool }); ok && !leader.IsLeader() {
+		return 0, nil
+	}
+	all := c.repo.GetAll()
+
+	var finished []finishedTask
+	for _, task := range all {
+		status := task.GetStatus()
+		if !model.IsTerminalStatus(status) {
+			continue
+		}
+		finished = append(finished, finishedTask{task: task, status: status, finishedAt: task.GetFinishedAt()})
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].finishedAt.Before(finished[j].finishedAt)
+	})
+
+	var evict []finishedTask
+	var reasons []string
+	kept := finished[:0]
+	for _, f := range finished {
+		if ttl := c.policy.TTL[f.status]; ttl > 0 && now.Sub(f.finishedAt) > ttl {
+			evict = append(evict, f)
+			reasons = append(reasons, "ttl")
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	if c.policy.MaxTasks > 0 {
+		excess := len(all) - len(evict) - c.policy.MaxTasks
+		for i := 0; i < excess && i < len(kept); i++ {
+			evict = append(evict, kept[i])
+			reasons = append(reasons, "max_tasks")
+		}
+	}
+
+	if len(evict) == 0 {
+		return 0, nil
+	}
+
+	if c.policy.ArchiveDir != "" {
+		if err := c.archive(evict, now); err != nil {
+			gcArchiveErrors.Inc()
+			return 0, fmt.Errorf("archive: %v", err)
+		}
+	}
+
+	evicted := 0
+	for i, f := range evict {
+		if c.repo.DeleteFinished(f.task.ID, f.finishedAt) {
+			evicted++
+			gcEvicted.Inc(f.status, reasons[i])
+		}
+	}
+	slog.Info("Retention GC evicted tasks", "count", evicted, "remaining", len(all)-evicted)
+	return evicted, nil
+}
+
+func (c *Collector) archive(tasks []finishedTask, now time.Time) error {
+	if err := os.MkdirAll(c.policy.ArchiveDir, 0755); err != nil {
+		return err
+	}
+
+	name := filepath.Join(c.policy.ArchiveDir,
+		fmt.Sprintf("tasks-%s.jsonl.gz", now.UTC().Format("20060102T150405.000000000")))
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(f)
+	encoder := json.NewEncoder(zw)
+	for _, t := range tasks {
+		if err = encoder.Encode(t.task.Snapshot()); err != nil {
+			break
+		}
+	}
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if syncErr := f.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"TaskQueue/internal/model"
 )
@@ -13,6 +14,10 @@ type TaskRepository interface {
 	Update(task *model.Task) error
 	Exists(id string) bool
 	GetAll() map[string]*model.Task
+	Delete(id string) bool
+	// DeleteFinished удаляет задачу, только если она все еще завершена
+	// в момент finishedAt, а не перезапущена после чтения
+	DeleteFinished(id string, finishedAt time.Time) bool
 }
 
 type inMemoryTaskRepository struct {
@@ -66,6 +71,27 @@ func (r *inMemoryTaskRepository) Exists(id string) bool {
 	return exists
 }
 
+func (r *inMemoryTaskRepository) Delete(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, exists := r.tasks[id]
+	delete(r.tasks, id)
+	return exists
+}
+
+func (r *inMemoryTaskRepository) DeleteFinished(id string, finishedAt time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, exists := r.tasks[id]
+	if !exists || !task.IsFinishedAt(finishedAt) {
+		return false
+	}
+	delete(r.tasks, id)
+	return true
+}
+
 func (r *inMemoryTaskRepository) GetAll() map[string]*model.Task {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -0,0 +1,601 @@
+package resp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/events"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/service"
+	"TaskQueue/internal/tracing"
+	"TaskQueue/queue"
+)
+
+// Версия протокола Redis, которую видят клиенты
+const redisVersion = "7.0.0"
+
+const maxLeaseBatch = 100
+
+// Элементы списков - строки, в задаче хранятся как JSON строка
+const textContentType = "text/plain"
+
+type command struct {
+	// Число аргументов вместе с именем; отрицательное - минимум
+	arity int
+	// Пустой - команда доступна любому аутентифицированному клиенту
+	scope auth.Scope
+	// Доступна до AUTH
+	public bool
+	run    func(c *conn, args []string)
+}
+
+var commands = map[string]command{
+	"ping":    {arity: -1, run: (*conn).ping},
+	"echo":    {arity: 2, run: (*conn).echo},
+	"hello":   {arity: -1, public: true, run: (*conn).hello},
+	"auth":    {arity: -2, public: true, run: (*conn).auth},
+	"quit":    {arity: -1, public: true, run: (*conn).quitCommand},
+	"select":  {arity: 2, run: (*conn).selectDB},
+	"client":  {arity: -2, run: (*conn).client},
+	"command": {arity: -1, run: (*conn).commandInfo},
+	"info":    {arity: -1, run: (*conn).info},
+
+	// Списки Redis - это очереди задач типа Config.TaskType
+	"lpush": {arity: -3, scope: auth.ScopeEnqueue, run: (*conn).push},
+	"rpush": {arity: -3, scope: auth.ScopeEnqueue, run: (*conn).push},
+	"brpop": {arity: -3, scope: auth.ScopeWorker, run: (*conn).blockingPop},
+	"blpop": {arity: -3, scope: auth.ScopeWorker, run: (*conn).blockingPop},
+	"llen":  {arity: 2, scope: auth.ScopeRead, run: (*conn).llen},
+
+	"tq.enqueue":   {arity: -3, scope: auth.ScopeEnqueue, run: (*conn).tqEnqueue},
+	"tq.lease":     {arity: -3, scope: auth.ScopeWorker, run: (*conn).tqLease},
+	"tq.heartbeat": {arity: -3, scope: auth.ScopeWorker, run: (*conn).tqHeartbeat},
+	"tq.ack":       {arity: 3, scope: auth.ScopeWorker, run: (*conn).tqAck},
+	"tq.nack":      {arity: -3, scope: auth.ScopeWorker, run: (*conn).tqNack},
+	"tq.status":    {arity: 2, scope: auth.ScopeRead, run: (*conn).tqStatus},
+	"tq.cancel":    {arity: 2, scope: auth.ScopeAdmin, run: (*conn).tqCancel},
+}
+
+func (c *conn) execute(args []string) {
+	name := strings.ToLower(args[0])
+	cmd, exists := commands[name]
+	if !exists {
+		respCommands.Inc("unknown")
+		c.w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	respCommands.Inc(name)
+
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	if !c.authenticated.Load() && !cmd.public {
+		c.w.error("NOAUTH Authentication required.")
+		return
+	}
+	if cmd.scope != "" && !c.authorize(name, cmd.scope) {
+		return
+	}
+	cmd.run(c, args)
+}
+
+func (c *conn) ping(args []string) {
+	if len(args) > 1 {
+		c.w.bulk(args[1])
+		return
+	}
+	c.w.simple("PONG")
+}
+
+func (c *conn) echo(args []string) {
+	c.w.bulk(args[1])
+}
+
+// hello: HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (c *conn) hello(args []string) {
+	proto := c.w.proto
+	rest := args[1:]
+	if len(rest) > 0 {
+		version, err := strconv.Atoi(rest[0])
+		if err != nil || version < 2 || version > 3 {
+			c.w.error("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = version
+		rest = rest[1:]
+	}
+	for len(rest) > 0 {
+		switch strings.ToUpper(rest[0]) {
+		case "AUTH":
+			if len(rest) < 3 {
+				c.w.error("ERR syntax error")
+				return
+			}
+			// Без аутентификации пароль клиента игнорируется
+			if c.server.cfg.Authenticator != nil && !c.login(rest[2]) {
+				return
+			}
+			rest = rest[3:]
+		case "SETNAME":
+			if len(rest) < 2 {
+				c.w.error("ERR syntax error")
+				return
+			}
+			c.name = rest[1]
+			rest = rest[2:]
+		default:
+			c.w.error("ERR syntax error")
+			return
+		}
+	}
+	if !c.authenticated.Load() {
+		c.w.error("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+		return
+	}
+
+	c.w.proto = proto
+	c.w.mapHeader(7)
+	c.w.bulk("server")
+	c.w.bulk("taskqueue")
+	c.w.bulk("version")
+	c.w.bulk(redisVersion)
+	c.w.bulk("proto")
+	c.w.integer(int64(proto))
+	c.w.bulk("id")
+	c.w.integer(c.id)
+	c.w.bulk("mode")
+	c.w.bulk("standalone")
+	c.w.bulk("role")
+	c.w.bulk("master")
+	c.w.bulk("modules")
+	c.w.array(0)
+}
+
+// auth: AUTH [username] password; имя пользователя не используется
+func (c *conn) auth(args []string) {
+	if len(args) > 3 {
+		c.w.error("ERR syntax error")
+		return
+	}
+	if c.login(args[len(args)-1]) {
+		c.w.simple("OK")
+	}
+}
+
+func (c *conn) quitCommand(args []string) {
+	c.w.simple("OK")
+	c.quit = true
+}
+
+// selectDB: база у сервера одна
+func (c *conn) selectDB(args []string) {
+	if args[1] != "0" {
+		c.w.error("ERR DB index is out of range")
+		return
+	}
+	c.w.simple("OK")
+}
+
+func (c *conn) client(args []string) {
+	switch strings.ToUpper(args[1]) {
+	case "ID":
+		c.w.integer(c.id)
+	case "GETNAME":
+		if c.name == "" {
+			c.w.null()
+			return
+		}
+		c.w.bulk(c.name)
+	case "SETNAME":
+		if len(args) != 3 {
+			c.w.error("ERR wrong number of arguments for 'client|setname' command")
+			return
+		}
+		c.name = args[2]
+		c.w.simple("OK")
+	default:
+		// SETINFO и прочие настройки клиента не влияют на сервер
+		c.w.simple("OK")
+	}
+}
+
+func (c *conn) commandInfo(args []string) {
+	c.w.array(0)
+}
+
+func (c *conn) info(args []string) {
+	c.w.bulk("# Server\r\nredis_version:" + redisVersion + "\r\nredis_mode:standalone\r\n")
+}
+
+// push: LPUSH/RPUSH key element [element ...]. Каждый элемент - задача
+// в очереди key; порядок выдачи - FIFO очереди для обеих команд.
+func (c *conn) push(args []string) {
+	key := args[1]
+	if !c.authorize(strings.ToLower(args[0]), auth.ScopeEnqueue, key) {
+		return
+	}
+	for _, element := range args[2:] {
+		task := &model.Task{
+			ID:          newTaskID(),
+			Type:        c.server.cfg.TaskType,
+			Queue:       key,
+			Payload:     model.TextPayload(element),
+			ContentType: textContentType,
+			MaxRetries:  c.server.cfg.MaxRetries,
+		}
+		if err := c.enqueue(task); err != nil {
+			c.writeError(err)
+			return
+		}
+	}
+	c.w.integer(int64(c.queued(key)))
+}
+
+// blockingPop: BRPOP/BLPOP key [key ...] timeout. Задача выдается и сразу
+// завершается (at-most-once, как в Redis); для подтверждений - TQ.LEASE.
+func (c *conn) blockingPop(args []string) {
+	keys := args[1 : len(args)-1]
+	if !c.authorize(strings.ToLower(args[0]), auth.ScopeWorker, keys...) {
+		return
+	}
+	seconds, err := strconv.ParseFloat(args[len(args)-1], 64)
+	if err != nil || seconds < 0 {
+		c.w.error("ERR timeout is not a float or out of range")
+		return
+	}
+
+	// Подписка до первой попытки, чтобы не пропустить задачу между ними
+	taskEvents, unsubscribe := c.server.service.Subscribe(16)
+	defer unsubscribe()
+	var deadline <-chan time.Time
+	if seconds > 0 {
+		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	poll := time.NewTicker(c.server.cfg.PollInterval)
+	defer poll.Stop()
+
+	if c.pop(keys) {
+		return
+	}
+	// Ответы на предыдущие команды не должны ждать окончания блокировки
+	c.w.Flush()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-deadline:
+			c.w.nullArray()
+			return
+		case <-poll.C:
+		case event, ok := <-taskEvents:
+			if !ok {
+				taskEvents = nil
+				continue
+			}
+			if event.Type != events.TypeStatus || event.Status != "queued" || !contains(keys, event.Queue) {
+				continue
+			}
+		}
+		if c.pop(keys) {
+			return
+		}
+	}
+}
+
+// pop выдает первую задачу из очередей keys по порядку
+func (c *conn) pop(keys []string) bool {
+	workerID := fmt.Sprintf("resp-%d", c.id)
+	for _, key := range keys {
+		leased := c.server.service.Lease(queue.LeaseRequest{
+			WorkerID:  workerID,
+			Types:     []string{c.server.cfg.TaskType},
+			Queues:    []string{key},
+			Max:       1,
+			Tenant:    c.tenant(),
+			Principal: c.principalName(),
+		})
+		if len(leased) == 0 {
+			continue
+		}
+		task := leased[0]
+		c.w.bulks(key, string(model.PayloadBody(task.Payload, task.ContentType)))
+		// Клиент не получил задачу - она вернется в очередь по политике повторов
+		if err := c.w.Flush(); err != nil {
+			c.server.service.Fail(task.ID, c.holder(workerID), "resp client disconnected")
+			c.close()
+			return true
+		}
+		if err := c.server.service.Complete(task.ID, c.holder(workerID)); err != nil {
+			slog.Warn("Failed to complete popped task", "task_id", task.ID, "error", err)
+		}
+		return true
+	}
+	return false
+}
+
+func (c *conn) llen(args []string) {
+	if !c.authorize("llen", auth.ScopeRead, args[1]) {
+		return
+	}
+	c.w.integer(int64(c.queued(args[1])))
+}
+
+// queued - задачи, ожидающие в очереди key
+func (c *conn) queued(key string) int {
+	return c.server.service.QueueDepth(key, c.canAccess)
+}
+
+// tqEnqueue: TQ.ENQUEUE queue payload [ID id] [TYPE type] [RETRIES n]
+// [PARTITION key] [TENANT tenant]. Ответ - id задачи.
+func (c *conn) tqEnqueue(args []string) {
+	task := &model.Task{
+		Queue:       args[1],
+		Payload:     model.TextPayload(args[2]),
+		ContentType: textContentType,
+		Type:        c.server.cfg.TaskType,
+		MaxRetries:  c.server.cfg.MaxRetries,
+	}
+	options, ok := c.options(args[3:], "ID", "TYPE", "RETRIES", "PARTITION", "TENANT")
+	if !ok {
+		return
+	}
+	if values := options["ID"]; len(values) > 0 {
+		task.ID = values[0]
+	}
+	if values := options["TYPE"]; len(values) > 0 {
+		task.Type = values[0]
+	}
+	if values := options["RETRIES"]; len(values) > 0 {
+		retries, err := strconv.Atoi(values[0])
+		if err != nil || retries <= 0 {
+			c.w.error("ERR RETRIES must be a positive integer")
+			return
+		}
+		task.MaxRetries = retries
+	}
+	if values := options["PARTITION"]; len(values) > 0 {
+		task.PartitionKey = values[0]
+	}
+	// Как X-Tenant-ID в HTTP: учитывается только для ключей без арендатора
+	if values := options["TENANT"]; len(values) > 0 && c.tenant() == "" {
+		task.Tenant = values[0]
+	}
+	if task.ID == "" {
+		task.ID = newTaskID()
+	}
+	if !c.authorize("tq.enqueue", auth.ScopeEnqueue, task.Queue) {
+		return
+	}
+	if err := c.enqueue(task); err != nil {
+		c.writeError(err)
+		return
+	}
+	c.w.bulk(task.ID)
+}
+
+// tqLease: TQ.LEASE worker type [QUEUE q ...] [COUNT n] [VISIBILITY seconds].
+// Ответ - массив задач (map в RESP3).
+func (c *conn) tqLease(args []string) {
+	options, ok := c.options(args[3:], "QUEUE", "COUNT", "VISIBILITY")
+	if !ok {
+		return
+	}
+	req := queue.LeaseRequest{
+		WorkerID:  args[1],
+		Types:     strings.Split(args[2], ","),
+		Queues:    options["QUEUE"],
+		Max:       1,
+		Tenant:    c.tenant(),
+		Principal: c.principalName(),
+	}
+	if values := options["COUNT"]; len(values) > 0 {
+		count, err := strconv.Atoi(values[0])
+		if err != nil || count <= 0 {
+			c.w.error("ERR COUNT must be a positive integer")
+			return
+		}
+		req.Max = min(count, maxLeaseBatch)
+	}
+	if values := options["VISIBILITY"]; len(values) > 0 {
+		timeout, ok := c.seconds(values[0])
+		if !ok {
+			return
+		}
+		req.VisibilityTimeout = timeout
+	}
+	if c.principal != nil && len(c.principal.Queues) > 0 && len(req.Queues) == 0 {
+		req.Queues = c.principal.Queues
+	}
+	if !c.authorize("tq.lease", auth.ScopeWorker, req.Queues...) {
+		return
+	}
+
+	leased := c.server.service.Lease(req)
+	c.w.array(len(leased))
+	for _, task := range leased {
+		c.w.mapHeader(7)
+		c.w.bulk("id")
+		c.w.bulk(task.ID)
+		c.w.bulk("type")
+		c.w.bulk(task.Type)
+		c.w.bulk("queue")
+		c.w.bulk(task.Queue)
+		c.w.bulk("payload")
+		c.w.bulk(string(model.PayloadBody(task.Payload, task.ContentType)))
+		c.w.bulk("attempt")
+		c.w.integer(int64(task.Attempt))
+		c.w.bulk("max_retries")
+		c.w.integer(int64(task.MaxRetries))
+		c.w.bulk("lease_expires_at")
+		c.w.integer(task.LeaseExpiresAt.UnixMilli())
+	}
+}
+
+// tqHeartbeat: TQ.HEARTBEAT id worker [seconds]. Ответ - срок аренды в unix ms.
+func (c *conn) tqHeartbeat(args []string) {
+	if len(args) > 4 {
+		c.w.error("ERR syntax error")
+		return
+	}
+	var timeout time.Duration
+	if len(args) == 4 {
+		var ok bool
+		if timeout, ok = c.seconds(args[3]); !ok {
+			return
+		}
+	}
+	if !c.leaseAccessible(args[1]) {
+		return
+	}
+	expiresAt, err := c.server.service.Heartbeat(args[1], c.holder(args[2]), timeout)
+	if err != nil {
+		c.writeError(err)
+		return
+	}
+	c.w.integer(expiresAt.UnixMilli())
+}
+
+// tqAck: TQ.ACK id worker
+func (c *conn) tqAck(args []string) {
+	if !c.leaseAccessible(args[1]) {
+		return
+	}
+	if err := c.server.service.Complete(args[1], c.holder(args[2])); err != nil {
+		c.writeError(err)
+		return
+	}
+	c.w.simple("OK")
+}
+
+// tqNack: TQ.NACK id worker [reason]
+func (c *conn) tqNack(args []string) {
+	if len(args) > 4 {
+		c.w.error("ERR syntax error")
+		return
+	}
+	reason := ""
+	if len(args) == 4 {
+		reason = args[3]
+	}
+	if !c.leaseAccessible(args[1]) {
+		return
+	}
+	if err := c.server.service.Fail(args[1], c.holder(args[2]), reason); err != nil {
+		c.writeError(err)
+		return
+	}
+	c.w.simple("OK")
+}
+
+// tqStatus: TQ.STATUS id. Неизвестная задача - null.
+func (c *conn) tqStatus(args []string) {
+	task, exists := c.server.service.GetTask(args[1])
+	if !exists || !c.canAccess(task.Queue, task.Tenant) {
+		c.w.null()
+		return
+	}
+	c.w.bulk(task.GetStatus())
+}
+
+// tqCancel: TQ.CANCEL id
+func (c *conn) tqCancel(args []string) {
+	task, exists := c.server.service.GetTask(args[1])
+	if !exists || !c.canAccess(task.Queue, task.Tenant) {
+		c.writeError(service.ErrTaskNotFound)
+		return
+	}
+	if err := c.server.service.Cancel(args[1]); err != nil {
+		c.writeError(err)
+		return
+	}
+	c.w.simple("OK")
+}
+
+func (c *conn) enqueue(task *model.Task) error {
+	if task.Tenant == "" {
+		task.Tenant = c.tenant()
+	}
+	ctx, span := tracing.Tracer().Start(c.ctx, "enqueue", trace.WithAttributes(
+		attribute.String("task.id", task.ID),
+		attribute.String("task.queue", task.Queue),
+	))
+	defer span.End()
+	tracing.Inject(ctx, task)
+
+	err := c.server.service.EnqueueContext(ctx, task)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// options разбирает пары "NAME value"; значения повторенного имени накапливаются
+func (c *conn) options(args []string, names ...string) (map[string][]string, bool) {
+	result := make(map[string][]string)
+	for i := 0; i < len(args); i += 2 {
+		name := strings.ToUpper(args[i])
+		if !contains(names, name) || i+1 >= len(args) {
+			c.w.error("ERR syntax error")
+			return nil, false
+		}
+		result[name] = append(result[name], args[i+1])
+	}
+	return result, true
+}
+
+func (c *conn) seconds(value string) (time.Duration, bool) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds <= 0 {
+		c.w.error("ERR timeout is not a float or out of range")
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// holder - аренда принадлежит паре worker и ключ соединения
+func (c *conn) holder(workerID string) queue.LeaseHolder {
+	return queue.LeaseHolder{WorkerID: workerID, Principal: c.principalName()}
+}
+
+// leaseAccessible пишет ошибку "не арендована" для задачи чужой очереди или арендатора
+func (c *conn) leaseAccessible(id string) bool {
+	if task, exists := c.server.service.GetTask(id); exists && !c.canAccess(task.Queue, task.Tenant) {
+		c.writeError(queue.ErrTaskNotLeased)
+		return false
+	}
+	return true
+}
+
+// writeError - ошибка сервиса в виде ответа Redis; переполнение уже содержит "retry after"
+func (c *conn) writeError(err error) {
+	c.w.error("ERR " + err.Error())
+}
+
+func newTaskID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
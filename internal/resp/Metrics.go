@@ -0,0 +1,10 @@
+package resp
+
+import "TaskQueue/internal/metrics"
+
+var (
+	respConnections = metrics.Default.Gauge("taskqueue_resp_connections",
+		"Open connections to the Redis protocol listener.")
+	respCommands = metrics.Default.Counter("taskqueue_resp_commands_total",
+		"Redis protocol commands received.", "command")
+)
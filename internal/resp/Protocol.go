@@ -0,0 +1,154 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxArgs      = 1024
+	maxBulkBytes = 16 << 20
+	maxLineBytes = 64 << 10
+	// Буфер bulk строки выделяется сразу не больше этого, дальше растет по мере чтения
+	bulkChunkBytes = 64 << 10
+)
+
+// readLimits ограничивают размер команды
+type readLimits struct {
+	args int
+	bulk int
+}
+
+var (
+	authLimits = readLimits{args: maxArgs, bulk: maxBulkBytes}
+	// До AUTH хватает HELLO 3 AUTH user password SETNAME name, пароль - JWT
+	preAuthLimits = readLimits{args: 16, bulk: 16 << 10}
+)
+
+var errProtocol = errors.New("Protocol error")
+
+// readCommand читает команду: массив bulk строк или inline строку (telnet)
+func readCommand(r *bufio.Reader, limits readLimits) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count > limits.args {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	args := make([]string, 0, max(count, 0))
+	for i := 0; i < count; i++ {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if header == "" || header[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, header)
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil || size < 0 || size > limits.bulk {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+		// Объявленная длина не выделяется целиком до получения данных
+		var buf bytes.Buffer
+		buf.Grow(min(size+2, bulkChunkBytes))
+		if _, err := io.CopyN(&buf, r, int64(size+2)); err != nil {
+			return nil, err
+		}
+		data := buf.Bytes()
+		if data[size] != '\r' || data[size+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string is not terminated", errProtocol)
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxLineBytes {
+			return "", fmt.Errorf("%w: too big inline request", errProtocol)
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// writer кодирует ответы в RESP2 или RESP3 (после HELLO 3)
+type writer struct {
+	*bufio.Writer
+	proto int
+}
+
+func (w *writer) simple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+// error пишет ошибку; первое слово - код (ERR, NOAUTH, NOPERM, ...)
+func (w *writer) error(s string) {
+	w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(s) + "\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w *writer) bulk(s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func (w *writer) null() {
+	if w.proto >= 3 {
+		w.WriteString("_\r\n")
+		return
+	}
+	w.WriteString("$-1\r\n")
+}
+
+func (w *writer) nullArray() {
+	if w.proto >= 3 {
+		w.WriteString("_\r\n")
+		return
+	}
+	w.WriteString("*-1\r\n")
+}
+
+func (w *writer) array(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// mapHeader в RESP2 - плоский массив ключей и значений
+func (w *writer) mapHeader(n int) {
+	if w.proto >= 3 {
+		w.WriteString("%" + strconv.Itoa(n) + "\r\n")
+		return
+	}
+	w.array(2 * n)
+}
+
+func (w *writer) bulks(values ...string) {
+	w.array(len(values))
+	for _, v := range values {
+		w.bulk(v)
+	}
+}
@@ -0,0 +1,343 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/service"
+)
+
+const handshakeTimeout = 10 * time.Second
+
+var ErrServerClosed = errors.New("resp: server closed")
+
+type Config struct {
+	Addr string
+	// Тип задач списков: их создают LPUSH/RPUSH и выдает BRPOP.
+	// Должен быть в REMOTE_TASK_TYPES, чтобы задачи ждали потребителя.
+	TaskType   string
+	MaxRetries int
+	// nil - без аутентификации
+	Authenticator auth.Authenticator
+	TLSConfig     *tls.Config
+	// Запасной опрос очереди блокирующими командами, основной сигнал - события задач
+	PollInterval time.Duration
+}
+
+// Server принимает клиентов Redis (RESP2/RESP3) и отображает
+// команды списков и TQ.* на QueueService
+type Server struct {
+	cfg     Config
+	service service.QueueService
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*conn]struct{}
+	closed   bool
+	nextID   atomic.Int64
+	wg       sync.WaitGroup
+}
+
+func NewServer(cfg Config, queueService service.QueueService) *Server {
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	return &Server{
+		cfg:     cfg,
+		service: queueService,
+		conns:   make(map[*conn]struct{}),
+	}
+}
+
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	if s.cfg.TLSConfig != nil {
+		listener = tls.NewListener(listener, s.cfg.TLSConfig)
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		s.serveConn(netConn)
+	}
+}
+
+// Addr - адрес слушателя, nil до Serve
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close закрывает слушатель и все соединения; блокирующие команды прерываются
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for c := range s.conns {
+		c.close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(netConn net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &conn{
+		server:  s,
+		netConn: netConn,
+		id:      s.nextID.Add(1),
+		w:       &writer{Writer: bufio.NewWriter(netConn), proto: 2},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	c.authenticated.Store(s.cfg.Authenticator == nil)
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		c.close()
+		return
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	respConnections.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+			respConnections.Add(-1)
+		}()
+		c.serve()
+	}()
+}
+
+type request struct {
+	args []string
+	err  error
+}
+
+type conn struct {
+	server  *Server
+	netConn net.Conn
+	id      int64
+	name    string
+	w       *writer
+
+	// Читается и горутиной чтения для лимитов до AUTH
+	authenticated atomic.Bool
+	// nil без аутентификации
+	principal *auth.Principal
+	quit      bool
+
+	// Отменяется, когда клиент отключился или сервер закрывается
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (c *conn) close() {
+	c.cancel()
+	c.netConn.Close()
+}
+
+func (c *conn) serve() {
+	defer c.close()
+	if tlsConn, ok := c.netConn.(*tls.Conn); ok && !c.handshake(tlsConn) {
+		return
+	}
+
+	// Чтение отдельно от выполнения, чтобы заметить отключение клиента во время BRPOP
+	requests := make(chan request, 16)
+	go c.read(requests)
+
+	for req := range requests {
+		if req.err != nil {
+			c.w.error("ERR " + req.err.Error())
+			c.w.Flush()
+			return
+		}
+		c.execute(req.args)
+		if c.quit {
+			c.w.Flush()
+			return
+		}
+		// Ответы на конвейер команд уходят одной пачкой
+		if len(requests) == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) read(requests chan<- request) {
+	defer close(requests)
+	defer c.cancel()
+
+	r := bufio.NewReader(c.netConn)
+	for {
+		limits := preAuthLimits
+		if c.authenticated.Load() {
+			limits = authLimits
+		}
+		args, err := readCommand(r, limits)
+		if err != nil && !errors.Is(err, errProtocol) {
+			return
+		}
+		if err == nil && len(args) == 0 {
+			continue
+		}
+		select {
+		case requests <- request{args: args, err: err}:
+		case <-c.ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handshake завершает TLS и аутентифицирует клиента по сертификату, если он есть
+func (c *conn) handshake(tlsConn *tls.Conn) bool {
+	ctx, cancel := context.WithTimeout(c.ctx, handshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		slog.Debug("RESP TLS handshake failed", "remote_addr", c.remoteAddr(), "error", err)
+		return false
+	}
+	if c.server.cfg.Authenticator == nil {
+		return true
+	}
+	state := tlsConn.ConnectionState()
+	if principal, err := c.server.cfg.Authenticator.Authenticate(&http.Request{TLS: &state, Header: http.Header{}}); err == nil {
+		c.principal = principal
+		c.authenticated.Store(true)
+	}
+	return true
+}
+
+// login проверяет пароль AUTH как bearer токен (API ключ или JWT)
+func (c *conn) login(password string) bool {
+	authenticator := c.server.cfg.Authenticator
+	if authenticator == nil {
+		c.w.error("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		return false
+	}
+	req := &http.Request{Header: http.Header{"Authorization": {"Bearer " + password}}}
+	principal, err := authenticator.Authenticate(req)
+	if err != nil {
+		c.audit("", "AUTH", err.Error())
+		c.w.error("WRONGPASS invalid username-password pair or user is disabled.")
+		return false
+	}
+	c.principal = principal
+	c.authenticated.Store(true)
+	return true
+}
+
+// authorize пишет NOPERM, если ключу не хватает scope или очереди
+func (c *conn) authorize(command string, scope auth.Scope, queues ...string) bool {
+	if c.principal == nil {
+		return true
+	}
+	if !c.principal.HasScope(scope) {
+		c.audit(c.principal.Name, command, "missing scope "+string(scope))
+		c.w.error(fmt.Sprintf("NOPERM this user has no permissions to run the '%s' command", command))
+		return false
+	}
+	for _, q := range queues {
+		if !c.principal.CanAccessQueue(q) {
+			c.audit(c.principal.Name, command, "queue "+q+" not allowed")
+			c.w.error("NOPERM this user has no permissions to access the '" + q + "' queue")
+			return false
+		}
+	}
+	return true
+}
+
+func (c *conn) canAccess(queue, tenant string) bool {
+	return c.principal == nil || (c.principal.CanAccessQueue(queue) && c.principal.CanAccessTenant(tenant))
+}
+
+// principalName - имя ключа соединения; пустое без аутентификации
+func (c *conn) principalName() string {
+	if c.principal == nil {
+		return ""
+	}
+	return c.principal.Name
+}
+
+// tenant - арендатор ключа; пустой для ключей без арендатора
+func (c *conn) tenant() string {
+	if c.principal == nil {
+		return ""
+	}
+	return c.principal.Tenant
+}
+
+func (c *conn) audit(name, command, reason string) {
+	auth.Audit(&http.Request{
+		Method:     "RESP",
+		URL:        &url.URL{Path: command},
+		RemoteAddr: c.remoteAddr(),
+	}, name, reason)
+}
+
+func (c *conn) remoteAddr() string {
+	return c.netConn.RemoteAddr().String()
+}
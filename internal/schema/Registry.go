@@ -0,0 +1,203 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var printer = message.NewPrinter(language.English)
+
+// FieldError - нарушение схемы в одном поле payload
+type FieldError struct {
+	// JSON Pointer поля, "" - весь payload
+	Field   string `json:"field"`
+	Keyword string `json:"keyword,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError - payload не соответствует схеме типа задачи
+type ValidationError struct {
+	TaskType string
+	Errors   []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		field := fieldErr.Field
+		if field == "" {
+			field = "/"
+		}
+		parts[i] = field + ": " + fieldErr.Message
+	}
+	return fmt.Sprintf("payload of task type %s does not match schema: %s", e.TaskType, strings.Join(parts, "; "))
+}
+
+type entry struct {
+	raw      json.RawMessage
+	compiled *jsonschema.Schema
+}
+
+// Registry хранит JSON Schema payload по типам задач
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[string]*entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{schemas: make(map[string]*entry)}
+}
+
+// Register компилирует схему и заменяет прежнюю схему типа
+func (r *Registry) Register(taskType string, raw json.RawMessage) error {
+	if taskType == "" {
+		return errors.New("empty task type")
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("invalid schema JSON: %v", err)
+	}
+	compiler := jsonschema.NewCompiler()
+	// $ref только внутри схемы: загрузка файлов и URL запрещена
+	compiler.UseLoader(jsonschema.SchemeURLLoader{})
+	compiler.AssertFormat()
+	location := "taskqueue:///schemas/" + url.PathEscape(taskType) + ".json"
+	if err := compiler.AddResource(location, doc); err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+	compiled, err := compiler.Compile(location)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas[taskType] = &entry{raw: append(json.RawMessage(nil), raw...), compiled: compiled}
+	return nil
+}
+
+// Get возвращает схему в том виде, в каком она была зарегистрирована
+func (r *Registry) Get(taskType string) (json.RawMessage, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, exists := r.schemas[taskType]
+	if !exists {
+		return nil, false
+	}
+	return e.raw, true
+}
+
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.schemas))
+	for taskType := range r.schemas {
+		types = append(types, taskType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Validate проверяет payload по схеме типа. Тип без схемы принимает любой payload.
+func (r *Registry) Validate(taskType string, payload json.RawMessage) error {
+	r.mu.RLock()
+	e, exists := r.schemas[taskType]
+	r.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return &ValidationError{TaskType: taskType, Errors: []FieldError{{Message: "payload is not valid JSON"}}}
+	}
+	err = e.compiled.Validate(doc)
+	var schemaErr *jsonschema.ValidationError
+	if !errors.As(err, &schemaErr) {
+		return err
+	}
+	fieldErrors := collect(schemaErr, nil)
+	sort.SliceStable(fieldErrors, func(i, j int) bool {
+		return fieldErrors[i].Field < fieldErrors[j].Field
+	})
+	return &ValidationError{TaskType: taskType, Errors: fieldErrors}
+}
+
+// collect разворачивает дерево ошибок до листьев. Альтернативы anyOf/oneOf
+// не разворачиваются: ошибка каждой из них сама по себе не нарушение.
+func collect(err *jsonschema.ValidationError, out []FieldError) []FieldError {
+	switch err.ErrorKind.(type) {
+	case *kind.Group, *kind.Schema, *kind.Reference, *kind.AllOf:
+		if len(err.Causes) > 0 {
+			for _, cause := range err.Causes {
+				out = collect(cause, out)
+			}
+			return out
+		}
+	}
+
+	field := pointer(err.InstanceLocation)
+	if required, ok := err.ErrorKind.(*kind.Required); ok {
+		for _, name := range required.Missing {
+			out = append(out, FieldError{
+				Field:   field + "/" + escape(name),
+				Keyword: "required",
+				Message: "is required",
+			})
+		}
+		return out
+	}
+
+	keyword := ""
+	if path := err.ErrorKind.KeywordPath(); len(path) > 0 {
+		keyword = path[len(path)-1]
+	}
+	return append(out, FieldError{
+		Field:   field,
+		Keyword: keyword,
+		Message: err.ErrorKind.LocalizedString(printer),
+	})
+}
+
+func pointer(tokens []string) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString("/")
+		sb.WriteString(escape(token))
+	}
+	return sb.String()
+}
+
+func escape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// ReadDir читает схемы из файлов <тип>.json каталога dir
+func ReadDir(dir string) (map[string]json.RawMessage, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]json.RawMessage, len(files))
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		schemas[strings.TrimSuffix(filepath.Base(file), ".json")] = raw
+	}
+	return schemas, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"strings"
+
+	"TaskQueue/internal/model"
+	"TaskQueue/queue"
+)
+
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// AccessFunc ограничивает выдачу очередями и арендаторами запрашивающего
+type AccessFunc func(queue, tenant string) bool
+
+// TaskFilter - условия поиска задач; пустые поля не фильтруют
+type TaskFilter struct {
+	Status string
+	Queue  string
+	Type   string
+	Tenant string
+	// Подстрока в ID задачи
+	Query string
+	// Ограничение по правам запрашивающего
+	Allowed AccessFunc
+	Limit   int
+}
+
+func (f TaskFilter) matches(task model.TaskSnapshot) bool {
+	return (f.Status == "" || task.Status == f.Status) &&
+		(f.Queue == "" || task.Queue == f.Queue) &&
+		(f.Type == "" || task.Type == f.Type) &&
+		(f.Tenant == "" || task.Tenant == f.Tenant) &&
+		(f.Query == "" || strings.Contains(task.ID, f.Query)) &&
+		(f.Allowed == nil || f.Allowed(task.Queue, task.Tenant))
+}
+
+// Stats - состояние пула и число задач по очередям, арендаторам и статусам
+type Stats struct {
+	queue.PoolStats
+	Queues  map[string]map[string]int `json:"queues"`
+	Tenants map[string]map[string]int `json:"tenants"`
+}
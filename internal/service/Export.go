@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+
+	"TaskQueue/internal/model"
+)
+
+// ConflictMode - что делать при импорте задачи с существующим id
+type ConflictMode string
+
+const (
+	ConflictSkip      ConflictMode = "skip"
+	ConflictOverwrite ConflictMode = "overwrite"
+	ConflictFail      ConflictMode = "fail"
+)
+
+// MaxImportLineBytes - предел размера одной строки JSONL
+const MaxImportLineBytes = 16 << 20
+
+var (
+	ErrImportConflict  = errors.New("task already exists")
+	ErrImportForbidden = errors.New("queue or tenant not allowed")
+)
+
+func ParseConflictMode(s string) (ConflictMode, error) {
+	switch ConflictMode(s) {
+	case "", ConflictSkip:
+		return ConflictSkip, nil
+	case ConflictOverwrite, ConflictFail:
+		return ConflictMode(s), nil
+	}
+	return "", fmt.Errorf("unknown conflict mode %q", s)
+}
+
+// ImportError указывает строку файла, на которой импорт остановлен
+type ImportError struct {
+	Line int
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+type ImportResult struct {
+	Imported    int `json:"imported"`
+	Skipped     int `json:"skipped"`
+	Overwritten int `json:"overwritten"`
+	// Задачи в статусе queued, поставленные в очередь пула
+	Requeued int `json:"requeued"`
+}
+
+// Export пишет задачи в формате JSONL, старые первыми, чтобы импорт сохранил порядок.
+// Limit фильтра не применяется.
+func (s *queueService) Export(w io.Writer, filter TaskFilter) (int, error) {
+	var snapshots []model.TaskSnapshot
+	for _, task := range s.taskRepo.GetAll() {
+		snapshot := task.Snapshot()
+		if filter.matches(snapshot) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+		}
+		return snapshots[i].ID < snapshots[j].ID
+	})
+
+	encoder := json.NewEncoder(w)
+	for i, snapshot := range snapshots {
+		if err := encoder.Encode(snapshot); err != nil {
+			return i, err
+		}
+	}
+	return len(snapshots), nil
+}
+
+// Import загружает задачи из JSONL. Файл сначала разбирается целиком: при ошибке
+// разбора, запрещенной очереди или конфликте в режиме fail ничего не импортируется.
+// Задачи в running считаются прерванными и возвращаются в очередь без учета попытки.
+func (s *queueService) Import(r io.Reader, mode ConflictMode, allowed AccessFunc) (ImportResult, error) {
+	var result ImportResult
+	tasks, err := s.readImport(r, mode, allowed)
+	if err != nil {
+		return result, err
+	}
+
+	for _, task := range tasks {
+		if existing, exists := s.taskRepo.GetByID(task.ID); exists {
+			if mode == ConflictSkip {
+				result.Skipped++
+				continue
+			}
+			// Старая версия задачи не должна продолжать выполняться
+			if !model.IsTerminalStatus(existing.GetStatus()) {
+				s.workerPool.Cancel(existing)
+			}
+			s.taskRepo.Delete(task.ID)
+			result.Overwritten++
+		}
+
+		if err := s.taskRepo.Create(task); err != nil {
+			return result, err
+		}
+		result.Imported++
+		if task.Status == "queued" {
+			s.workerPool.Restore(task)
+			result.Requeued++
+		}
+	}
+
+	slog.Info("Tasks imported", "mode", mode, "imported", result.Imported,
+		"skipped", result.Skipped, "overwritten", result.Overwritten, "requeued", result.Requeued)
+	return result, nil
+}
+
+func (s *queueService) readImport(r io.Reader, mode ConflictMode, allowed AccessFunc) ([]*model.Task, error) {
+	var tasks []*model.Task
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxImportLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+		var snapshot model.TaskSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, &ImportError{Line: line, Err: err}
+		}
+		if snapshot.ID == "" {
+			return nil, &ImportError{Line: line, Err: errors.New("missing id")}
+		}
+
+		task := model.TaskFromSnapshot(snapshot)
+		if task.Queue == "" {
+			task.Queue = model.DefaultQueue
+		}
+		if task.Type == "" {
+			task.Type = model.DefaultType
+		}
+		if task.Tenant == "" {
+			task.Tenant = model.DefaultTenant
+		}
+		switch task.Status {
+		case "", "running":
+			task.Status = "queued"
+		case "queued", "done", "failed", "cancelled", "quarantined":
+		default:
+			return nil, &ImportError{Line: line, Err: fmt.Errorf("unknown status %q", task.Status)}
+		}
+		if allowed != nil && !allowed(task.Queue, task.Tenant) {
+			return nil, &ImportError{Line: line, Err: ErrImportForbidden}
+		}
+		if mode == ConflictFail && (seen[task.ID] || s.taskRepo.Exists(task.ID)) {
+			return nil, &ImportError{Line: line, Err: fmt.Errorf("task %s: %w", task.ID, ErrImportConflict)}
+		}
+		seen[task.ID] = true
+		tasks = append(tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
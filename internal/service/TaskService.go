@@ -1,16 +1,75 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
 
+	"TaskQueue/internal/events"
 	"TaskQueue/internal/model"
 	"TaskQueue/internal/repository"
+	"TaskQueue/internal/schema"
+	"TaskQueue/internal/tasklog"
 	"TaskQueue/queue"
 )
 
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskExists   = errors.New("already exists")
+	// Задача выполняется, ждет повтора или отложена breaker
+	ErrTaskNotWaiting = errors.New("task is not waiting in the queue")
+)
+
 type QueueService interface {
 	Enqueue(task *model.Task) error
+	EnqueueContext(ctx context.Context, task *model.Task) error
+	GetTask(id string) (*model.Task, bool)
 	GetTaskStatus(id string) (string, bool)
+	RegisterHandler(taskType string, handler queue.Handler)
+	Use(middlewares ...queue.Middleware)
+	UseFor(taskType string, middlewares ...queue.Middleware)
+
+	// Схемы payload по типам задач; Enqueue отклоняет несоответствующие
+	// с *schema.ValidationError
+	RegisterSchema(taskType string, raw json.RawMessage) error
+	Schema(taskType string) (json.RawMessage, bool)
+	SchemaTypes() []string
+
+	Lease(req queue.LeaseRequest) []queue.LeasedTask
+	Heartbeat(taskID string, holder queue.LeaseHolder, timeout time.Duration) (time.Time, error)
+	Progress(taskID string, holder queue.LeaseHolder, update queue.ProgressUpdate) (time.Time, error)
+	Complete(taskID string, holder queue.LeaseHolder) error
+	Fail(taskID string, holder queue.LeaseHolder, reason string) error
+
+	Subscribe(buffer int) (<-chan events.Event, func())
+	TaskLogs(taskID string, attempt int) (*tasklog.Buffer, int, bool)
+
+	ListTasks(filter TaskFilter) []model.TaskSnapshot
+	Stats(allowed AccessFunc) Stats
+	// QueueDepth - ожидающие задачи очереди по счетчику пула, без обхода хранилища
+	QueueDepth(queue string, allowed AccessFunc) int
+	Cancel(id string) error
+	Replay(id string) error
+	Release(id string) error
+	Breakers() []queue.BreakerStatus
+	ResetBreaker(key string) error
+
+	// Export и Import переносят задачи между экземплярами в формате JSONL
+	Export(w io.Writer, filter TaskFilter) (int, error)
+	Import(r io.Reader, mode ConflictMode, allowed AccessFunc) (ImportResult, error)
+
+	// Handoff передает ожидающую задачу другому узлу (шардирование)
+	Handoff(id string, send func(model.TaskSnapshot) error) error
+
+	// Suspend и Resume переключают пул узла кластера при смене лидера
+	Suspend()
+	Resume()
+
 	StartWorkers()
 	Shutdown()
 }
@@ -18,40 +77,71 @@ type QueueService interface {
 type queueService struct {
 	taskRepo   repository.TaskRepository
 	workerPool queue.WorkerPool
+	schemas    *schema.Registry
 	workers    int
 	queueSize  int
 }
 
-func NewQueueService(taskRepo repository.TaskRepository, workers, queueSize int) QueueService {
-	workerPool := queue.NewWorkerPool(workers, queueSize, taskRepo)
+func NewQueueService(taskRepo repository.TaskRepository, workers, queueSize int, opts ...queue.Option) QueueService {
+	workerPool := queue.NewWorkerPool(workers, queueSize, taskRepo, opts...)
 	return &queueService{
 		taskRepo:   taskRepo,
 		workerPool: workerPool,
+		schemas:    schema.NewRegistry(),
 		workers:    workers,
 		queueSize:  queueSize,
 	}
 }
 
 func (s *queueService) Enqueue(task *model.Task) error {
+	return s.EnqueueContext(context.Background(), task)
+}
+
+func (s *queueService) EnqueueContext(ctx context.Context, task *model.Task) error {
 	if s.taskRepo.Exists(task.ID) {
-		return fmt.Errorf("task with id %s already exists", task.ID)
+		return fmt.Errorf("task with id %s %w", task.ID, ErrTaskExists)
 	}
 
+	if task.Queue == "" {
+		task.Queue = model.DefaultQueue
+	}
+	if task.Type == "" {
+		task.Type = model.DefaultType
+	}
+	if task.Tenant == "" {
+		task.Tenant = model.DefaultTenant
+	}
+	if err := s.validatePayload(task); err != nil {
+		return err
+	}
+	task.CreatedAt = time.Now()
 	task.SetStatus("queued")
 
 	if err := s.taskRepo.Create(task); err != nil {
 		return err
 	}
 
-	if err := s.workerPool.Enqueue(task); err != nil {
-		task.SetStatus("failed")
-		s.taskRepo.Update(task)
-		return fmt.Errorf("failed to enqueue task: %v", err)
+	if err := s.workerPool.EnqueueContext(ctx, task); err != nil {
+		// Клиент может повторить задачу с тем же id после Retry-After
+		var limitErr *queue.TenantLimitError
+		if errors.Is(err, queue.ErrQueueFull) || errors.As(err, &limitErr) {
+			s.taskRepo.Delete(task.ID)
+		} else {
+			task.SetStatus("failed")
+			s.taskRepo.Update(task)
+		}
+		return fmt.Errorf("failed to enqueue task: %w", err)
 	}
 
+	slog.Info("Task enqueued", "task_id", task.ID, "type", task.Type,
+		"queue", task.Queue, "request_id", task.RequestID)
 	return nil
 }
 
+func (s *queueService) GetTask(id string) (*model.Task, bool) {
+	return s.taskRepo.GetByID(id)
+}
+
 func (s *queueService) GetTaskStatus(id string) (string, bool) {
 	task, exists := s.taskRepo.GetByID(id)
 	if !exists {
@@ -60,6 +150,180 @@ func (s *queueService) GetTaskStatus(id string) (string, bool) {
 	return task.GetStatus(), true
 }
 
+func (s *queueService) RegisterHandler(taskType string, handler queue.Handler) {
+	s.workerPool.RegisterHandler(taskType, handler)
+}
+
+func (s *queueService) Use(middlewares ...queue.Middleware) {
+	s.workerPool.Use(middlewares...)
+}
+
+func (s *queueService) UseFor(taskType string, middlewares ...queue.Middleware) {
+	s.workerPool.UseFor(taskType, middlewares...)
+}
+
+func (s *queueService) RegisterSchema(taskType string, raw json.RawMessage) error {
+	return s.schemas.Register(taskType, raw)
+}
+
+// validatePayload сверяет payload со схемой типа. Схема описывает JSON,
+// поэтому текстовый payload для типа со схемой не принимается.
+func (s *queueService) validatePayload(task *model.Task) error {
+	if model.IsJSONContentType(task.ContentType) {
+		return s.schemas.Validate(task.Type, task.Payload)
+	}
+	if _, exists := s.schemas.Get(task.Type); !exists {
+		return nil
+	}
+	return &schema.ValidationError{TaskType: task.Type, Errors: []schema.FieldError{{
+		Keyword: "contentType",
+		Message: "content type " + task.ContentType + " is not JSON",
+	}}}
+}
+
+func (s *queueService) Schema(taskType string) (json.RawMessage, bool) {
+	return s.schemas.Get(taskType)
+}
+
+func (s *queueService) SchemaTypes() []string {
+	return s.schemas.Types()
+}
+
+func (s *queueService) Lease(req queue.LeaseRequest) []queue.LeasedTask {
+	return s.workerPool.Lease(req)
+}
+
+func (s *queueService) Heartbeat(taskID string, holder queue.LeaseHolder, timeout time.Duration) (time.Time, error) {
+	return s.workerPool.Heartbeat(taskID, holder, timeout)
+}
+
+func (s *queueService) Progress(taskID string, holder queue.LeaseHolder, update queue.ProgressUpdate) (time.Time, error) {
+	return s.workerPool.Progress(taskID, holder, update)
+}
+
+func (s *queueService) Complete(taskID string, holder queue.LeaseHolder) error {
+	return s.workerPool.Complete(taskID, holder)
+}
+
+func (s *queueService) Fail(taskID string, holder queue.LeaseHolder, reason string) error {
+	return s.workerPool.Fail(taskID, holder, reason)
+}
+
+func (s *queueService) Subscribe(buffer int) (<-chan events.Event, func()) {
+	return s.workerPool.Subscribe(buffer)
+}
+
+func (s *queueService) TaskLogs(taskID string, attempt int) (*tasklog.Buffer, int, bool) {
+	return s.workerPool.TaskLogs(taskID, attempt)
+}
+
+func (s *queueService) Cancel(id string) error {
+	task, exists := s.taskRepo.GetByID(id)
+	if !exists {
+		return ErrTaskNotFound
+	}
+	return s.workerPool.Cancel(task)
+}
+
+func (s *queueService) Replay(id string) error {
+	task, exists := s.taskRepo.GetByID(id)
+	if !exists {
+		return ErrTaskNotFound
+	}
+	return s.workerPool.Replay(task)
+}
+
+func (s *queueService) Release(id string) error {
+	task, exists := s.taskRepo.GetByID(id)
+	if !exists {
+		return ErrTaskNotFound
+	}
+	return s.workerPool.Release(task)
+}
+
+func (s *queueService) Breakers() []queue.BreakerStatus {
+	return s.workerPool.Breakers()
+}
+
+func (s *queueService) ResetBreaker(key string) error {
+	return s.workerPool.ResetBreaker(key)
+}
+
+// Handoff убирает задачу из очереди пула и отправляет снимок через send.
+// После успеха задача удаляется из хранилища, при ошибке возвращается в очередь.
+func (s *queueService) Handoff(id string, send func(model.TaskSnapshot) error) error {
+	task, exists := s.taskRepo.GetByID(id)
+	if !exists {
+		return ErrTaskNotFound
+	}
+	if !s.workerPool.Remove(task) {
+		return ErrTaskNotWaiting
+	}
+	if err := send(task.Snapshot()); err != nil {
+		s.workerPool.Restore(task)
+		return err
+	}
+	s.taskRepo.Delete(id)
+	slog.Info("Task handed off", "task_id", id, "queue", task.Queue)
+	return nil
+}
+
+func (s *queueService) Suspend() {
+	s.workerPool.Suspend()
+}
+
+func (s *queueService) Resume() {
+	s.workerPool.Resume()
+}
+
+func (s *queueService) ListTasks(filter TaskFilter) []model.TaskSnapshot {
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxListLimit {
+		limit = DefaultListLimit
+	}
+
+	var result []model.TaskSnapshot
+	for _, task := range s.taskRepo.GetAll() {
+		snapshot := task.Snapshot()
+		if filter.matches(snapshot) {
+			result = append(result, snapshot)
+		}
+	}
+
+	// Новые задачи первыми
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (s *queueService) Stats(allowed AccessFunc) Stats {
+	stats := Stats{
+		PoolStats: s.workerPool.Stats(),
+		Queues:    make(map[string]map[string]int),
+		Tenants:   make(map[string]map[string]int),
+	}
+	for _, task := range s.taskRepo.GetAll() {
+		if allowed != nil && !allowed(task.Queue, task.Tenant) {
+			continue
+		}
+		status := task.GetStatus()
+		countStatus(stats.Queues, task.Queue, status)
+		countStatus(stats.Tenants, task.Tenant, status)
+	}
+	return stats
+}
+
+func (s *queueService) QueueDepth(queue string, allowed AccessFunc) int {
+	return s.workerPool.QueueDepth(queue, allowed)
+}
+
 func (s *queueService) StartWorkers() {
 	s.workerPool.Start()
 }
@@ -67,3 +331,12 @@ func (s *queueService) StartWorkers() {
 func (s *queueService) Shutdown() {
 	s.workerPool.Shutdown()
 }
+
+func countStatus(counts map[string]map[string]int, key, status string) {
+	byStatus, exists := counts[key]
+	if !exists {
+		byStatus = make(map[string]int)
+		counts[key] = byStatus
+	}
+	byStatus[status]++
+}
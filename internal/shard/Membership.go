@@ -0,0 +1,329 @@
+package shard
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretHeader - общий секрет узлов для gossip и передачи задач
+const SecretHeader = "X-Shard-Secret"
+
+// Membership - источник состава кольца
+type Membership interface {
+	// Members - живые узлы: id -> базовый URL API
+	Members() map[string]string
+	// Changes сигналит об изменении состава
+	Changes() <-chan struct{}
+	Start()
+	// Leave сообщает остальным узлам об уходе этого
+	Leave()
+	Stop()
+}
+
+// Static - фиксированный состав из конфигурации
+type Static struct {
+	members map[string]string
+}
+
+func NewStatic(members map[string]string) *Static {
+	return &Static{members: members}
+}
+
+func (s *Static) Members() map[string]string {
+	result := make(map[string]string, len(s.members))
+	for id, addr := range s.members {
+		result[id] = addr
+	}
+	return result
+}
+
+func (s *Static) Changes() <-chan struct{} { return nil }
+func (s *Static) Start()                   {}
+func (s *Static) Leave()                   {}
+func (s *Static) Stop()                    {}
+
+// Member - запись таблицы gossip. Heartbeat растет, пока узел жив;
+// побеждает запись с большим значением.
+type Member struct {
+	ID        string `json:"id"`
+	Addr      string `json:"addr"`
+	Heartbeat uint64 `json:"heartbeat"`
+	Left      bool   `json:"left,omitempty"`
+}
+
+type GossipConfig struct {
+	ID   string
+	Addr string
+	// Адреса узлов для первого контакта
+	Seeds    []string
+	Interval time.Duration
+	// Узел без новых heartbeat дольше FailTimeout исключается из кольца
+	FailTimeout time.Duration
+	// Сколько случайных узлов опрашивается за раунд
+	Fanout int
+	Secret string
+	Client *http.Client
+}
+
+type memberState struct {
+	Member
+	seen time.Time
+}
+
+// Gossip - членство по протоколу push-pull: каждый раунд узел обменивается
+// таблицей с несколькими случайными узлами
+type Gossip struct {
+	cfg    GossipConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	members map[string]*memberState
+	alive   map[string]string
+
+	changes  chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewGossip(cfg GossipConfig) *Gossip {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.FailTimeout <= 0 {
+		cfg.FailTimeout = 5 * cfg.Interval
+	}
+	if cfg.Fanout <= 0 {
+		cfg.Fanout = 2
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Interval}
+	}
+	cfg.Addr = strings.TrimRight(cfg.Addr, "/")
+
+	g := &Gossip{
+		cfg:     cfg,
+		client:  client,
+		members: make(map[string]*memberState),
+		changes: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	// Heartbeat от текущего времени, чтобы перезапущенный узел не проиграл старой записи
+	g.members[cfg.ID] = &memberState{
+		Member: Member{ID: cfg.ID, Addr: cfg.Addr, Heartbeat: uint64(time.Now().UnixNano())},
+		seen:   time.Now(),
+	}
+	g.alive = map[string]string{cfg.ID: cfg.Addr}
+	return g
+}
+
+func (g *Gossip) Members() map[string]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	result := make(map[string]string, len(g.alive))
+	for id, addr := range g.alive {
+		result[id] = addr
+	}
+	return result
+}
+
+func (g *Gossip) Changes() <-chan struct{} {
+	return g.changes
+}
+
+func (g *Gossip) Start() {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ticker := time.NewTicker(g.cfg.Interval)
+		defer ticker.Stop()
+
+		g.round()
+		for {
+			select {
+			case <-g.stop:
+				return
+			case <-ticker.C:
+				g.round()
+			}
+		}
+	}()
+}
+
+func (g *Gossip) Stop() {
+	g.stopOnce.Do(func() {
+		close(g.stop)
+		g.wg.Wait()
+	})
+}
+
+// Leave помечает узел ушедшим и рассылает таблицу всем известным узлам
+func (g *Gossip) Leave() {
+	g.mu.Lock()
+	self := g.members[g.cfg.ID]
+	self.Left = true
+	self.Heartbeat++
+	table := g.tableLocked()
+	var targets []string
+	for id, addr := range g.alive {
+		if id != g.cfg.ID {
+			targets = append(targets, addr)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, addr := range targets {
+		g.exchange(addr, table)
+	}
+	g.refresh()
+	slog.Info("Left shard ring", "node", g.cfg.ID)
+}
+
+func (g *Gossip) round() {
+	g.mu.Lock()
+	self := g.members[g.cfg.ID]
+	if !self.Left {
+		self.Heartbeat++
+	}
+	self.seen = time.Now()
+	table := g.tableLocked()
+
+	var peers []string
+	for id, addr := range g.alive {
+		if id != g.cfg.ID {
+			peers = append(peers, addr)
+		}
+	}
+	g.mu.Unlock()
+
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > g.cfg.Fanout {
+		peers = peers[:g.cfg.Fanout]
+	}
+	// Пока никто не известен - через seeds
+	if len(peers) == 0 {
+		for _, seed := range g.cfg.Seeds {
+			if seed = strings.TrimRight(seed, "/"); seed != g.cfg.Addr {
+				peers = append(peers, seed)
+			}
+		}
+	}
+	for _, addr := range peers {
+		g.exchange(addr, table)
+	}
+	g.refresh()
+}
+
+// tableLocked вызывается под mu
+func (g *Gossip) tableLocked() []Member {
+	table := make([]Member, 0, len(g.members))
+	for _, m := range g.members {
+		table = append(table, m.Member)
+	}
+	return table
+}
+
+func (g *Gossip) exchange(addr string, table []Member) {
+	body, _ := json.Marshal(table)
+	req, err := http.NewRequest(http.MethodPost, addr+"/shard/gossip", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SecretHeader, g.cfg.Secret)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		slog.Debug("Gossip exchange failed", "peer", addr, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Debug("Gossip exchange rejected", "peer", addr, "status", resp.StatusCode)
+		return
+	}
+	var remote []Member
+	if err := json.NewDecoder(resp.Body).Decode(&remote); err == nil {
+		g.merge(remote)
+	}
+}
+
+func (g *Gossip) merge(remote []Member) {
+	now := time.Now()
+	g.mu.Lock()
+	for _, m := range remote {
+		if m.ID == "" || m.ID == g.cfg.ID {
+			continue
+		}
+		if current, exists := g.members[m.ID]; !exists || m.Heartbeat > current.Heartbeat {
+			g.members[m.ID] = &memberState{Member: m, seen: now}
+		}
+	}
+	g.mu.Unlock()
+}
+
+// refresh пересчитывает живые узлы и сигналит об изменении
+func (g *Gossip) refresh() {
+	now := time.Now()
+	g.mu.Lock()
+	alive := make(map[string]string)
+	for id, m := range g.members {
+		if m.Left {
+			continue
+		}
+		if id == g.cfg.ID || now.Sub(m.seen) < g.cfg.FailTimeout {
+			alive[id] = m.Addr
+		}
+	}
+	changed := len(alive) != len(g.alive)
+	for id, addr := range alive {
+		if g.alive[id] != addr {
+			changed = true
+		}
+	}
+	g.alive = alive
+	g.mu.Unlock()
+
+	if changed {
+		select {
+		case g.changes <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Handler обслуживает POST /shard/gossip
+func (g *Gossip) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkSecret(r, g.cfg.Secret) {
+			http.Error(w, "Invalid shard secret", http.StatusUnauthorized)
+			return
+		}
+		var remote []Member
+		if err := json.NewDecoder(r.Body).Decode(&remote); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid gossip table: %v", err), http.StatusBadRequest)
+			return
+		}
+		g.merge(remote)
+		g.refresh()
+
+		g.mu.Lock()
+		table := g.tableLocked()
+		g.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(table)
+	})
+}
+
+// checkSecret: без секрета узла запросы узлов не принимаются
+func checkSecret(r *http.Request, secret string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretHeader)), []byte(secret)) == 1
+}
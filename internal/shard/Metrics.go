@@ -0,0 +1,12 @@
+package shard
+
+import "TaskQueue/internal/metrics"
+
+var (
+	shardMembers = metrics.Default.Gauge("taskqueue_shard_members",
+		"Nodes in the shard ring as seen by the node.", "node")
+	shardForwarded = metrics.Default.Counter("taskqueue_shard_forwarded_total",
+		"Requests routed to another shard node.", "kind")
+	shardHandoffs = metrics.Default.Counter("taskqueue_shard_handoffs_total",
+		"Waiting tasks handed off to their new owner after a ring change.", "result")
+)
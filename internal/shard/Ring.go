@@ -0,0 +1,115 @@
+// Package shard распределяет очереди по узлам консистентным хешированием
+package shard
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"sync"
+
+	"TaskQueue/internal/model"
+)
+
+// DefaultVirtualNodes - точек на кольце у каждого узла
+const DefaultVirtualNodes = 64
+
+// Ring - кольцо консистентного хеширования с виртуальными узлами. При добавлении
+// или удалении узла переезжает примерно 1/N ключей.
+type Ring struct {
+	vnodes int
+
+	mu      sync.RWMutex
+	points  []uint64
+	owners  map[uint64]string
+	members []string
+}
+
+func NewRing(vnodes int, members ...string) *Ring {
+	if vnodes <= 0 {
+		vnodes = DefaultVirtualNodes
+	}
+	r := &Ring{vnodes: vnodes}
+	r.Set(members)
+	return r
+}
+
+func hashKey(key string) uint64 {
+	sum := sha1.Sum([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// Set заменяет состав кольца; false - состав не изменился
+func (r *Ring) Set(members []string) bool {
+	sorted := append([]string(nil), members...)
+	sort.Strings(sorted)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if equalMembers(sorted, r.members) {
+		return false
+	}
+
+	r.members = sorted
+	r.points = r.points[:0]
+	r.owners = make(map[uint64]string, len(sorted)*r.vnodes)
+	for _, member := range sorted {
+		for i := 0; i < r.vnodes; i++ {
+			point := hashKey(member + "#" + strconv.Itoa(i))
+			// Коллизия: точку получает меньший id, чтобы результат не зависел от порядка
+			if owner, exists := r.owners[point]; exists && owner < member {
+				continue
+			}
+			if _, exists := r.owners[point]; !exists {
+				r.points = append(r.points, point)
+			}
+			r.owners[point] = member
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return true
+}
+
+func equalMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Owner - узел, отвечающий за ключ; false для пустого кольца
+func (r *Ring) Owner(key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.points) == 0 {
+		return "", false
+	}
+	hash := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]], true
+}
+
+func (r *Ring) Members() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.members...)
+}
+
+// Key - ключ шардирования задачи: partition_key или очередь
+func Key(queue, partitionKey string) string {
+	if partitionKey != "" {
+		return partitionKey
+	}
+	if queue == "" {
+		return model.DefaultQueue
+	}
+	return queue
+}
@@ -0,0 +1,461 @@
+package shard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/service"
+)
+
+// ForwardedHeader помечает запрос, пересланный другим узлом: он обслуживается локально
+const ForwardedHeader = "X-TaskQueue-Shard-Forwarded"
+
+// PrincipalHeader - принципал клиента перенаправленного запроса (JSON)
+const PrincipalHeader = "X-TaskQueue-Shard-Principal"
+
+var errInvalidSecret = errors.New("invalid shard secret")
+
+type Config struct {
+	NodeID       string
+	Secret       string
+	VirtualNodes int
+	// Периодическая проверка, что ожидающие задачи лежат на своих узлах
+	RebalanceInterval time.Duration
+	Client            *http.Client
+}
+
+// Router направляет задачи на узел-владелец ключа и переносит ожидающие
+// задачи при изменении состава кольца
+type Router struct {
+	cfg        Config
+	membership Membership
+	ring       *Ring
+	service    service.QueueService
+	client     *http.Client
+
+	mu    sync.RWMutex
+	addrs map[string]string
+
+	leaving  atomic.Bool
+	trigger  chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewRouter(cfg Config, membership Membership, queueService service.QueueService) *Router {
+	if cfg.RebalanceInterval <= 0 {
+		cfg.RebalanceInterval = 30 * time.Second
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &Router{
+		cfg:        cfg,
+		membership: membership,
+		ring:       NewRing(cfg.VirtualNodes),
+		service:    queueService,
+		client:     client,
+		addrs:      make(map[string]string),
+		trigger:    make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+	}
+}
+
+func (r *Router) Start() {
+	r.membership.Start()
+	r.refresh()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.cfg.RebalanceInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-r.membership.Changes():
+				if !r.refresh() {
+					continue
+				}
+			case <-r.trigger:
+			case <-ticker.C:
+			}
+			for r.rebalance() > 0 {
+			}
+		}
+	}()
+}
+
+// Leave выводит узел из кольца и передает его ожидающие задачи остальным.
+// Выполняемые задачи завершаются на этом узле.
+func (r *Router) Leave() int {
+	r.leaving.Store(true)
+	r.membership.Leave()
+	r.refresh()
+
+	moved := 0
+	for {
+		n := r.rebalance()
+		if n == 0 {
+			return moved
+		}
+		moved += n
+	}
+}
+
+func (r *Router) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		r.wg.Wait()
+		r.membership.Stop()
+	})
+}
+
+// Rebalance запускает внеочередную проверку размещения задач
+func (r *Router) Rebalance() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// refresh перестраивает кольцо; true - состав изменился
+func (r *Router) refresh() bool {
+	members := r.membership.Members()
+	if r.leaving.Load() {
+		delete(members, r.cfg.NodeID)
+	}
+	ids := make([]string, 0, len(members))
+	for id := range members {
+		ids = append(ids, id)
+	}
+
+	r.mu.Lock()
+	r.addrs = members
+	r.mu.Unlock()
+	changed := r.ring.Set(ids)
+	if changed {
+		shardMembers.Set(float64(len(ids)), r.cfg.NodeID)
+		slog.Info("Shard ring changed", "node", r.cfg.NodeID, "members", r.ring.Members())
+	}
+	return changed
+}
+
+// Owner - узел и его адрес для ключа шардирования
+func (r *Router) Owner(key string) (string, string, bool) {
+	owner, ok := r.ring.Owner(key)
+	if !ok {
+		return "", "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return owner, r.addrs[owner], true
+}
+
+// rebalance передает владельцам ожидающие задачи чужих ключей. Задачи
+// в бэкоффе и выполняемые остаются до следующего прохода.
+func (r *Router) rebalance() int {
+	moved := 0
+	for _, snapshot := range r.service.ListTasks(service.TaskFilter{Status: "queued", Limit: service.MaxListLimit}) {
+		owner, addr, ok := r.Owner(Key(snapshot.Queue, snapshot.PartitionKey))
+		if !ok || owner == r.cfg.NodeID {
+			continue
+		}
+		err := r.service.Handoff(snapshot.ID, func(task model.TaskSnapshot) error {
+			return r.sendHandoff(addr, task)
+		})
+		switch {
+		case err == nil:
+			moved++
+			shardHandoffs.Inc("ok")
+		case errors.Is(err, service.ErrTaskNotWaiting), errors.Is(err, service.ErrTaskNotFound):
+		default:
+			shardHandoffs.Inc("error")
+			slog.Warn("Failed to hand off task", "task_id", snapshot.ID, "owner", owner, "error", err)
+		}
+	}
+	if moved > 0 {
+		slog.Info("Shard rebalance moved tasks", "node", r.cfg.NodeID, "count", moved)
+	}
+	return moved
+}
+
+func (r *Router) sendHandoff(addr string, task model.TaskSnapshot) error {
+	body, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, addr+"/shard/handoff", bytes.NewReader(append(body, '\n')))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set(SecretHeader, r.cfg.Secret)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("handoff to %s: %s: %s", addr, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Register добавляет POST /shard/handoff, GET /shard/status и gossip, если он используется
+func (r *Router) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /shard/handoff", r.HandoffHandler)
+	mux.HandleFunc("GET /shard/status", r.StatusHandler)
+	if gossip, ok := r.membership.(*Gossip); ok {
+		mux.Handle("POST /shard/gossip", gossip.Handler())
+	}
+}
+
+// HandoffHandler принимает задачи, переданные другим узлом
+func (r *Router) HandoffHandler(w http.ResponseWriter, req *http.Request) {
+	if !checkSecret(req, r.cfg.Secret) {
+		http.Error(w, "Invalid shard secret", http.StatusUnauthorized)
+		return
+	}
+	result, err := r.service.Import(req.Body, service.ConflictSkip, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+type Status struct {
+	Node    string            `json:"node"`
+	Leaving bool              `json:"leaving,omitempty"`
+	Members map[string]string `json:"members"`
+	Key     string            `json:"key,omitempty"`
+	Owner   string            `json:"owner,omitempty"`
+}
+
+// StatusHandler - состав кольца; ?key= или ?queue=&partition_key= - владелец ключа
+func (r *Router) StatusHandler(w http.ResponseWriter, req *http.Request) {
+	r.mu.RLock()
+	status := Status{Node: r.cfg.NodeID, Leaving: r.leaving.Load(), Members: r.addrs}
+	r.mu.RUnlock()
+
+	query := req.URL.Query()
+	status.Key = query.Get("key")
+	if status.Key == "" && (query.Has("queue") || query.Has("partition_key")) {
+		status.Key = Key(query.Get("queue"), query.Get("partition_key"))
+	}
+	if status.Key != "" {
+		status.Owner, _, _ = r.Owner(status.Key)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (r *Router) forwarded(req *http.Request) bool {
+	return req.Header.Get(ForwardedHeader) != "" && checkSecret(req, r.cfg.Secret)
+}
+
+// taskID - id задачи из /tasks/{id}[/...] и /status?id=
+func taskID(req *http.Request) string {
+	if req.URL.Path == "/status" {
+		return req.URL.Query().Get("id")
+	}
+	rest, found := strings.CutPrefix(req.URL.Path, "/tasks/")
+	if !found {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
+
+// Middleware направляет /enqueue владельцу ключа, а запросы к задаче,
+// которой нет на узле, - узлу, где она есть
+func (r *Router) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, "/shard/") || r.forwarded(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if req.Method == http.MethodPost && req.URL.Path == "/enqueue" {
+			r.routeEnqueue(w, req, next)
+			return
+		}
+		if id := taskID(req); id != "" {
+			r.routeTask(w, req, next, id)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (r *Router) routeEnqueue(w http.ResponseWriter, req *http.Request, next http.Handler) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, service.MaxImportLineBytes))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var target struct {
+		Queue        string `json:"queue"`
+		PartitionKey string `json:"partition_key"`
+	}
+	// Некорректное тело отклонит контроллер
+	if json.Unmarshal(body, &target) != nil {
+		next.ServeHTTP(w, req)
+		return
+	}
+	owner, addr, ok := r.Owner(Key(target.Queue, target.PartitionKey))
+	if !ok || owner == r.cfg.NodeID {
+		next.ServeHTTP(w, req)
+		return
+	}
+
+	resp, err := r.send(req, body, addr)
+	if err != nil {
+		slog.Warn("Failed to route task to shard owner", "owner", owner, "error", err)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Shard owner unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	shardForwarded.Inc("enqueue")
+	copyResponse(w, resp)
+}
+
+func (r *Router) routeTask(w http.ResponseWriter, req *http.Request, next http.Handler, id string) {
+	if _, exists := r.service.GetTask(id); exists {
+		next.ServeHTTP(w, req)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, service.MaxImportLineBytes))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Владелец по id неизвестен - спрашиваем остальные узлы по порядку
+	r.mu.RLock()
+	peers := make([]string, 0, len(r.addrs))
+	for peer, addr := range r.addrs {
+		if peer != r.cfg.NodeID {
+			peers = append(peers, addr)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Strings(peers)
+
+	for _, addr := range peers {
+		resp, err := r.send(req, body, addr)
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			continue
+		}
+		shardForwarded.Inc("task")
+		copyResponse(w, resp)
+		return
+	}
+	next.ServeHTTP(w, req)
+}
+
+func (r *Router) send(req *http.Request, body []byte, addr string) (*http.Response, error) {
+	out, err := http.NewRequestWithContext(req.Context(), req.Method, addr+req.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	out.Header = req.Header.Clone()
+	// Учетные данные клиента не уходят на другой узел: он доверяет
+	// секрету узла и принципалу, проверенному здесь
+	for _, name := range []string{"Authorization", "Proxy-Authorization", "Cookie"} {
+		out.Header.Del(name)
+	}
+	out.Header.Del(PrincipalHeader)
+	if principal, ok := auth.PrincipalFromContext(req.Context()); ok {
+		data, err := json.Marshal(forwardedPrincipal{
+			Name:   principal.Name,
+			Tenant: principal.Tenant,
+			Scopes: principal.Scopes,
+			Queues: principal.Queues,
+		})
+		if err != nil {
+			return nil, err
+		}
+		out.Header.Set(PrincipalHeader, string(data))
+	}
+	out.Header.Set(ForwardedHeader, r.cfg.NodeID)
+	out.Header.Set(SecretHeader, r.cfg.Secret)
+	return r.client.Do(out)
+}
+
+type forwardedPrincipal struct {
+	Name   string       `json:"name"`
+	Tenant string       `json:"tenant,omitempty"`
+	Scopes []auth.Scope `json:"scopes"`
+	Queues []string     `json:"queues,omitempty"`
+}
+
+// Authenticator принимает запросы, перенаправленные другими узлами: принципал
+// берется из PrincipalHeader, если запрос подписан секретом узлов. Ставится
+// первым в auth.Chain перед аутентификатором клиентов.
+func (r *Router) Authenticator() auth.Authenticator {
+	return peerAuthenticator{secret: r.cfg.Secret}
+}
+
+type peerAuthenticator struct {
+	secret string
+}
+
+func (a peerAuthenticator) Authenticate(req *http.Request) (*auth.Principal, error) {
+	if req.Header.Get(ForwardedHeader) == "" {
+		return nil, auth.ErrMissingCredentials
+	}
+	if !checkSecret(req, a.secret) {
+		return nil, errInvalidSecret
+	}
+	value := req.Header.Get(PrincipalHeader)
+	if value == "" {
+		return nil, auth.ErrMissingCredentials
+	}
+	var principal forwardedPrincipal
+	if err := json.Unmarshal([]byte(value), &principal); err != nil {
+		return nil, errInvalidSecret
+	}
+	return &auth.Principal{
+		Name:   principal.Name,
+		Tenant: principal.Tenant,
+		Scopes: principal.Scopes,
+		Queues: principal.Queues,
+	}, nil
+}
+
+func copyResponse(w http.ResponseWriter, resp *http.Response) {
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
@@ -0,0 +1,84 @@
+package tasklog
+
+import (
+	"sync"
+	"time"
+)
+
+type Line struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"msg"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// Buffer хранит последние строки одной попытки. Смещения сквозные,
+// поэтому читатель в режиме follow не теряет позицию при вытеснении.
+type Buffer struct {
+	mu      sync.Mutex
+	lines   []Line
+	max     int
+	dropped int
+	closed  bool
+	notify  chan struct{}
+}
+
+func newBuffer(max int) *Buffer {
+	return &Buffer{max: max, notify: make(chan struct{})}
+}
+
+func (b *Buffer) Append(line Line) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	if len(b.lines) >= b.max {
+		copy(b.lines, b.lines[1:])
+		b.lines = b.lines[:len(b.lines)-1]
+		b.dropped++
+	}
+	b.lines = append(b.lines, line)
+	b.wake()
+}
+
+// Close помечает попытку завершенной, новые строки не принимаются
+func (b *Buffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		b.wake()
+	}
+}
+
+func (b *Buffer) wake() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// Read возвращает строки начиная со смещения from, смещение для следующего
+// чтения, признак завершения попытки и канал, закрываемый при новых строках
+func (b *Buffer) Read(from int) ([]Line, int, bool, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if from < b.dropped {
+		from = b.dropped
+	}
+	end := b.dropped + len(b.lines)
+	var lines []Line
+	if from < end {
+		lines = append(lines, b.lines[from-b.dropped:]...)
+	}
+	return lines, end, b.closed, b.notify
+}
+
+// Dropped - число строк, вытесненных из буфера
+func (b *Buffer) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
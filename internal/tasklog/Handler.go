@@ -0,0 +1,92 @@
+package tasklog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler пишет записи в буфер попытки и передает их дальше в общий лог
+type Handler struct {
+	next   slog.Handler
+	buffer *Buffer
+	attrs  []slog.Attr
+	group  string
+}
+
+func NewHandler(next slog.Handler, buffer *Buffer) *Handler {
+	return &Handler{next: next, buffer: buffer}
+}
+
+// Буфер попытки принимает все уровни, общий лог - по своей настройке
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	line := Line{
+		Time:    record.Time,
+		Level:   record.Level.String(),
+		Message: record.Message,
+	}
+	if len(h.attrs) > 0 || record.NumAttrs() > 0 {
+		line.Attrs = make(map[string]any, len(h.attrs)+record.NumAttrs())
+		for _, attr := range h.attrs {
+			addAttr(line.Attrs, "", attr)
+		}
+		record.Attrs(func(attr slog.Attr) bool {
+			addAttr(line.Attrs, h.group, attr)
+			return true
+		})
+	}
+	h.buffer.Append(line)
+
+	if h.next.Enabled(ctx, record.Level) {
+		return h.next.Handle(ctx, record)
+	}
+	return nil
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		clone.attrs = append(clone.attrs, attr)
+	}
+	return &clone
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	if h.group != "" {
+		name = h.group + "." + name
+	}
+	clone.group = name
+	return &clone
+}
+
+func addAttr(attrs map[string]any, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	key := attr.Key
+	if group != "" {
+		key = group + "." + key
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, nested := range attr.Value.Group() {
+			addAttr(attrs, key, nested)
+		}
+		return
+	}
+	if err, ok := attr.Value.Any().(error); ok {
+		attrs[key] = err.Error()
+		return
+	}
+	attrs[key] = attr.Value.Any()
+}
@@ -0,0 +1,86 @@
+package tasklog
+
+import "sync"
+
+const (
+	DefaultMaxLines = 1000
+	DefaultMaxTasks = 10000
+)
+
+// Store держит буферы логов по задачам и попыткам. При превышении
+// maxTasks вытесняются логи самых старых задач.
+type Store struct {
+	mu       sync.Mutex
+	maxLines int
+	maxTasks int
+	tasks    map[string][]*Buffer
+	order    []string
+}
+
+func NewStore(maxLines, maxTasks int) *Store {
+	if maxLines <= 0 {
+		maxLines = DefaultMaxLines
+	}
+	if maxTasks <= 0 {
+		maxTasks = DefaultMaxTasks
+	}
+	return &Store{
+		maxLines: maxLines,
+		maxTasks: maxTasks,
+		tasks:    make(map[string][]*Buffer),
+	}
+}
+
+// Attempt возвращает буфер попытки (нумерация с 1), создавая его при необходимости
+func (s *Store) Attempt(taskID string, attempt int) *Buffer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, exists := s.tasks[taskID]
+	if !exists {
+		s.order = append(s.order, taskID)
+		s.evict()
+	}
+	for len(attempts) < attempt {
+		attempts = append(attempts, nil)
+	}
+	if attempts[attempt-1] == nil {
+		attempts[attempt-1] = newBuffer(s.maxLines)
+	}
+	s.tasks[taskID] = attempts
+	return attempts[attempt-1]
+}
+
+// Get возвращает буфер попытки; attempt <= 0 - последняя попытка
+func (s *Store) Get(taskID string, attempt int) (*Buffer, int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.tasks[taskID]
+	if attempt <= 0 {
+		attempt = len(attempts)
+	}
+	if attempt == 0 || attempt > len(attempts) || attempts[attempt-1] == nil {
+		return nil, 0, false
+	}
+	return attempts[attempt-1], attempt, true
+}
+
+// Reset удаляет буферы задачи, нумерация попыток начинается заново
+func (s *Store) Reset(taskID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.tasks[taskID]; exists {
+		s.tasks[taskID] = nil
+	}
+}
+
+// evict вызывается под mu
+func (s *Store) evict() {
+	for len(s.order) > s.maxTasks {
+		id := s.order[0]
+		s.order[0] = ""
+		s.order = s.order[1:]
+		delete(s.tasks, id)
+	}
+}
@@ -0,0 +1,401 @@
+"use strict";
+
+// Дашборд работает только через JSON API и /events.
+// Ключ хранится в localStorage и передается как Bearer токен.
+
+const state = {
+  apiKey: localStorage.getItem("taskqueue.apiKey") || "",
+  view: "overview",
+  task: null,
+  utilisation: [],
+  throughput: [],
+  lastTotals: null,
+  logsAbort: null,
+};
+
+const HISTORY = 60;
+const POLL_INTERVAL = 2000;
+
+function $(selector) {
+  return document.querySelector(selector);
+}
+
+function el(tag, text, className) {
+  const node = document.createElement(tag);
+  if (text !== undefined) node.textContent = text;
+  if (className) node.className = className;
+  return node;
+}
+
+function headers() {
+  return state.apiKey ? { Authorization: "Bearer " + state.apiKey } : {};
+}
+
+async function api(path, options = {}) {
+  const resp = await fetch(path, { ...options, headers: { ...headers(), ...(options.headers || {}) } });
+  if (!resp.ok) {
+    throw new Error(path + ": " + resp.status + " " + (await resp.text()).trim());
+  }
+  return resp;
+}
+
+function showError(err) {
+  const box = $("#error");
+  if (!err) {
+    box.hidden = true;
+    return;
+  }
+  box.textContent = err.message || String(err);
+  box.hidden = false;
+}
+
+function statusBadge(status) {
+  return el("span", status, "status " + status);
+}
+
+function formatTime(value) {
+  return value ? new Date(value).toLocaleString() : "";
+}
+
+// Навигация
+
+function show(view) {
+  state.view = view;
+  document.querySelectorAll(".view").forEach((section) => {
+    section.hidden = section.id !== view;
+  });
+  document.querySelectorAll("nav button").forEach((button) => {
+    button.classList.toggle("active", button.dataset.view === view);
+  });
+  if (view === "tasks") searchTasks();
+  if (view === "dlq") loadDLQ();
+}
+
+// Обзор
+
+async function refreshStats() {
+  try {
+    const stats = await (await api("/stats")).json();
+    showError(null);
+
+    $("#workers").textContent = stats.busy_workers + " / " + stats.workers;
+    $("#queued").textContent = stats.queued;
+    $("#leased").textContent = stats.leased;
+    $("#completed").textContent = stats.completed_total;
+    $("#failed").textContent = stats.failed_total;
+
+    const now = Date.now();
+    push(state.utilisation, stats.workers ? (100 * stats.busy_workers) / stats.workers : 0);
+    if (state.lastTotals) {
+      const seconds = (now - state.lastTotals.time) / 1000 || 1;
+      push(state.throughput, {
+        done: (stats.completed_total - state.lastTotals.completed) / seconds,
+        failed: (stats.failed_total - state.lastTotals.failed) / seconds,
+      });
+    }
+    state.lastTotals = { time: now, completed: stats.completed_total, failed: stats.failed_total };
+
+    drawChart($("#utilisation-chart"), [{ color: "#4f8cff", values: state.utilisation }], 100);
+    drawChart($("#throughput-chart"), [
+      { color: "#2e9d5b", values: state.throughput.map((p) => p.done) },
+      { color: "#d64545", values: state.throughput.map((p) => p.failed) },
+    ]);
+    renderQueues(stats.queues || {});
+  } catch (err) {
+    showError(err);
+  }
+}
+
+function push(series, value) {
+  series.push(value);
+  if (series.length > HISTORY) series.shift();
+}
+
+function drawChart(canvas, lines, fixedMax) {
+  const ctx = canvas.getContext("2d");
+  const { width, height } = canvas;
+  ctx.clearRect(0, 0, width, height);
+
+  let max = fixedMax || 0;
+  if (!fixedMax) {
+    lines.forEach((line) => line.values.forEach((v) => { max = Math.max(max, v); }));
+    max = max || 1;
+  }
+
+  ctx.strokeStyle = "#e3e7ee";
+  ctx.fillStyle = "#6b7385";
+  ctx.font = "10px sans-serif";
+  for (let i = 0; i <= 4; i++) {
+    const y = height - (i / 4) * (height - 12) - 1;
+    ctx.beginPath();
+    ctx.moveTo(0, y);
+    ctx.lineTo(width, y);
+    ctx.stroke();
+    ctx.fillText(((max * i) / 4).toFixed(fixedMax ? 0 : 1), 2, y - 2);
+  }
+
+  const step = width / (HISTORY - 1);
+  lines.forEach((line) => {
+    ctx.strokeStyle = line.color;
+    ctx.lineWidth = 2;
+    ctx.beginPath();
+    line.values.forEach((v, i) => {
+      const x = (HISTORY - line.values.length + i) * step;
+      const y = height - (v / max) * (height - 12) - 1;
+      if (i === 0) ctx.moveTo(x, y);
+      else ctx.lineTo(x, y);
+    });
+    ctx.stroke();
+  });
+  ctx.lineWidth = 1;
+}
+
+function renderQueues(queues) {
+  const body = $("#queues tbody");
+  body.replaceChildren();
+  Object.keys(queues).sort().forEach((name) => {
+    const row = el("tr", undefined, "link");
+    row.append(el("td", name));
+    ["queued", "running", "done", "failed", "cancelled", "quarantined"].forEach((status) => {
+      row.append(el("td", String(queues[name][status] || 0)));
+    });
+    row.addEventListener("click", () => {
+      $("#search").elements.queue.value = name;
+      show("tasks");
+    });
+    body.append(row);
+  });
+}
+
+// Поток событий. EventSource не умеет заголовки, поэтому читаем SSE через fetch.
+
+async function streamEvents() {
+  for (;;) {
+    try {
+      const resp = await api("/events");
+      const reader = resp.body.getReader();
+      const decoder = new TextDecoder();
+      let buffer = "";
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buffer += decoder.decode(value, { stream: true });
+        let index;
+        while ((index = buffer.indexOf("\n\n")) >= 0) {
+          const chunk = buffer.slice(0, index);
+          buffer = buffer.slice(index + 2);
+          const data = chunk.split("\n").filter((l) => l.startsWith("data: ")).map((l) => l.slice(6)).join("\n");
+          if (data) onEvent(JSON.parse(data));
+        }
+      }
+    } catch (err) {
+      // Переподключение ниже
+    }
+    await new Promise((resolve) => setTimeout(resolve, 3000));
+  }
+}
+
+function onEvent(event) {
+  const list = $("#events");
+  let text = formatTime(event.time) + "  " + event.task_id + "  " + event.type + "  " + (event.status || "");
+  if (event.progress) {
+    text += "  " + event.progress.percent.toFixed(0) + "% " + (event.progress.message || "");
+  }
+  list.prepend(el("li", text));
+  while (list.children.length > 200) list.lastChild.remove();
+
+  if (state.view === "detail" && state.task && state.task.id === event.task_id) {
+    openTask(event.task_id, false);
+  }
+}
+
+// Поиск задач
+
+async function searchTasks(event) {
+  if (event) event.preventDefault();
+  const params = new URLSearchParams();
+  for (const field of $("#search").elements) {
+    if (field.name && field.value) params.set(field.name, field.value);
+  }
+  try {
+    const { tasks } = await (await api("/tasks?" + params)).json();
+    const body = $("#task-list tbody");
+    body.replaceChildren();
+    (tasks || []).forEach((task) => {
+      const row = el("tr", undefined, "link");
+      const status = el("td");
+      status.append(statusBadge(task.status));
+      row.append(el("td", task.id), el("td", task.type), el("td", task.queue), status,
+        el("td", String(task.retries + 1)),
+        el("td", task.progress ? task.progress.percent.toFixed(0) + "%" : ""),
+        el("td", formatTime(task.created_at)));
+      row.addEventListener("click", () => openTask(task.id, true));
+      body.append(row);
+    });
+  } catch (err) {
+    showError(err);
+  }
+}
+
+async function loadDLQ() {
+  try {
+    const { tasks } = await (await api("/tasks?status=failed")).json();
+    const body = $("#dlq-list tbody");
+    body.replaceChildren();
+    (tasks || []).forEach((task) => {
+      const row = el("tr", undefined, "link");
+      const actions = el("td");
+      const replay = el("button", "Replay");
+      replay.addEventListener("click", async (e) => {
+        e.stopPropagation();
+        await operate(task.id, "replay");
+        loadDLQ();
+      });
+      actions.append(replay);
+      row.append(el("td", task.id), el("td", task.type), el("td", task.queue),
+        el("td", String(task.retries)), el("td", formatTime(task.finished_at)), actions);
+      row.addEventListener("click", () => openTask(task.id, true));
+      body.append(row);
+    });
+  } catch (err) {
+    showError(err);
+  }
+}
+
+// Карточка задачи
+
+async function openTask(id, reloadLogs) {
+  try {
+    const task = await (await api("/tasks/" + encodeURIComponent(id))).json();
+    const previous = state.task;
+    state.task = task;
+    show("detail");
+
+    $("#detail-title").replaceChildren(el("span", task.id + " "), statusBadge(task.status));
+    const terminal = ["done", "failed", "cancelled"].includes(task.status);
+    $("#cancel").hidden = terminal;
+    $("#replay").hidden = task.status !== "failed" && task.status !== "cancelled";
+    $("#release").hidden = task.status !== "quarantined";
+
+    const progress = $("#progress");
+    progress.hidden = !task.progress;
+    if (task.progress) {
+      progress.querySelector(".bar div").style.width = task.progress.percent + "%";
+      progress.querySelector("span").textContent = task.progress.percent.toFixed(0) + "% " + (task.progress.message || "");
+    }
+
+    const fields = $("#detail-fields");
+    fields.replaceChildren();
+    const rows = {
+      "Тип": task.type,
+      "Очередь": task.queue,
+      "Попытки": task.retries + " / " + task.max_retries,
+      "Создана": formatTime(task.created_at),
+      "В очереди с": formatTime(task.enqueued_at),
+      "Завершена": formatTime(task.finished_at),
+      "Request ID": task.request_id || "",
+      "Payload": typeof task.payload === "string" ? task.payload : JSON.stringify(task.payload),
+      "Content-Type": task.content_type || "",
+      "Чекпоинт": task.checkpoint ? JSON.stringify(task.checkpoint) : "",
+      "Паники / аварии": task.panics || task.crashes ? (task.panics || 0) + " / " + (task.crashes || 0) : "",
+    };
+    Object.entries(rows).forEach(([name, value]) => fields.append(el("dt", name), el("dd", value)));
+
+    const errors = task.attempt_errors || [];
+    $("#attempt-errors").hidden = errors.length === 0;
+    $("#attempt-errors ul").replaceChildren(...errors.map((e) => {
+      const item = el("li", "#" + e.attempt + " " + formatTime(e.time) + " " + e.error);
+      if (e.stack) item.append(el("pre", e.stack));
+      return item;
+    }));
+
+    const stopped = terminal || task.status === "quarantined";
+    const attempts = task.retries + (stopped ? 0 : 1) || 1;
+    const select = $("#attempt");
+    const attemptsChanged = select.options.length !== attempts;
+    if (attemptsChanged) {
+      select.replaceChildren();
+      for (let i = 1; i <= attempts; i++) select.append(el("option", String(i)));
+      select.value = String(attempts);
+    }
+    if (reloadLogs || attemptsChanged || !previous || previous.id !== task.id) {
+      loadLogs();
+    }
+  } catch (err) {
+    showError(err);
+  }
+}
+
+async function loadLogs() {
+  if (state.logsAbort) state.logsAbort.abort();
+  const controller = new AbortController();
+  state.logsAbort = controller;
+
+  const pre = $("#logs");
+  pre.textContent = "";
+  const path = "/tasks/" + encodeURIComponent(state.task.id) + "/logs?follow=true&attempt=" + $("#attempt").value;
+  try {
+    const resp = await api(path, { signal: controller.signal });
+    const reader = resp.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = "";
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) break;
+      buffer += decoder.decode(value, { stream: true });
+      let index;
+      while ((index = buffer.indexOf("\n")) >= 0) {
+        const line = JSON.parse(buffer.slice(0, index));
+        buffer = buffer.slice(index + 1);
+        const attrs = Object.entries(line.attrs || {})
+          .filter(([key]) => !["task_id", "type", "queue", "request_id"].includes(key))
+          .map(([key, value]) => key + "=" + JSON.stringify(value)).join(" ");
+        pre.textContent += new Date(line.time).toLocaleTimeString() + " " + line.level + " " + line.msg + " " + attrs + "\n";
+      }
+    }
+  } catch (err) {
+    if (err.name === "AbortError") return;
+    pre.textContent = err.message.includes(": 404") ? "Логов нет" : err.message;
+  }
+}
+
+async function operate(id, action) {
+  try {
+    await api("/tasks/" + encodeURIComponent(id) + "/" + action, { method: "POST" });
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+// Инициализация
+
+document.querySelectorAll("nav button").forEach((button) => {
+  button.addEventListener("click", () => show(button.dataset.view));
+});
+$("#auth").addEventListener("submit", (event) => {
+  event.preventDefault();
+  state.apiKey = $("#api-key").value;
+  localStorage.setItem("taskqueue.apiKey", state.apiKey);
+  refreshStats();
+});
+$("#api-key").value = state.apiKey;
+$("#search").addEventListener("submit", searchTasks);
+$("#back").addEventListener("click", () => show("tasks"));
+$("#attempt").addEventListener("change", loadLogs);
+$("#cancel").addEventListener("click", async () => {
+  await operate(state.task.id, "cancel");
+  openTask(state.task.id, true);
+});
+$("#replay").addEventListener("click", async () => {
+  await operate(state.task.id, "replay");
+  openTask(state.task.id, true);
+});
+$("#release").addEventListener("click", async () => {
+  await operate(state.task.id, "release");
+  openTask(state.task.id, true);
+});
+
+refreshStats();
+setInterval(refreshStats, POLL_INTERVAL);
+streamEvents();
@@ -2,63 +2,568 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"TaskQueue/config"
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/cluster"
 	"TaskQueue/internal/controller"
+	"TaskQueue/internal/handlers"
+	"TaskQueue/internal/logging"
+	"TaskQueue/internal/model"
 	"TaskQueue/internal/repository"
+	"TaskQueue/internal/resp"
+	"TaskQueue/internal/schema"
 	"TaskQueue/internal/service"
+	"TaskQueue/internal/shard"
+	"TaskQueue/internal/tasklog"
+	"TaskQueue/internal/tlsutil"
+	"TaskQueue/internal/tracing"
+	"TaskQueue/internal/ui"
+	"TaskQueue/queue"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	cfg := config.LoadConfig()
-	log.Printf("Starting with %d workers, queue size %d, port %s",
-		cfg.Workers, cfg.QueueSize, cfg.Port)
+	if _, err := logging.Setup(os.Stdout, cfg.LogFormat, cfg.LogLevel); err != nil {
+		fatal("Failed to configure logging", err)
+	}
+	slog.Info("Starting", "workers", cfg.Workers, "queue_size", cfg.QueueSize, "port", cfg.Port)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter: cfg.TracingExporter,
+		File:     cfg.TracingFile,
+	})
+	if err != nil {
+		fatal("Failed to configure tracing", err)
+	}
+
+	retryOption, err := newRetryOption(cfg)
+	if err != nil {
+		fatal("Failed to configure retry policies", err)
+	}
+
+	tenantOption, err := newTenantOption(cfg)
+	if err != nil {
+		fatal("Failed to configure tenant limits", err)
+	}
+
+	breakerOption, err := newBreakerOption(cfg)
+	if err != nil {
+		fatal("Failed to configure circuit breakers", err)
+	}
+
+	overflowMode, err := queue.ParseOverflowMode(cfg.OverflowMode)
+	if err != nil {
+		fatal("Failed to configure overflow mode", err)
+	}
+
+	taskCluster, err := newCluster(cfg)
+	if err != nil {
+		fatal("Failed to configure cluster", err)
+	}
+	if taskCluster != nil && overflowMode == queue.OverflowSpill {
+		fatal("Failed to configure cluster", fmt.Errorf("overflow mode spill is not supported in cluster mode"))
+	}
 
-	taskRepo := repository.NewInMemoryTaskRepository()
-	queueService := service.NewQueueService(taskRepo, cfg.Workers, cfg.QueueSize)
+	remoteTypes := strings.FieldsFunc(cfg.RemoteTaskTypes, func(r rune) bool { return r == ',' })
+	// Задачи списков Redis ждут BRPOP, а не локальный обработчик
+	if cfg.RespAddr != "" {
+		remoteTypes = append(remoteTypes, cfg.RespTaskType)
+	}
+	poolOptions := []queue.Option{
+		retryOption,
+		tenantOption,
+		breakerOption,
+		queue.WithRemoteTypes(remoteTypes...),
+		queue.WithVisibilityTimeout(cfg.VisibilityTimeout),
+		queue.WithReapInterval(cfg.ReaperInterval),
+		queue.WithPoisonThreshold(cfg.PoisonThreshold),
+		queue.WithTaskLogs(tasklog.NewStore(cfg.TaskLogLines, cfg.TaskLogTasks)),
+		queue.WithOverflow(overflowMode, cfg.EnqueueMaxWait),
+		queue.WithSpill(cfg.SpillDir, int64(cfg.SpillMaxBytes)),
+	}
+
+	var taskRepo repository.TaskRepository = repository.NewInMemoryTaskRepository()
+	if taskCluster != nil {
+		taskRepo = taskCluster.Repository()
+		// Пул выполняет задачи, только пока узел лидер
+		poolOptions = append(poolOptions, queue.WithStandby())
+	}
+	queueService := service.NewQueueService(taskRepo, cfg.Workers, cfg.QueueSize, poolOptions...)
 	httpController := controller.NewHTTPController(queueService)
 
+	if cfg.SchemasDir != "" {
+		schemas, err := schema.ReadDir(cfg.SchemasDir)
+		if err != nil {
+			fatal("Failed to read payload schemas", err)
+		}
+		for taskType, raw := range schemas {
+			if err := queueService.RegisterSchema(taskType, raw); err != nil {
+				fatal("Failed to load payload schema for "+taskType, err)
+			}
+		}
+		slog.Info("Payload schemas loaded", "dir", cfg.SchemasDir, "task_types", queueService.SchemaTypes())
+	}
+
+	if cfg.ExecCommandsFile != "" {
+		execHandler, err := handlers.LoadExecCommands(cfg.ExecCommandsFile)
+		if err != nil {
+			fatal("Failed to configure exec commands", err)
+		}
+		queueService.RegisterHandler(handlers.ExecTaskType, execHandler.Handle)
+	}
+	if cfg.HTTPTaskAllowedHosts != "" {
+		httpHandler, err := handlers.NewHTTPHandler(handlers.HTTPConfig{
+			AllowedHosts:     strings.FieldsFunc(cfg.HTTPTaskAllowedHosts, func(r rune) bool { return r == ',' }),
+			Timeout:          cfg.HTTPTaskTimeout,
+			MaxResponseBytes: cfg.HTTPTaskMaxResponseSize,
+			MaxRetryAfter:    cfg.HTTPTaskMaxRetryAfter,
+		})
+		if err != nil {
+			fatal("Failed to configure http tasks", err)
+		}
+		queueService.RegisterHandler(handlers.HTTPTaskType, httpHandler.Handle)
+	}
+	var plugins *handlers.Plugins
+	stopPlugins := make(chan struct{})
+	if cfg.PluginsDir != "" {
+		loaded, err := handlers.NewPlugins(handlers.PluginConfig{
+			Dir:              cfg.PluginsDir,
+			MemoryLimitBytes: cfg.PluginMemoryLimit,
+			Timeout:          cfg.PluginTimeout,
+		}, queueService)
+		if err != nil {
+			fatal("Failed to load plugins", err)
+		}
+		plugins = loaded
+		slog.Info("Plugins loaded", "dir", cfg.PluginsDir, "task_types", plugins.Types())
+		go plugins.Watch(cfg.PluginReloadInterval, stopPlugins)
+	}
+
 	queueService.StartWorkers()
+	if taskCluster != nil {
+		taskCluster.Start(queueService)
+	}
+
+	shardRouter, err := newShardRouter(cfg, queueService)
+	if err != nil {
+		fatal("Failed to configure sharding", err)
+	}
+	if shardRouter != nil {
+		if taskCluster != nil {
+			fatal("Failed to configure sharding", fmt.Errorf("sharding and cluster mode are mutually exclusive"))
+		}
+		shardRouter.Start()
+	}
+
+	collector := repository.NewCollector(taskRepo, repository.RetentionPolicy{
+		TTL: map[string]time.Duration{
+			"done":      cfg.RetentionDoneTTL,
+			"failed":    cfg.RetentionFailedTTL,
+			"cancelled": cfg.RetentionCancelledTTL,
+		},
+		MaxTasks:   cfg.RetentionMaxTasks,
+		ArchiveDir: cfg.RetentionArchiveDir,
+		Interval:   cfg.RetentionInterval,
+	})
+	collector.Start()
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /enqueue", httpController.EnqueueHandler)
 	mux.HandleFunc("GET /healthz", httpController.HealthHandler)
 	mux.HandleFunc("GET /status", httpController.StatusHandler)
+	mux.HandleFunc("GET /metrics", httpController.MetricsHandler)
+	mux.HandleFunc("GET /tasks/{id}", httpController.TaskHandler)
+	mux.HandleFunc("GET /tasks/{id}/logs", httpController.LogsHandler)
+	mux.HandleFunc("GET /events", httpController.EventsHandler)
+	mux.HandleFunc("GET /tasks", httpController.ListTasksHandler)
+	mux.HandleFunc("GET /stats", httpController.StatsHandler)
+	mux.HandleFunc("POST /tasks/{id}/cancel", httpController.CancelHandler)
+	mux.HandleFunc("POST /tasks/{id}/replay", httpController.ReplayHandler)
+	mux.HandleFunc("POST /tasks/{id}/release", httpController.ReleaseHandler)
+	mux.HandleFunc("GET /admin/breakers", httpController.BreakersHandler)
+	mux.HandleFunc("POST /admin/breakers/{key}/reset", httpController.ResetBreakerHandler)
+	mux.HandleFunc("GET /admin/schemas", httpController.SchemasHandler)
+	mux.HandleFunc("GET /admin/schemas/{type}", httpController.GetSchemaHandler)
+	mux.HandleFunc("GET /admin/export", httpController.ExportHandler)
+	mux.HandleFunc("POST /admin/import", httpController.ImportHandler)
+	mux.Handle("GET /ui/", ui.Handler())
+	mux.Handle("GET /ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+	mux.HandleFunc("POST /lease", httpController.LeaseHandler)
+	mux.HandleFunc("POST /tasks/{id}/heartbeat", httpController.HeartbeatHandler)
+	mux.HandleFunc("POST /tasks/{id}/progress", httpController.ProgressHandler)
+	mux.HandleFunc("POST /tasks/{id}/complete", httpController.CompleteHandler)
+	mux.HandleFunc("POST /tasks/{id}/fail", httpController.FailHandler)
+	if taskCluster != nil {
+		taskCluster.Register(mux)
+	}
+	if shardRouter != nil {
+		shardRouter.Register(mux)
+	}
+
+	var handler http.Handler = mux
+	if shardRouter != nil {
+		handler = shardRouter.Middleware(handler)
+	}
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		fatal("Failed to configure authentication", err)
+	}
+	if authenticator != nil {
+		if shardRouter != nil {
+			authenticator = auth.Chain(shardRouter.Authenticator(), authenticator)
+		}
+		handler = auth.Middleware(authenticator, handler)
+	} else {
+		slog.Warn("Authentication disabled")
+	}
+	if taskCluster != nil {
+		handler = taskCluster.Middleware(handler)
+	}
+	handler = logging.RequestIDMiddleware(tracing.Middleware(handler))
 
 	server := &http.Server{
-		Addr:    "127.0.0.1:9000",
-		Handler: mux,
+		Addr:     cfg.ListenAddr,
+		Handler:  handler,
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	var reloader *tlsutil.Reloader
+	stopReload := make(chan struct{})
+	if cfg.TLSCertFile != "" {
+		reloader, err = tlsutil.NewReloader(tlsutil.Config{
+			CertFile:     cfg.TLSCertFile,
+			KeyFile:      cfg.TLSKeyFile,
+			ClientCAFile: cfg.TLSClientCAFile,
+			ClientAuth:   tlsutil.ClientAuthMode(cfg.TLSClientAuth),
+		})
+		if err != nil {
+			fatal("Failed to configure TLS", err)
+		}
+		server.TLSConfig = reloader.TLSConfig()
+		go reloader.Watch(cfg.TLSReloadInterval, stopReload)
 	}
 
 	go func() {
-		log.Printf("Server starting on port %s", cfg.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed: %v", err)
+		slog.Info("Server starting", "port", cfg.Port, "tls", reloader != nil)
+		var err error
+		if reloader != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("Server failed", err)
 		}
 	}()
 
+	var respServer *resp.Server
+	if cfg.RespAddr != "" {
+		respConfig := resp.Config{
+			Addr:          cfg.RespAddr,
+			TaskType:      cfg.RespTaskType,
+			MaxRetries:    cfg.RespMaxRetries,
+			Authenticator: authenticator,
+		}
+		if reloader != nil {
+			respConfig.TLSConfig = reloader.TLSConfig()
+		}
+		respServer = resp.NewServer(respConfig, queueService)
+		go func() {
+			slog.Info("RESP server starting", "addr", cfg.RespAddr, "task_type", cfg.RespTaskType)
+			if err := respServer.ListenAndServe(); err != nil && err != resp.ErrServerClosed {
+				fatal("RESP server failed", err)
+			}
+		}()
+	}
+
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	sig := <-sigChan
-	log.Printf("Received signal: %v", sig)
+	for sig == syscall.SIGHUP {
+		if reloader != nil {
+			if err := reloader.Reload(); err != nil {
+				slog.Error("TLS reload failed", "error", err)
+			} else {
+				slog.Info("TLS certificates reloaded")
+			}
+		}
+		if plugins != nil {
+			if err := plugins.Reload(); err != nil {
+				slog.Error("Plugin reload failed", "error", err)
+			}
+		}
+		sig = <-sigChan
+	}
+	slog.Info("Received signal", "signal", sig.String())
+	close(stopReload)
+	close(stopPlugins)
 
-	log.Println("Initiating graceful shutdown...")
+	slog.Info("Initiating graceful shutdown")
+
+	// Ожидающие задачи передаются остальным узлам до остановки
+	if shardRouter != nil {
+		slog.Info("Handing off waiting tasks", "count", shardRouter.Leave())
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+		slog.Error("Server shutdown error", "error", err)
+	}
+	if respServer != nil {
+		respServer.Close()
 	}
 
+	if taskCluster != nil {
+		taskCluster.Stop()
+	}
+	if shardRouter != nil {
+		shardRouter.Stop()
+	}
 	queueService.Shutdown()
+	if plugins != nil {
+		plugins.Close()
+	}
+	collector.Stop()
+
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Tracing shutdown error", "error", err)
+	}
 
-	log.Println("Shutdown completed")
+	slog.Info("Shutdown completed")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// newRetryOption разбирает RETRY_POLICY и QUEUE_RETRY_POLICIES
+// ("queue=strategy:base[:max_delay[:max_elapsed]];...")
+func newRetryOption(cfg config.Config) (queue.Option, error) {
+	defaultPolicy, err := model.ParseRetryPolicy(cfg.RetryPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	queues := make(map[string]model.RetryPolicy)
+	for _, entry := range strings.Split(cfg.QueueRetryPolicies, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, spec, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid queue retry policy %q", entry)
+		}
+		policy, err := model.ParseRetryPolicy(spec)
+		if err != nil {
+			return nil, fmt.Errorf("queue %s: %v", name, err)
+		}
+		queues[name] = policy
+	}
+
+	return queue.WithRetryPolicies(defaultPolicy, queues), nil
+}
+
+// newTenantOption разбирает TENANT_DEFAULT_LIMITS и TENANT_LIMITS
+// ("tenant:weight=3,concurrency=2;...")
+func newTenantOption(cfg config.Config) (queue.Option, error) {
+	defaultLimits, err := queue.ParseTenantLimits(cfg.TenantDefaultLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make(map[string]queue.TenantLimits)
+	for _, entry := range strings.Split(cfg.TenantLimits, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, spec, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid tenant limits %q", entry)
+		}
+		limits, err := queue.ParseTenantLimits(spec)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", name, err)
+		}
+		tenants[name] = limits
+	}
+
+	return queue.WithTenantLimits(defaultLimits, tenants), nil
+}
+
+// newBreakerOption разбирает CIRCUIT_BREAKER и CIRCUIT_BREAKERS
+// ("key:rate=0.5,cooldown=1m;..."); key - resource задачи или ее тип
+func newBreakerOption(cfg config.Config) (queue.Option, error) {
+	var defaultConfig queue.BreakerConfig
+	if cfg.CircuitBreaker != "" {
+		var err error
+		if defaultConfig, err = queue.ParseBreakerConfig(cfg.CircuitBreaker); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make(map[string]queue.BreakerConfig)
+	for _, entry := range strings.Split(cfg.CircuitBreakers, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		key, spec, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid circuit breaker %q", entry)
+		}
+		breakerConfig, err := queue.ParseBreakerConfig(spec)
+		if err != nil {
+			return nil, fmt.Errorf("circuit breaker %s: %v", key, err)
+		}
+		keys[key] = breakerConfig
+	}
+
+	return queue.WithBreakers(defaultConfig, keys), nil
+}
+
+// newCluster включает кластерный режим, если задан CLUSTER_NODE_ID
+func newCluster(cfg config.Config) (*cluster.Cluster, error) {
+	if cfg.ClusterNodeID == "" {
+		return nil, nil
+	}
+	peers, err := cluster.ParsePeers(cfg.ClusterPeers)
+	if err != nil {
+		return nil, err
+	}
+	forward, err := cluster.ParseForwardMode(cfg.ClusterForward)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ClusterSecret == "" {
+		return nil, fmt.Errorf("CLUSTER_SECRET is required in cluster mode")
+	}
+	slog.Info("Cluster mode enabled", "node", cfg.ClusterNodeID, "peers", len(peers), "forward", forward)
+	return cluster.New(cluster.Config{
+		NodeID:          cfg.ClusterNodeID,
+		Peers:           peers,
+		DataDir:         cfg.ClusterDataDir,
+		Secret:          cfg.ClusterSecret,
+		Forward:         forward,
+		ElectionTimeout: cfg.ClusterElectionTimeout,
+		SnapshotEntries: cfg.ClusterSnapshotEntries,
+	})
+}
+
+// newShardRouter включает шардирование, если задан SHARD_NODE_ID
+func newShardRouter(cfg config.Config, queueService service.QueueService) (*shard.Router, error) {
+	if cfg.ShardNodeID == "" {
+		return nil, nil
+	}
+	// Handoff импортирует задачи в любую очередь: узлы без секрета не принимаются
+	if cfg.ShardSecret == "" {
+		return nil, fmt.Errorf("SHARD_SECRET is required with sharding")
+	}
+
+	var membership shard.Membership
+	if cfg.ShardSeeds != "" {
+		if cfg.ShardAdvertiseURL == "" {
+			return nil, fmt.Errorf("SHARD_ADVERTISE_URL is required with SHARD_SEEDS")
+		}
+		membership = shard.NewGossip(shard.GossipConfig{
+			ID:       cfg.ShardNodeID,
+			Addr:     cfg.ShardAdvertiseURL,
+			Seeds:    strings.FieldsFunc(cfg.ShardSeeds, func(r rune) bool { return r == ',' }),
+			Interval: cfg.ShardGossipInterval,
+			Secret:   cfg.ShardSecret,
+		})
+	} else {
+		peers, err := cluster.ParsePeers(cfg.ShardPeers)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := peers[cfg.ShardNodeID]; !exists {
+			return nil, fmt.Errorf("node %s is not listed in SHARD_PEERS", cfg.ShardNodeID)
+		}
+		membership = shard.NewStatic(peers)
+	}
+	slog.Info("Sharding enabled", "node", cfg.ShardNodeID, "gossip", cfg.ShardSeeds != "")
+
+	return shard.NewRouter(shard.Config{
+		NodeID:            cfg.ShardNodeID,
+		Secret:            cfg.ShardSecret,
+		VirtualNodes:      cfg.ShardVirtualNodes,
+		RebalanceInterval: cfg.ShardRebalanceInterval,
+	}, membership, queueService), nil
+}
+
+func newAuthenticator(cfg config.Config) (auth.Authenticator, error) {
+	bearer, err := newBearerAuthenticator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSClientAuth == string(tlsutil.ClientAuthNone) {
+		return bearer, nil
+	}
+
+	scopes := strings.FieldsFunc(cfg.TLSClientScopes, func(r rune) bool { return r == ',' })
+	certs, err := auth.LoadCertAuthenticator(cfg.TLSClientIdentities, scopes)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("mTLS client certificate authentication enabled", "mode", cfg.TLSClientAuth)
+	if bearer == nil {
+		return certs, nil
+	}
+	return auth.Chain(certs, bearer), nil
+}
+
+func newBearerAuthenticator(cfg config.Config) (auth.Authenticator, error) {
+	switch cfg.AuthMode {
+	case "none":
+		return nil, nil
+
+	case "apikey":
+		keyStore, err := auth.LoadAPIKeys(cfg.APIKeys, cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		if keyStore.Len() == 0 {
+			return nil, nil
+		}
+		slog.Info("API key authentication enabled", "keys", keyStore.Len())
+		return keyStore, nil
+
+	case "jwt":
+		source := cfg.JWKSURL
+		if source == "" {
+			source = cfg.JWKSFile
+		}
+		if source == "" {
+			return nil, fmt.Errorf("JWKS_URL or JWKS_FILE is required in jwt mode")
+		}
+		keys, err := auth.NewJWKS(source, cfg.JWKSRefresh)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("JWT authentication enabled", "jwks", source)
+		return auth.NewJWTAuthenticator(keys, auth.JWTConfig{
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+			TenantClaim: cfg.JWTTenantClaim,
+			ScopeClaim:  cfg.JWTScopeClaim,
+			Leeway:      time.Minute,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown AUTH_MODE %q", cfg.AuthMode)
 }
@@ -0,0 +1,424 @@
+package queue
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"TaskQueue/internal/model"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+var ErrBreakerNotFound = &QueueError{Message: "circuit breaker not found"}
+
+// BreakerConfig - порог ошибок и время восстановления. Нулевой ErrorRate выключает breaker.
+type BreakerConfig struct {
+	// Доля ошибок в окне, при которой breaker открывается
+	ErrorRate float64
+	// Минимум попыток в окне для оценки доли ошибок
+	MinRequests int
+	Window      time.Duration
+	// Сколько breaker открыт до пробных попыток
+	Cooldown time.Duration
+	// Пробных попыток в half-open
+	Probes int
+}
+
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{MinRequests: 10, Window: 30 * time.Second, Cooldown: 30 * time.Second, Probes: 1}
+}
+
+func (c BreakerConfig) enabled() bool {
+	return c.ErrorRate > 0
+}
+
+// ParseBreakerConfig разбирает "rate=0.5,min=10,window=30s,cooldown=30s,probes=1".
+// Пустая строка - breaker выключен.
+func ParseBreakerConfig(spec string) (BreakerConfig, error) {
+	config := DefaultBreakerConfig()
+	for _, field := range strings.Split(spec, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		name, value, found := strings.Cut(field, "=")
+		if !found {
+			return config, fmt.Errorf("invalid breaker setting %q", field)
+		}
+
+		var err error
+		switch name {
+		case "rate":
+			config.ErrorRate, err = strconv.ParseFloat(value, 64)
+		case "min":
+			config.MinRequests, err = strconv.Atoi(value)
+		case "window":
+			config.Window, err = time.ParseDuration(value)
+		case "cooldown":
+			config.Cooldown, err = time.ParseDuration(value)
+		case "probes":
+			config.Probes, err = strconv.Atoi(value)
+		default:
+			return config, fmt.Errorf("unknown breaker setting %q", name)
+		}
+		if err != nil {
+			return config, fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	if config.ErrorRate < 0 || config.ErrorRate > 1 {
+		return config, fmt.Errorf("rate must be between 0 and 1")
+	}
+	if config.MinRequests <= 0 || config.Window <= 0 || config.Cooldown <= 0 || config.Probes <= 0 {
+		return config, fmt.Errorf("min, window, cooldown and probes must be positive")
+	}
+	return config, nil
+}
+
+// BreakerStatus - состояние breaker для /admin/breakers
+type BreakerStatus struct {
+	Key         string         `json:"key"`
+	State       BreakerState   `json:"state"`
+	Requests    int            `json:"requests"`
+	Failures    int            `json:"failures"`
+	Held        int            `json:"held"`
+	OpenedAt    *time.Time     `json:"opened_at,omitempty"`
+	ErrorRate   float64        `json:"error_rate"`
+	MinRequests int            `json:"min_requests"`
+	Window      model.Duration `json:"window"`
+	Cooldown    model.Duration `json:"cooldown"`
+}
+
+type breaker struct {
+	config BreakerConfig
+	state  BreakerState
+
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	openedAt time.Time
+	// Пробные попытки half-open: id задачи -> время начала
+	probes map[string]time.Time
+
+	// Задачи, отложенные без учета попытки, пока breaker не закрыт
+	held []heldTask
+}
+
+// heldTask - отложенная задача и ее поколение на момент отказа
+type heldTask struct {
+	task       *model.Task
+	generation uint64
+}
+
+// breakerRegistry хранит breakers по ключу: resource задачи или ее тип
+type breakerRegistry struct {
+	mu            sync.Mutex
+	defaultConfig BreakerConfig
+	configs       map[string]BreakerConfig
+	breakers      map[string]*breaker
+}
+
+func newBreakerRegistry() *breakerRegistry {
+	return &breakerRegistry{
+		configs:  make(map[string]BreakerConfig),
+		breakers: make(map[string]*breaker),
+	}
+}
+
+func breakerKey(task *model.Task) string {
+	if task.Resource != "" {
+		return task.Resource
+	}
+	return task.Type
+}
+
+// get вызывается под mu; nil - для ключа breaker выключен
+func (r *breakerRegistry) get(key string) *breaker {
+	if b, exists := r.breakers[key]; exists {
+		return b
+	}
+	config, exists := r.configs[key]
+	if !exists {
+		config = r.defaultConfig
+	}
+	if !config.enabled() {
+		return nil
+	}
+	b := &breaker{config: config, state: BreakerClosed, windowStart: time.Now()}
+	r.breakers[key] = b
+	breakerState.Set(0, key)
+	return b
+}
+
+// setState вызывается под mu
+func (b *breaker) setState(key string, state BreakerState, now time.Time) {
+	b.state = state
+	b.probes = nil
+	b.requests = 0
+	b.failures = 0
+	b.windowStart = now
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+	breakerTransitions.Inc(key, string(state))
+	switch state {
+	case BreakerClosed:
+		breakerState.Set(0, key)
+	case BreakerHalfOpen:
+		breakerState.Set(1, key)
+	case BreakerOpen:
+		breakerState.Set(2, key)
+	}
+}
+
+// allow решает, можно ли выполнять попытку. При отказе задача
+// откладывается в held и будет возвращена в очередь позже.
+func (r *breakerRegistry) allow(task *model.Task) bool {
+	key := breakerKey(task)
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.get(key)
+	if b == nil {
+		return true
+	}
+
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.config.Cooldown {
+		b.setState(key, BreakerHalfOpen, now)
+	}
+	if b.state == BreakerHalfOpen {
+		// Пробы без результата дольше cooldown (зависшая аренда) не держат breaker
+		for id, started := range b.probes {
+			if now.Sub(started) >= b.config.Cooldown {
+				delete(b.probes, id)
+			}
+		}
+		if len(b.probes) < b.config.Probes {
+			if b.probes == nil {
+				b.probes = make(map[string]time.Time)
+			}
+			b.probes[task.ID] = now
+			return true
+		}
+	}
+	if b.state == BreakerClosed {
+		return true
+	}
+
+	b.held = append(b.held, heldTask{task: task, generation: task.GetGeneration()})
+	breakerHeld.Set(float64(len(b.held)), key)
+	return false
+}
+
+// removeHeld убирает отмененную задачу из отложенных
+func (r *breakerRegistry) removeHeld(task *model.Task) {
+	key := breakerKey(task)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, exists := r.breakers[key]
+	if !exists {
+		return
+	}
+	for i, held := range b.held {
+		if held.task == task {
+			b.held = append(b.held[:i], b.held[i+1:]...)
+			breakerHeld.Set(float64(len(b.held)), key)
+			return
+		}
+	}
+}
+
+// record учитывает результат попытки. Возвращает задачи, которые можно
+// вернуть в очередь, и время до следующей проверки, если breaker открылся.
+func (r *breakerRegistry) record(task *model.Task, failed bool) ([]heldTask, time.Duration) {
+	key := breakerKey(task)
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.get(key)
+	if b == nil {
+		return nil, 0
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			b.setState(key, BreakerOpen, now)
+			return nil, b.config.Cooldown
+		}
+		b.setState(key, BreakerClosed, now)
+		return b.takeHeld(key), 0
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.config.Window {
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.config.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.config.ErrorRate {
+			b.setState(key, BreakerOpen, now)
+			return nil, b.config.Cooldown
+		}
+	}
+	return nil, 0
+}
+
+// releaseProbe освобождает пробу задачи, попытка которой закончилась без результата.
+// Если проб больше нет, отложенные задачи возвращаются, чтобы пробу взяла другая.
+func (r *breakerRegistry) releaseProbe(task *model.Task) []heldTask {
+	key := breakerKey(task)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, exists := r.breakers[key]
+	if !exists {
+		return nil
+	}
+	if _, probing := b.probes[task.ID]; !probing {
+		return nil
+	}
+	delete(b.probes, task.ID)
+	if b.state == BreakerHalfOpen && len(b.probes) == 0 {
+		return b.takeHeld(key)
+	}
+	return nil
+}
+
+// takeHeld вызывается под mu
+func (b *breaker) takeHeld(key string) []heldTask {
+	held := b.held
+	b.held = nil
+	breakerHeld.Set(0, key)
+	return held
+}
+
+// release забирает отложенные задачи для пробных попыток после cooldown
+func (r *breakerRegistry) release(key string) []heldTask {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, exists := r.breakers[key]
+	if !exists {
+		return nil
+	}
+	return b.takeHeld(key)
+}
+
+// reset вручную закрывает breaker
+func (r *breakerRegistry) reset(key string) ([]heldTask, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, exists := r.breakers[key]
+	if !exists {
+		return nil, ErrBreakerNotFound
+	}
+	b.setState(key, BreakerClosed, time.Now())
+	return b.takeHeld(key), nil
+}
+
+func (r *breakerRegistry) heldCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	held := 0
+	for _, b := range r.breakers {
+		held += len(b.held)
+	}
+	return held
+}
+
+func (r *breakerRegistry) statuses() []BreakerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]BreakerStatus, 0, len(r.breakers))
+	for key, b := range r.breakers {
+		status := BreakerStatus{
+			Key:         key,
+			State:       b.state,
+			Requests:    b.requests,
+			Failures:    b.failures,
+			Held:        len(b.held),
+			ErrorRate:   b.config.ErrorRate,
+			MinRequests: b.config.MinRequests,
+			Window:      model.Duration(b.config.Window),
+			Cooldown:    model.Duration(b.config.Cooldown),
+		}
+		if b.state != BreakerClosed {
+			openedAt := b.openedAt
+			status.OpenedAt = &openedAt
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses
+}
+
+// holdForBreaker откладывает задачу, если ее breaker открыт. Попытка не засчитывается.
+func (wp *workerPool) holdForBreaker(task *model.Task) bool {
+	if wp.breakers.allow(task) {
+		return false
+	}
+	taskLogger(task, "").Debug("Task held by open circuit breaker", "breaker", breakerKey(task))
+	return true
+}
+
+// recordBreaker учитывает результат попытки и планирует возврат отложенных задач
+func (wp *workerPool) recordBreaker(task *model.Task, failed bool) {
+	released, cooldown := wp.breakers.record(task, failed)
+	key := breakerKey(task)
+	if cooldown > 0 {
+		taskLogger(task, "").Warn("Circuit breaker opened", "breaker", key, "cooldown", cooldown)
+		time.AfterFunc(cooldown, func() {
+			// После остановки или перехода в резерв отложенные задачи не возвращаются
+			select {
+			case <-wp.shutdown:
+				return
+			default:
+			}
+			if wp.suspended.Load() {
+				return
+			}
+			wp.releaseHeld(wp.breakers.release(key))
+		})
+	}
+	wp.releaseHeld(released)
+}
+
+// releaseProbe вызывается, когда попытка задачи завершилась без записи результата
+func (wp *workerPool) releaseProbe(task *model.Task) {
+	wp.releaseHeld(wp.breakers.releaseProbe(task))
+}
+
+func (wp *workerPool) releaseHeld(held []heldTask) {
+	for _, h := range held {
+		wp.requeue(h.task, h.generation)
+	}
+}
+
+func (wp *workerPool) Breakers() []BreakerStatus {
+	return wp.breakers.statuses()
+}
+
+// ResetBreaker закрывает breaker и возвращает отложенные задачи в очередь
+func (wp *workerPool) ResetBreaker(key string) error {
+	released, err := wp.breakers.reset(key)
+	if err != nil {
+		return err
+	}
+	wp.releaseHeld(released)
+	slog.Info("Circuit breaker reset", "breaker", key)
+	return nil
+}
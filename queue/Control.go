@@ -0,0 +1,148 @@
+package queue
+
+import (
+	"time"
+
+	"TaskQueue/internal/model"
+)
+
+var (
+	ErrTaskFinished    = &QueueError{Message: "task is already finished"}
+	ErrTaskNotReplayed = &QueueError{Message: "only failed or cancelled tasks can be replayed"}
+)
+
+// PoolStats - мгновенное состояние пула для дашборда
+type PoolStats struct {
+	Workers     int `json:"workers"`
+	BusyWorkers int `json:"busy_workers"`
+	Queued      int `json:"queued"`
+	Leased      int `json:"leased"`
+	Spilled     int `json:"spilled"`
+	// Задачи, отложенные открытыми circuit breakers
+	Held           int    `json:"held"`
+	CompletedTotal uint64 `json:"completed_total"`
+	FailedTotal    uint64 `json:"failed_total"`
+}
+
+func (wp *workerPool) Stats() PoolStats {
+	spilled := 0
+	if wp.spill != nil {
+		spilled = wp.spill.Len()
+	}
+
+	held := wp.breakers.heldCount()
+
+	wp.leaseMu.Lock()
+	defer wp.leaseMu.Unlock()
+
+	return PoolStats{
+		Spilled:        spilled,
+		Held:           held,
+		Workers:        wp.workers,
+		BusyWorkers:    int(wp.busyWorkers.Load()),
+		Queued:         wp.scheduler.Len() + len(wp.pending),
+		Leased:         len(wp.leased),
+		CompletedTotal: wp.completedTotal.Load(),
+		FailedTotal:    wp.failedTotal.Load(),
+	}
+}
+
+// Cancel останавливает задачу в любом незавершенном состоянии. Выполняемая
+// попытка получает отмену контекста, ее результат игнорируется.
+func (wp *workerPool) Cancel(task *model.Task) error {
+	wp.leaseMu.Lock()
+	if model.IsTerminalStatus(task.GetStatus()) {
+		wp.leaseMu.Unlock()
+		return ErrTaskFinished
+	}
+	// Статус меняется под leaseMu, чтобы воркер не успел взять задачу.
+	// Новое поколение отбрасывает ожидающие бэкоффа возвраты в очередь.
+	task.SetStatus("cancelled")
+	task.NextGeneration()
+
+	wp.scheduler.remove(task)
+	for i, pending := range wp.pending {
+		if pending == task {
+			wp.pending = append(wp.pending[:i], wp.pending[i+1:]...)
+			wp.depth.add(task.Queue, tenantOf(task), -1)
+			break
+		}
+	}
+	if _, exists := wp.leased[task.ID]; exists {
+		wp.dropLease(task)
+	}
+	wp.leaseMu.Unlock()
+	wp.breakers.removeHeld(task)
+	wp.releaseProbe(task)
+
+	wp.taskRepo.Update(task)
+	wp.publishStatus(task)
+	tasksCancelled.Inc(task.Queue, task.Type)
+
+	logger, logBuffer := wp.attemptLogger(task, "")
+	logger.Info("Task cancelled")
+	logBuffer.Close()
+	return nil
+}
+
+// Replay заново ставит в очередь проваленную или отмененную задачу
+// с полным набором попыток
+func (wp *workerPool) Replay(task *model.Task) error {
+	wp.leaseMu.Lock()
+	status := task.GetStatus()
+	if status != "failed" && status != "cancelled" {
+		wp.leaseMu.Unlock()
+		return ErrTaskNotReplayed
+	}
+	task.ResetForReplay()
+	task.SetStatus("queued")
+	task.NextGeneration()
+	wp.leaseMu.Unlock()
+
+	wp.taskLogs.Reset(task.ID)
+	if err := wp.Enqueue(task); err != nil {
+		task.SetStatus(status)
+		return err
+	}
+	wp.taskRepo.Update(task)
+	tasksReplayed.Inc(task.Queue, task.Type)
+	taskLogger(task, "").Info("Task replayed", "previous_status", status)
+	return nil
+}
+
+// Restore ставит в очередь импортированную задачу в статусе queued.
+// Как и повторы, не проверяет лимиты, чтобы не потерять задачу.
+func (wp *workerPool) Restore(task *model.Task) {
+	// Задача, экспортированная в бэкоффе, ждет оставшееся время повтора
+	generation := task.GetGeneration()
+	if retryAt := task.GetRetryAt(); time.Until(retryAt) > 0 {
+		wp.requeueAfter(task, generation, time.Until(retryAt), nil)
+		wp.publishStatus(task)
+		taskLogger(task, "").Debug("Task restored", "retry_at", retryAt)
+		return
+	}
+	wp.requeue(task, generation)
+	wp.publishStatus(task)
+	taskLogger(task, "").Debug("Task restored")
+}
+
+// Remove убирает из очереди пула задачу, ожидающую воркера (передача другому узлу).
+// Задачи в бэкоффе, отложенные breaker и выполняемые не трогаются - false.
+func (wp *workerPool) Remove(task *model.Task) bool {
+	wp.leaseMu.Lock()
+	defer wp.leaseMu.Unlock()
+	if task.GetStatus() != "queued" {
+		return false
+	}
+	if wp.scheduler.remove(task) {
+		return true
+	}
+	for i, pending := range wp.pending {
+		if pending == task {
+			wp.pending = append(wp.pending[:i], wp.pending[i+1:]...)
+			wp.depth.add(task.Queue, tenantOf(task), -1)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package queue
+
+import "sync"
+
+type depthKey struct {
+	queue  string
+	tenant string
+}
+
+// queueDepth считает задачи, ожидающие в памяти пула (очередь планировщика
+// и задачи для удаленных воркеров), по очереди и арендатору
+type queueDepth struct {
+	mu     sync.Mutex
+	counts map[depthKey]int
+}
+
+func newQueueDepth() *queueDepth {
+	return &queueDepth{counts: make(map[depthKey]int)}
+}
+
+func (d *queueDepth) add(queue, tenant string, delta int) {
+	key := depthKey{queue: queue, tenant: tenant}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n := d.counts[key] + delta; n > 0 {
+		d.counts[key] = n
+	} else {
+		delete(d.counts, key)
+	}
+}
+
+func (d *queueDepth) get(queue string, allowed func(queue, tenant string) bool) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	total := 0
+	for key, n := range d.counts {
+		if key.queue == queue && (allowed == nil || allowed(key.queue, key.tenant)) {
+			total += n
+		}
+	}
+	return total
+}
+
+// QueueDepth - число задач очереди, ожидающих выполнения или выдачи воркеру.
+// allowed отбирает арендаторов; nil - все.
+func (wp *workerPool) QueueDepth(queue string, allowed func(queue, tenant string) bool) int {
+	return wp.depth.get(queue, allowed)
+}
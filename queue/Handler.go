@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"TaskQueue/internal/model"
+)
+
+// Handler выполняет одну попытку задачи. Контекст содержит трассировку задачи.
+type Handler func(ctx context.Context, task *model.Task) error
+
+var errSimulatedFailure = errors.New("simulated failure")
+
+// SimulatedHandler имитирует работу: 100-500мс и 20% вероятность ошибки.
+// Используется для типов задач без зарегистрированного обработчика.
+func SimulatedHandler(ctx context.Context, task *model.Task) error {
+	processingTime := time.Duration(100+rand.Intn(400)) * time.Millisecond
+	select {
+	case <-time.After(processingTime):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if rand.Float64() < 0.2 {
+		return errSimulatedFailure
+	}
+	return nil
+}
+
+// PermanentError - ошибка, которую повтор не исправит: задача сразу становится failed
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent помечает ошибку обработчика как неповторяемую
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// RetryAfterError задает задержку до следующей попытки вместо политики повторов
+type RetryAfterError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter просит повторить задачу не раньше, чем через delay
+func RetryAfter(err error, delay time.Duration) error {
+	return &RetryAfterError{Err: err, Delay: delay}
+}
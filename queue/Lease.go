@@ -0,0 +1,304 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/tracing"
+)
+
+const (
+	DefaultVisibilityTimeout = 30 * time.Second
+	MaxVisibilityTimeout     = time.Hour
+)
+
+type LeaseRequest struct {
+	WorkerID string
+	Types    []string
+	// Пустой список - любые очереди
+	Queues            []string
+	Max               int
+	VisibilityTimeout time.Duration
+	// Непустой - только задачи этого арендатора
+	Tenant string
+	// Ключ, которым запрошена аренда; только он может ее продлить и завершить
+	Principal string
+}
+
+// LeaseHolder - воркер и ключ, от имени которых продлевают и завершают аренду
+type LeaseHolder struct {
+	WorkerID  string
+	Principal string
+}
+
+// LeasedTask - снимок задачи на момент выдачи воркеру
+type LeasedTask struct {
+	ID             string            `json:"id"`
+	Type           string            `json:"type"`
+	Queue          string            `json:"queue"`
+	Payload        json.RawMessage   `json:"payload"`
+	ContentType    string            `json:"content_type,omitempty"`
+	Attempt        int               `json:"attempt"`
+	MaxRetries     int               `json:"max_retries"`
+	LeaseExpiresAt time.Time         `json:"lease_expires_at"`
+	TraceContext   map[string]string `json:"trace_context,omitempty"`
+	// Чекпоинт предыдущей попытки
+	Checkpoint json.RawMessage `json:"checkpoint,omitempty"`
+}
+
+func clampVisibilityTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultVisibilityTimeout
+	}
+	if timeout > MaxVisibilityTimeout {
+		return MaxVisibilityTimeout
+	}
+	return timeout
+}
+
+func (wp *workerPool) isRemote(task *model.Task) bool {
+	return wp.remoteTypes[task.Type]
+}
+
+func (wp *workerPool) enqueuePending(task *model.Task) error {
+	wp.leaseMu.Lock()
+	defer wp.leaseMu.Unlock()
+
+	if len(wp.pending) >= wp.pendingLimit {
+		return ErrQueueFull
+	}
+	tenant := tenantOf(task)
+	if limit := wp.tenantLimits(tenant).MaxQueued; limit > 0 {
+		queued := 0
+		for _, pending := range wp.pending {
+			if tenantOf(pending) == tenant {
+				queued++
+			}
+		}
+		if queued >= limit {
+			return &TenantLimitError{Tenant: tenant, Limit: "queued", RetryAfter: minRetryAfter}
+		}
+	}
+	wp.pending = append(wp.pending, task)
+	wp.depth.add(task.Queue, tenant, 1)
+	return nil
+}
+
+// feedPending добавляет задачу из spill, если есть место
+func (wp *workerPool) feedPending(task *model.Task) bool {
+	wp.leaseMu.Lock()
+	defer wp.leaseMu.Unlock()
+	if len(wp.pending) >= wp.pendingLimit {
+		return false
+	}
+	wp.pending = append(wp.pending, task)
+	wp.depth.add(task.Queue, tenantOf(task), 1)
+	return true
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (wp *workerPool) Lease(req LeaseRequest) []LeasedTask {
+	if wp.suspended.Load() {
+		return nil
+	}
+	if req.Max <= 0 {
+		req.Max = 1
+	}
+	timeout := clampVisibilityTimeout(req.VisibilityTimeout)
+	now := time.Now()
+
+	wp.leaseMu.Lock()
+	selected := wp.selectPending(req)
+	var leased []*model.Task
+	var grants []LeasedTask
+	remaining := wp.pending[:0]
+	for _, task := range wp.pending {
+		lease, decided := selected[task]
+		if decided {
+			wp.depth.add(task.Queue, tenantOf(task), -1)
+		}
+		if decided && !lease {
+			// Отложена открытым circuit breaker
+			continue
+		}
+		if lease {
+			task.SetStatus("running")
+			task.LeaseOwner = req.WorkerID
+			task.LeasePrincipal = req.Principal
+			task.LeaseExpiresAt = now.Add(timeout)
+			wp.leased[task.ID] = task
+			leased = append(leased, task)
+			grants = append(grants, LeasedTask{
+				ID:             task.ID,
+				Type:           task.Type,
+				Queue:          task.Queue,
+				Payload:        task.Payload,
+				ContentType:    task.ContentType,
+				Attempt:        task.GetRetries() + 1,
+				MaxRetries:     task.MaxRetries,
+				LeaseExpiresAt: task.LeaseExpiresAt,
+				TraceContext:   task.TraceContext,
+				Checkpoint:     task.GetCheckpoint(),
+			})
+			continue
+		}
+		remaining = append(remaining, task)
+	}
+	// Обнуляем хвост, чтобы не держать ссылки на выданные задачи
+	for i := len(remaining); i < len(wp.pending); i++ {
+		wp.pending[i] = nil
+	}
+	wp.pending = remaining
+	wp.leaseMu.Unlock()
+
+	for _, task := range leased {
+		wp.drain.record()
+		wp.taskRepo.Update(task)
+		wp.publishStatus(task)
+		logger, _ := wp.attemptLogger(task, req.WorkerID)
+		logger.Debug("Task leased", "visibility_timeout", timeout)
+	}
+	return grants
+}
+
+// selectPending выбирает задачи для воркера взвешенным round-robin по арендаторам:
+// за круг арендатор получает до Weight задач. Лимит одновременных задач общий
+// с локальными воркерами: выбранная задача занимает слот в планировщике.
+// Задачи с открытым circuit breaker попадают в результат со значением false.
+// Вызывается под leaseMu.
+func (wp *workerPool) selectPending(req LeaseRequest) map[*model.Task]bool {
+	candidates := make(map[string][]*model.Task)
+	var tenants []string
+	for _, task := range wp.pending {
+		tenant := tenantOf(task)
+		if !contains(req.Types, task.Type) ||
+			(len(req.Queues) > 0 && !contains(req.Queues, task.Queue)) ||
+			(req.Tenant != "" && req.Tenant != tenant) {
+			continue
+		}
+		if _, exists := candidates[tenant]; !exists {
+			tenants = append(tenants, tenant)
+		}
+		candidates[tenant] = append(candidates[tenant], task)
+	}
+
+	selected := make(map[*model.Task]bool)
+	leased := 0
+	for progress := true; progress && leased < req.Max; {
+		progress = false
+		for _, tenant := range tenants {
+			limits := wp.tenantLimits(tenant)
+			for n := 0; n < limits.Weight && len(candidates[tenant]) > 0 && leased < req.Max; {
+				task := candidates[tenant][0]
+				if !wp.scheduler.acquire(task) {
+					break
+				}
+				candidates[tenant] = candidates[tenant][1:]
+				progress = true
+				if !wp.breakers.allow(task) {
+					wp.scheduler.done(task)
+					selected[task] = false
+					continue
+				}
+				selected[task] = true
+				leased++
+				n++
+			}
+		}
+	}
+	return selected
+}
+
+// leasedTask возвращает арендованную задачу, если аренду держит holder.
+// Вызывается под leaseMu.
+func (wp *workerPool) leasedTask(taskID string, holder LeaseHolder) (*model.Task, error) {
+	task, exists := wp.leased[taskID]
+	if !exists {
+		return nil, ErrTaskNotLeased
+	}
+	if task.LeaseOwner != holder.WorkerID || task.LeasePrincipal != holder.Principal {
+		return nil, ErrLeaseNotHeld
+	}
+	if time.Now().After(task.LeaseExpiresAt) {
+		return nil, ErrLeaseExpired
+	}
+	return task, nil
+}
+
+// releaseLease снимает аренду перед записью результата и возвращает
+// поколение задачи, к которому относится результат
+func (wp *workerPool) releaseLease(taskID string, holder LeaseHolder) (*model.Task, uint64, error) {
+	wp.leaseMu.Lock()
+	defer wp.leaseMu.Unlock()
+
+	task, err := wp.leasedTask(taskID, holder)
+	if err != nil {
+		return nil, 0, err
+	}
+	wp.dropLease(task)
+	return task, task.GetGeneration(), nil
+}
+
+// dropLease снимает аренду задачи и отменяет ее локальную попытку. Слот
+// арендатора удаленной аренды освобождается сразу, локальной - воркером
+// после попытки. Вызывается под leaseMu.
+func (wp *workerPool) dropLease(task *model.Task) {
+	cancel, local := wp.leaseCancels[task.ID]
+	if local {
+		cancel()
+		delete(wp.leaseCancels, task.ID)
+	} else {
+		wp.scheduler.done(task)
+	}
+	delete(wp.leased, task.ID)
+	task.LeaseOwner = ""
+	task.LeasePrincipal = ""
+	task.LeaseExpiresAt = time.Time{}
+}
+
+func (wp *workerPool) Heartbeat(taskID string, holder LeaseHolder, timeout time.Duration) (time.Time, error) {
+	wp.leaseMu.Lock()
+	defer wp.leaseMu.Unlock()
+
+	task, err := wp.leasedTask(taskID, holder)
+	if err != nil {
+		return time.Time{}, err
+	}
+	task.LeaseExpiresAt = time.Now().Add(clampVisibilityTimeout(timeout))
+	return task.LeaseExpiresAt, nil
+}
+
+func (wp *workerPool) Complete(taskID string, holder LeaseHolder) error {
+	task, _, err := wp.releaseLease(taskID, holder)
+	if err != nil {
+		return err
+	}
+	logger, logBuffer := wp.attemptLogger(task, holder.WorkerID)
+	defer logBuffer.Close()
+	wp.complete(task, logger)
+	return nil
+}
+
+func (wp *workerPool) Fail(taskID string, holder LeaseHolder, reason string) error {
+	task, generation, err := wp.releaseLease(taskID, holder)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		reason = "remote worker reported failure"
+	}
+	logger, logBuffer := wp.attemptLogger(task, holder.WorkerID)
+	defer logBuffer.Close()
+	wp.handleFailure(tracing.ContextFromTask(wp.ctx, task), task, generation, errors.New(reason), logger)
+	return nil
+}
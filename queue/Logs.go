@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"context"
+	"log/slog"
+
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/tasklog"
+)
+
+type loggerKey struct{}
+
+// LoggerFromContext возвращает логгер попытки: строки попадают и в общий лог,
+// и в буфер, доступный через GET /tasks/{id}/logs
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+func taskLogger(task *model.Task, workerID any) *slog.Logger {
+	return withTaskFields(slog.Default(), task, workerID)
+}
+
+func withTaskFields(logger *slog.Logger, task *model.Task, workerID any) *slog.Logger {
+	return logger.With(
+		"task_id", task.ID,
+		"type", task.Type,
+		"queue", task.Queue,
+		"attempt", task.GetRetries()+1,
+		"worker_id", workerID,
+		"request_id", task.RequestID,
+	)
+}
+
+// attemptLogger пишет в буфер текущей попытки задачи
+func (wp *workerPool) attemptLogger(task *model.Task, workerID any) (*slog.Logger, *tasklog.Buffer) {
+	buffer := wp.taskLogs.Attempt(task.ID, task.GetRetries()+1)
+	logger := slog.New(tasklog.NewHandler(slog.Default().Handler(), buffer))
+	return withTaskFields(logger, task, workerID), buffer
+}
+
+func (wp *workerPool) TaskLogs(taskID string, attempt int) (*tasklog.Buffer, int, bool) {
+	return wp.taskLogs.Get(taskID, attempt)
+}
@@ -0,0 +1,81 @@
+package queue
+
+import "TaskQueue/internal/metrics"
+
+var (
+	tasksEnqueued = metrics.Default.Counter("taskqueue_tasks_enqueued_total",
+		"Tasks accepted into the queue.", "queue", "type")
+	tasksCompleted = metrics.Default.Counter("taskqueue_tasks_completed_total",
+		"Tasks finished successfully.", "queue", "type")
+	tasksFailed = metrics.Default.Counter("taskqueue_tasks_failed_total",
+		"Tasks failed after exhausting retries.", "queue", "type")
+	taskRetries = metrics.Default.Counter("taskqueue_task_retries_total",
+		"Failed attempts scheduled for retry.", "queue", "type")
+	taskAttempts = metrics.Default.Counter("taskqueue_task_attempts_total",
+		"Attempts executed by local workers.", "queue", "type", "result")
+	taskAttemptSeconds = metrics.Default.Counter("taskqueue_task_attempt_seconds_total",
+		"Time spent executing attempts by local workers.", "queue", "type")
+	enqueueRejected = metrics.Default.Counter("taskqueue_enqueue_rejected_total",
+		"Tasks rejected because the queue was full.", "queue", "mode")
+	tasksSpilled = metrics.Default.Counter("taskqueue_tasks_spilled_total",
+		"Tasks written to the on-disk overflow buffer.", "queue")
+	tasksCancelled = metrics.Default.Counter("taskqueue_tasks_cancelled_total",
+		"Tasks cancelled by operators.", "queue", "type")
+	tasksReplayed = metrics.Default.Counter("taskqueue_tasks_replayed_total",
+		"Failed or cancelled tasks replayed by operators.", "queue", "type")
+	taskPanics = metrics.Default.Counter("taskqueue_task_panics_total",
+		"Attempts that ended with a handler panic.", "queue", "type")
+	tasksQuarantined = metrics.Default.Counter("taskqueue_tasks_quarantined_total",
+		"Tasks moved to quarantine after repeated panics or worker crashes.", "queue", "type")
+	tasksReleased = metrics.Default.Counter("taskqueue_tasks_released_total",
+		"Quarantined tasks released by operators.", "queue", "type")
+
+	breakerState = metrics.Default.Gauge("taskqueue_breaker_state",
+		"Circuit breaker state: 0 closed, 1 half-open, 2 open.", "breaker")
+	breakerTransitions = metrics.Default.Counter("taskqueue_breaker_transitions_total",
+		"Circuit breaker state changes.", "breaker", "state")
+	breakerHeld = metrics.Default.Gauge("taskqueue_breaker_held_tasks",
+		"Tasks held by an open circuit breaker.", "breaker")
+
+	tenantEnqueued = metrics.Default.Counter("taskqueue_tenant_enqueued_total",
+		"Tasks accepted per tenant.", "tenant")
+	tenantRejected = metrics.Default.Counter("taskqueue_tenant_rejected_total",
+		"Tasks rejected by tenant limits.", "tenant", "limit")
+	tenantCompleted = metrics.Default.Counter("taskqueue_tenant_completed_total",
+		"Tasks finished successfully per tenant.", "tenant")
+	tenantFailed = metrics.Default.Counter("taskqueue_tenant_failed_total",
+		"Tasks failed after exhausting retries per tenant.", "tenant")
+	tenantQueued = metrics.Default.Gauge("taskqueue_tenant_queued_tasks",
+		"Tasks waiting for local workers per tenant.", "tenant")
+	tenantRunning = metrics.Default.Gauge("taskqueue_tenant_running_tasks",
+		"Tasks executed by local workers per tenant.", "tenant")
+
+	reaperRuns = metrics.Default.Counter("taskqueue_reaper_runs_total",
+		"Reaper passes over leased tasks.")
+	reaperRequeued = metrics.Default.Counter("taskqueue_reaper_requeued_total",
+		"Tasks with expired leases returned to the queue.", "queue", "type")
+	reaperFailed = metrics.Default.Counter("taskqueue_reaper_failed_total",
+		"Tasks with expired leases failed after exhausting retries.", "queue", "type")
+)
+
+func (wp *workerPool) registerGauges() {
+	metrics.Default.GaugeFunc("taskqueue_queue_depth", "Tasks waiting in the queue.", func() float64 {
+		wp.leaseMu.Lock()
+		defer wp.leaseMu.Unlock()
+		return float64(wp.scheduler.Len() + len(wp.pending))
+	})
+	metrics.Default.GaugeFunc("taskqueue_leased_tasks", "Tasks currently leased by local or remote workers.", func() float64 {
+		wp.leaseMu.Lock()
+		defer wp.leaseMu.Unlock()
+		return float64(len(wp.leased))
+	})
+	metrics.Default.GaugeFunc("taskqueue_spilled_tasks", "Tasks waiting in the on-disk overflow buffer.", func() float64 {
+		if wp.spill == nil {
+			return 0
+		}
+		return float64(wp.spill.Len())
+	})
+	metrics.Default.GaugeFunc("taskqueue_busy_workers", "Local workers executing a task.", func() float64 {
+		return float64(wp.busyWorkers.Load())
+	})
+}
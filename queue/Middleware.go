@@ -0,0 +1,185 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"TaskQueue/internal/logging"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/tracing"
+)
+
+// Middleware оборачивает обработчик попытки
+type Middleware func(Handler) Handler
+
+// Chain собирает цепочку: первый middleware - внешний
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Use добавляет middleware для всех типов задач
+func (wp *workerPool) Use(middlewares ...Middleware) {
+	wp.handlersMu.Lock()
+	defer wp.handlersMu.Unlock()
+	wp.middlewares = append(wp.middlewares, middlewares...)
+}
+
+// UseFor добавляет middleware для одного типа задач, они выполняются после общих
+func (wp *workerPool) UseFor(taskType string, middlewares ...Middleware) {
+	wp.handlersMu.Lock()
+	defer wp.handlersMu.Unlock()
+	wp.typeMiddlewares[taskType] = append(wp.typeMiddlewares[taskType], middlewares...)
+}
+
+// chainFor собирает обработчик попытки: встроенные middleware, общие,
+// middleware типа и сам обработчик
+func (wp *workerPool) chainFor(taskType string) Handler {
+	wp.handlersMu.RLock()
+	handler, exists := wp.handlers[taskType]
+	if !exists {
+		handler = wp.defaultHandler
+	}
+	middlewares := make([]Middleware, 0, len(wp.builtins)+len(wp.middlewares)+len(wp.typeMiddlewares[taskType]))
+	middlewares = append(middlewares, wp.builtins...)
+	middlewares = append(middlewares, wp.middlewares...)
+	middlewares = append(middlewares, wp.typeMiddlewares[taskType]...)
+	wp.handlersMu.RUnlock()
+	return Chain(handler, middlewares...)
+}
+
+// attempt - данные попытки локального воркера для встроенных middleware
+type attempt struct {
+	workerID int
+	owner    string
+	logger   *slog.Logger
+}
+
+type attemptKey struct{}
+
+func withAttempt(ctx context.Context, a *attempt) context.Context {
+	return context.WithValue(ctx, attemptKey{}, a)
+}
+
+func attemptFromContext(ctx context.Context) (*attempt, bool) {
+	a, ok := ctx.Value(attemptKey{}).(*attempt)
+	return a, ok
+}
+
+// builtinMiddlewares - наблюдаемость попытки, выполняется снаружи пользовательских
+func (wp *workerPool) builtinMiddlewares() []Middleware {
+	return []Middleware{wp.attemptContext, attemptTracing, wp.attemptMetrics, attemptLogging, Recover()}
+}
+
+// attemptContext кладет в контекст репортер, логгер попытки и request_id
+func (wp *workerPool) attemptContext(next Handler) Handler {
+	return func(ctx context.Context, task *model.Task) error {
+		if a, ok := attemptFromContext(ctx); ok {
+			ctx = withReporter(ctx, &taskReporter{wp: wp, task: task, owner: a.owner})
+			ctx = withLogger(ctx, a.logger)
+		}
+		if task.RequestID != "" {
+			ctx = logging.WithRequestID(ctx, task.RequestID)
+		}
+		return next(ctx, task)
+	}
+}
+
+func attemptTracing(next Handler) Handler {
+	return func(ctx context.Context, task *model.Task) error {
+		opts := []trace.SpanStartOption{taskSpanAttributes(task)}
+		if a, ok := attemptFromContext(ctx); ok {
+			opts = append(opts, trace.WithAttributes(attribute.Int("worker.id", a.workerID)))
+		}
+		ctx, span := tracing.Tracer().Start(ctx, "task.attempt", opts...)
+		defer span.End()
+
+		err := next(ctx, task)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+func (wp *workerPool) attemptMetrics(next Handler) Handler {
+	return func(ctx context.Context, task *model.Task) error {
+		wp.busyWorkers.Add(1)
+		defer wp.busyWorkers.Add(-1)
+
+		start := time.Now()
+		err := next(ctx, task)
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		taskAttempts.Inc(task.Queue, task.Type, result)
+		taskAttemptSeconds.Add(time.Since(start).Seconds(), task.Queue, task.Type)
+		return err
+	}
+}
+
+func attemptLogging(next Handler) Handler {
+	return func(ctx context.Context, task *model.Task) error {
+		start := time.Now()
+		err := next(ctx, task)
+		LoggerFromContext(ctx).Debug("Task attempt finished", "duration", time.Since(start), "error", err)
+		return err
+	}
+}
+
+// PanicError - паника обработчика, перехваченная Recover
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Recover превращает панику обработчика в ошибку попытки
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, task *model.Task) (err error) {
+			defer func() {
+				if value := recover(); value != nil {
+					err = &PanicError{Value: value, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, task)
+		}
+	}
+}
+
+type payloadKey struct{}
+
+// DecodeJSON разбирает payload задачи в T; обработчик получает значение
+// через PayloadFromContext. Ошибка разбора - неудачная попытка.
+func DecodeJSON[T any]() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, task *model.Task) error {
+			var payload T
+			if err := json.Unmarshal(task.Payload, &payload); err != nil {
+				return fmt.Errorf("decode payload: %w", err)
+			}
+			return next(context.WithValue(ctx, payloadKey{}, payload), task)
+		}
+	}
+}
+
+func PayloadFromContext[T any](ctx context.Context) (T, bool) {
+	payload, ok := ctx.Value(payloadKey{}).(T)
+	return payload, ok
+}
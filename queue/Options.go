@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"time"
+
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/tasklog"
+)
+
+type Option func(*workerPool)
+
+// WithRetryPolicies задает политику по умолчанию и политики отдельных очередей.
+// Политика из задачи имеет приоритет над ними.
+func WithRetryPolicies(defaultPolicy model.RetryPolicy, queues map[string]model.RetryPolicy) Option {
+	return func(wp *workerPool) {
+		wp.defaultRetryPolicy = defaultPolicy
+		wp.queueRetryPolicies = queues
+	}
+}
+
+// WithRemoteTypes отдает задачи этих типов удаленным воркерам через lease протокол
+func WithRemoteTypes(types ...string) Option {
+	return func(wp *workerPool) {
+		for _, t := range types {
+			wp.remoteTypes[t] = true
+		}
+	}
+}
+
+// WithVisibilityTimeout задает время аренды попытки локального воркера
+func WithVisibilityTimeout(timeout time.Duration) Option {
+	return func(wp *workerPool) {
+		wp.visibilityTimeout = timeout
+	}
+}
+
+func WithReapInterval(interval time.Duration) Option {
+	return func(wp *workerPool) {
+		wp.reapInterval = interval
+	}
+}
+
+// WithTaskLogs задает хранилище логов попыток
+func WithTaskLogs(store *tasklog.Store) Option {
+	return func(wp *workerPool) {
+		wp.taskLogs = store
+	}
+}
+
+// WithOverflow задает режим переполнения и предел ожидания в режиме block
+func WithOverflow(mode OverflowMode, maxWait time.Duration) Option {
+	return func(wp *workerPool) {
+		wp.overflowMode = mode
+		if maxWait > 0 {
+			wp.maxEnqueueWait = maxWait
+		}
+	}
+}
+
+// WithSpill задает каталог и лимит размера для режима spill
+func WithSpill(dir string, maxBytes int64) Option {
+	return func(wp *workerPool) {
+		wp.spillDir = dir
+		wp.spillMaxBytes = maxBytes
+	}
+}
+
+// WithTenantLimits задает лимиты по умолчанию и лимиты отдельных арендаторов
+func WithTenantLimits(defaultLimits TenantLimits, tenants map[string]TenantLimits) Option {
+	return func(wp *workerPool) {
+		wp.defaultTenantLimits = defaultLimits
+		for tenant, limits := range tenants {
+			wp.tenants[tenant] = limits
+		}
+	}
+}
+
+// WithMiddleware добавляет middleware для всех типов задач
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(wp *workerPool) {
+		wp.middlewares = append(wp.middlewares, middlewares...)
+	}
+}
+
+// WithTypeMiddleware добавляет middleware для одного типа задач
+func WithTypeMiddleware(taskType string, middlewares ...Middleware) Option {
+	return func(wp *workerPool) {
+		wp.typeMiddlewares[taskType] = append(wp.typeMiddlewares[taskType], middlewares...)
+	}
+}
+
+// WithPoisonThreshold задает число паник и аварий воркеров до карантина задачи, 0 - без карантина
+func WithPoisonThreshold(threshold int) Option {
+	return func(wp *workerPool) {
+		wp.poisonThreshold = threshold
+	}
+}
+
+// WithBreakers задает circuit breaker по умолчанию и для отдельных ключей (resource или тип задачи)
+func WithBreakers(defaultConfig BreakerConfig, keys map[string]BreakerConfig) Option {
+	return func(wp *workerPool) {
+		wp.breakers.defaultConfig = defaultConfig
+		for key, config := range keys {
+			wp.breakers.configs[key] = config
+		}
+	}
+}
+
+// WithStandby запускает пул в резерве: задачи не выполняются и не выдаются до Resume
+func WithStandby() Option {
+	return func(wp *workerPool) {
+		wp.suspended.Store(true)
+	}
+}
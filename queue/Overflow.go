@@ -0,0 +1,239 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"TaskQueue/internal/model"
+)
+
+// OverflowMode - поведение Enqueue при заполненной очереди
+type OverflowMode string
+
+const (
+	// OverflowReject - сразу ErrQueueFull (503)
+	OverflowReject OverflowMode = "reject"
+	// OverflowBlock - ждать места до таймаута клиента
+	OverflowBlock OverflowMode = "block"
+	// OverflowThrottle - отказ с оценкой Retry-After (429)
+	OverflowThrottle OverflowMode = "throttle"
+	// OverflowSpill - излишек пишется на диск и возвращается по мере освобождения места
+	OverflowSpill OverflowMode = "spill"
+)
+
+func ParseOverflowMode(value string) (OverflowMode, error) {
+	switch mode := OverflowMode(value); mode {
+	case OverflowReject, OverflowBlock, OverflowThrottle, OverflowSpill:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown overflow mode %q", value)
+}
+
+const (
+	minRetryAfter = time.Second
+	maxRetryAfter = time.Minute
+	// Как часто блокированный Enqueue проверяет место в очереди удаленных задач
+	pendingPollInterval = 20 * time.Millisecond
+)
+
+// QueueFullError - очередь заполнена, клиенту стоит повторить через RetryAfter.
+// errors.Is(err, ErrQueueFull) для него истинно.
+type QueueFullError struct {
+	RetryAfter time.Duration
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("queue is full, retry after %s", e.RetryAfter)
+}
+
+func (e *QueueFullError) Is(target error) bool {
+	return target == ErrQueueFull
+}
+
+// EnqueueContext ставит задачу в очередь с учетом режима переполнения.
+// В режиме block ожидание ограничено контекстом и maxEnqueueWait.
+func (wp *workerPool) EnqueueContext(ctx context.Context, task *model.Task) error {
+	if wp.suspended.Load() {
+		return ErrPoolSuspended
+	}
+	task.MarkEnqueued()
+
+	// Лимит скорости арендатора не зависит от режима переполнения
+	err := wp.checkTenantRate(task)
+	if err == nil && wp.isRemote(task) {
+		err = wp.enqueueRemote(ctx, task)
+	} else if err == nil {
+		err = wp.enqueueLocal(ctx, task)
+	}
+	if err != nil {
+		var limitErr *TenantLimitError
+		if errors.As(err, &limitErr) {
+			tenantRejected.Inc(limitErr.Tenant, limitErr.Limit)
+		} else {
+			enqueueRejected.Inc(task.Queue, string(wp.overflowMode))
+		}
+		return err
+	}
+	tasksEnqueued.Inc(task.Queue, task.Type)
+	tenantEnqueued.Inc(tenantOf(task))
+	wp.publishStatus(task)
+	return nil
+}
+
+func (wp *workerPool) Enqueue(task *model.Task) error {
+	return wp.EnqueueContext(context.Background(), task)
+}
+
+func (wp *workerPool) enqueueLocal(ctx context.Context, task *model.Task) error {
+	// Пока в spill есть задачи, новые идут туда же, чтобы сохранить порядок
+	if wp.spill != nil && wp.spill.Len() > 0 {
+		return wp.spillTask(task)
+	}
+
+	err := wp.scheduler.push(task, true, true)
+	if err != errSchedulerFull {
+		return err
+	}
+
+	switch wp.overflowMode {
+	case OverflowBlock:
+		ctx, cancel := wp.blockContext(ctx)
+		defer cancel()
+		for {
+			changed := wp.scheduler.waitChange()
+			if err := wp.scheduler.push(task, true, true); err != errSchedulerFull {
+				return err
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return wp.queueFull()
+			case <-wp.shutdown:
+				return ErrQueueFull
+			}
+		}
+	case OverflowThrottle:
+		return wp.queueFull()
+	case OverflowSpill:
+		if wp.spill != nil {
+			return wp.spillTask(task)
+		}
+	}
+	return ErrQueueFull
+}
+
+func (wp *workerPool) enqueueRemote(ctx context.Context, task *model.Task) error {
+	// Общий с локальными задачами spill сохраняет порядок приема
+	if wp.spill != nil && wp.spill.Len() > 0 {
+		return wp.spillTask(task)
+	}
+	err := wp.enqueuePending(task)
+	if err != ErrQueueFull {
+		return err
+	}
+
+	switch wp.overflowMode {
+	case OverflowBlock:
+		ctx, cancel := wp.blockContext(ctx)
+		defer cancel()
+		ticker := time.NewTicker(pendingPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := wp.enqueuePending(task); err == nil {
+					return nil
+				}
+			case <-ctx.Done():
+				return wp.queueFull()
+			case <-wp.shutdown:
+				return ErrQueueFull
+			}
+		}
+	case OverflowThrottle:
+		return wp.queueFull()
+	case OverflowSpill:
+		if wp.spill != nil {
+			return wp.spillTask(task)
+		}
+	}
+	return err
+}
+
+func (wp *workerPool) blockContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wp.maxEnqueueWait {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, wp.maxEnqueueWait)
+}
+
+func (wp *workerPool) queueFull() error {
+	return &QueueFullError{RetryAfter: wp.retryAfter()}
+}
+
+// retryAfter оценивает время освобождения места по темпу разбора очереди
+func (wp *workerPool) retryAfter() time.Duration {
+	rate := wp.drain.rate()
+	if rate <= 0 {
+		return maxRetryAfter
+	}
+
+	wp.leaseMu.Lock()
+	depth := wp.scheduler.Len() + len(wp.pending)
+	wp.leaseMu.Unlock()
+	if wp.spill != nil {
+		depth += wp.spill.Len()
+	}
+
+	// Ждем, пока освободится хотя бы десятая часть очереди
+	need := math.Max(1, float64(depth)/10)
+	retryAfter := time.Duration(need / rate * float64(time.Second))
+	if retryAfter < minRetryAfter {
+		return minRetryAfter
+	}
+	if retryAfter > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return retryAfter.Round(time.Second)
+}
+
+// drainMeter считает задачи, взятые из очереди, по секундам за последнее окно
+type drainMeter struct {
+	mu      sync.Mutex
+	buckets [drainWindow]int
+	stamps  [drainWindow]int64
+}
+
+const drainWindow = 30
+
+func (m *drainMeter) record() {
+	now := time.Now().Unix()
+	i := now % drainWindow
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stamps[i] != now {
+		m.stamps[i] = now
+		m.buckets[i] = 0
+	}
+	m.buckets[i]++
+}
+
+// rate - задач в секунду за окно
+func (m *drainMeter) rate() float64 {
+	now := time.Now().Unix()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total := 0
+	for i := range m.buckets {
+		if now-m.stamps[i] < drainWindow {
+			total += m.buckets[i]
+		}
+	}
+	return float64(total) / drainWindow
+}
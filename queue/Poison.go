@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"TaskQueue/internal/model"
+)
+
+// DefaultPoisonThreshold - паник и аварий, после которых задача уходит в карантин
+const DefaultPoisonThreshold = 3
+
+var ErrTaskNotQuarantined = &QueueError{Message: "task is not quarantined"}
+
+// recordAttemptError сохраняет ошибку попытки в задаче, для паники - со стеком
+func recordAttemptError(task *model.Task, attempt int, err error, logger *slog.Logger) {
+	attemptError := model.AttemptError{Attempt: attempt, Error: err.Error(), Time: time.Now()}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		attemptError.Stack = string(panicErr.Stack)
+		logger.Error("Task panicked", "panic", panicErr.Value, "stack", attemptError.Stack)
+	}
+	task.RecordAttemptError(attemptError)
+}
+
+// poisoned учитывает панику или аварию воркера и сообщает, превышен ли порог.
+// Аварией считается задача локального воркера, оставшаяся в running после рестарта.
+func (wp *workerPool) poisoned(task *model.Task, err error) bool {
+	var strikes int
+	var panicErr *PanicError
+	switch {
+	case errors.As(err, &panicErr):
+		taskPanics.Inc(task.Queue, task.Type)
+		strikes = task.RecordPanic()
+	case errors.Is(err, errInterrupted) && !wp.isRemote(task):
+		strikes = task.RecordCrash()
+	default:
+		return false
+	}
+	return wp.poisonThreshold > 0 && strikes >= wp.poisonThreshold
+}
+
+func (wp *workerPool) quarantine(task *model.Task, logger *slog.Logger) {
+	wp.setStatus(task, "quarantined")
+	tasksQuarantined.Inc(task.Queue, task.Type)
+	snapshot := task.Snapshot()
+	logger.Error("Task quarantined as poison", "panics", snapshot.Panics, "crashes", snapshot.Crashes)
+}
+
+// Release возвращает задачу из карантина в очередь. Счетчики паник
+// сбрасываются, попытки - нет.
+func (wp *workerPool) Release(task *model.Task) error {
+	wp.leaseMu.Lock()
+	if task.GetStatus() != "quarantined" {
+		wp.leaseMu.Unlock()
+		return ErrTaskNotQuarantined
+	}
+	task.ResetPoison()
+	task.SetStatus("queued")
+	generation := task.GetGeneration()
+	wp.leaseMu.Unlock()
+
+	wp.requeue(task, generation)
+	wp.taskRepo.Update(task)
+	wp.publishStatus(task)
+	tasksReleased.Inc(task.Queue, task.Type)
+	taskLogger(task, "").Info("Task released from quarantine")
+	return nil
+}
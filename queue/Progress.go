@@ -0,0 +1,176 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"TaskQueue/internal/events"
+	"TaskQueue/internal/model"
+)
+
+// Reporter передается обработчику через контекст попытки
+type Reporter interface {
+	// Progress сохраняет процент выполнения (0-100) и продлевает аренду попытки
+	Progress(percent float64, message string)
+	// Checkpoint сохраняет состояние, которое получит следующая попытка
+	Checkpoint(data any) error
+	// LastCheckpoint - чекпоинт предыдущих попыток или nil
+	LastCheckpoint() json.RawMessage
+	// Result сохраняет результат попытки, он отдается вместе с задачей
+	Result(data any) error
+}
+
+type reporterKey struct{}
+
+// ReporterFromContext возвращает репортер попытки. Вне пула возвращает
+// пустой репортер, чтобы обработчики могли вызывать его без проверок.
+func ReporterFromContext(ctx context.Context) Reporter {
+	if reporter, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		return reporter
+	}
+	return noopReporter{}
+}
+
+func withReporter(ctx context.Context, reporter Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, reporter)
+}
+
+type taskReporter struct {
+	wp    *workerPool
+	task  *model.Task
+	owner string
+}
+
+func (r *taskReporter) Progress(percent float64, message string) {
+	progress := r.task.SetProgress(clampPercent(percent), message)
+	r.wp.taskRepo.Update(r.task)
+	r.wp.extendLease(r.task.ID, r.owner)
+	r.wp.publishProgress(r.task, progress)
+}
+
+func (r *taskReporter) Checkpoint(data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	r.task.SetCheckpoint(raw)
+	r.wp.taskRepo.Update(r.task)
+	return nil
+}
+
+func (r *taskReporter) LastCheckpoint() json.RawMessage {
+	return r.task.GetCheckpoint()
+}
+
+func (r *taskReporter) Result(data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	r.task.SetResult(raw)
+	r.wp.taskRepo.Update(r.task)
+	return nil
+}
+
+type noopReporter struct{}
+
+func (noopReporter) Progress(float64, string)        {}
+func (noopReporter) Checkpoint(any) error            { return nil }
+func (noopReporter) LastCheckpoint() json.RawMessage { return nil }
+func (noopReporter) Result(any) error                { return nil }
+
+func clampPercent(percent float64) float64 {
+	if percent < 0 {
+		return 0
+	}
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+func (wp *workerPool) publishProgress(task *model.Task, progress model.TaskProgress) {
+	wp.events.Publish(events.Event{
+		Type:     events.TypeProgress,
+		TaskID:   task.ID,
+		TaskType: task.Type,
+		Queue:    task.Queue,
+		Tenant:   task.Tenant,
+		Status:   task.GetStatus(),
+		Attempt:  task.GetRetries() + 1,
+		Progress: &progress,
+	})
+}
+
+// ProgressUpdate - прогресс и чекпоинт попытки удаленного воркера
+type ProgressUpdate struct {
+	// nil - прогресс не меняется
+	Percent *float64
+	Message string
+	// nil - чекпоинт не меняется
+	Checkpoint json.RawMessage
+	// На сколько продлить аренду
+	VisibilityTimeout time.Duration
+}
+
+// Progress сохраняет прогресс и чекпоинт удаленной попытки и, как Heartbeat,
+// продлевает аренду. Возвращает новый срок аренды.
+func (wp *workerPool) Progress(taskID string, holder LeaseHolder, update ProgressUpdate) (time.Time, error) {
+	wp.leaseMu.Lock()
+	task, err := wp.leasedTask(taskID, holder)
+	if err != nil {
+		wp.leaseMu.Unlock()
+		return time.Time{}, err
+	}
+	task.LeaseExpiresAt = time.Now().Add(clampVisibilityTimeout(update.VisibilityTimeout))
+	expiresAt := task.LeaseExpiresAt
+	// Изменения под leaseMu, чтобы не попасть в задачу после завершения аренды
+	if update.Checkpoint != nil {
+		task.SetCheckpoint(update.Checkpoint)
+	}
+	var progress model.TaskProgress
+	if update.Percent != nil {
+		progress = task.SetProgress(clampPercent(*update.Percent), update.Message)
+	}
+	wp.leaseMu.Unlock()
+
+	wp.taskRepo.Update(task)
+	if update.Percent != nil {
+		wp.publishProgress(task, progress)
+	}
+	return expiresAt, nil
+}
+
+// extendLease продлевает аренду, пока ее держит owner
+func (wp *workerPool) extendLease(taskID, owner string) {
+	wp.leaseMu.Lock()
+	defer wp.leaseMu.Unlock()
+
+	if task, exists := wp.leased[taskID]; exists && task.LeaseOwner == owner {
+		task.LeaseExpiresAt = time.Now().Add(wp.visibilityTimeout)
+	}
+}
+
+// setStatus меняет статус, сохраняет задачу и публикует событие
+func (wp *workerPool) setStatus(task *model.Task, status string) {
+	task.SetStatus(status)
+	wp.taskRepo.Update(task)
+	wp.publishStatus(task)
+}
+
+func (wp *workerPool) publishStatus(task *model.Task) {
+	wp.events.Publish(events.Event{
+		Type:     events.TypeStatus,
+		TaskID:   task.ID,
+		TaskType: task.Type,
+		Queue:    task.Queue,
+		Tenant:   task.Tenant,
+		Status:   task.GetStatus(),
+		Attempt:  task.GetRetries() + 1,
+	})
+}
+
+func (wp *workerPool) Subscribe(buffer int) (<-chan events.Event, func()) {
+	return wp.events.Subscribe(buffer)
+}
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/tracing"
+)
+
+var errInterrupted = errors.New("task interrupted before completion")
+
+// acquireLocalLease регистрирует попытку локального воркера и переводит задачу
+// в running. Если воркер зависнет, reaper вернет задачу в очередь и отменит
+// контекст попытки. Возвращает false для отмененной задачи.
+func (wp *workerPool) acquireLocalLease(task *model.Task, owner string, cancel context.CancelFunc) bool {
+	wp.leaseMu.Lock()
+	defer wp.leaseMu.Unlock()
+
+	if task.GetStatus() == "cancelled" {
+		return false
+	}
+	task.SetStatus("running")
+	task.LeaseOwner = owner
+	task.LeasePrincipal = ""
+	task.LeaseExpiresAt = time.Now().Add(wp.visibilityTimeout)
+	wp.leased[task.ID] = task
+	wp.leaseCancels[task.ID] = cancel
+	return true
+}
+
+// reaper периодически ищет running задачи с истекшей арендой
+func (wp *workerPool) reaper() {
+	defer wp.wg.Done()
+
+	ticker := time.NewTicker(wp.reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wp.shutdown:
+			return
+		case <-ticker.C:
+			wp.reapExpiredLeases()
+		}
+	}
+}
+
+func (wp *workerPool) reapExpiredLeases() {
+	reaperRuns.Inc()
+	now := time.Now()
+
+	wp.leaseMu.Lock()
+	var expired []*model.Task
+	var owners []string
+	var generations []uint64
+	for _, task := range wp.leased {
+		if !now.After(task.LeaseExpiresAt) {
+			continue
+		}
+		expired = append(expired, task)
+		owners = append(owners, task.LeaseOwner)
+		generations = append(generations, task.GetGeneration())
+		wp.dropLease(task)
+	}
+	wp.leaseMu.Unlock()
+
+	for i, task := range expired {
+		wp.reap(task, generations[i], ErrLeaseExpired, owners[i], "Lease expired, reaping task")
+	}
+}
+
+// recoverRunning обрабатывает задачи, оставшиеся в статусе running
+// после аварийной остановки (при восстановлении из постоянного хранилища)
+func (wp *workerPool) recoverRunning() {
+	for _, task := range wp.taskRepo.GetAll() {
+		if task.GetStatus() != "running" {
+			continue
+		}
+		wp.leaseMu.Lock()
+		_, leased := wp.leased[task.ID]
+		generation := task.GetGeneration()
+		wp.leaseMu.Unlock()
+		if leased {
+			continue
+		}
+		wp.reap(task, generation, errInterrupted, "", "Recovering interrupted task")
+	}
+}
+
+func (wp *workerPool) reap(task *model.Task, generation uint64, err error, owner, reason string) {
+	logger, logBuffer := wp.attemptLogger(task, owner)
+	defer logBuffer.Close()
+	logger.Warn(reason)
+
+	retried := wp.handleFailure(tracing.ContextFromTask(wp.ctx, task), task, generation, err, logger)
+	if retried {
+		reaperRequeued.Inc(task.Queue, task.Type)
+	} else {
+		reaperFailed.Inc(task.Queue, task.Type)
+	}
+}
@@ -0,0 +1,209 @@
+package queue
+
+import (
+	"errors"
+	"sync"
+
+	"TaskQueue/internal/model"
+)
+
+var errSchedulerFull = errors.New("scheduler is full")
+
+type tenantQueue struct {
+	tasks   []*model.Task
+	deficit int
+	// Попытки арендатора в работе: локальные и удаленные аренды
+	running int
+}
+
+// fairScheduler заменяет общий канал: у каждого арендатора своя FIFO очередь,
+// воркеры выбирают задачи по deficit round-robin с учетом веса и лимита
+// одновременных задач арендатора
+type fairScheduler struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	tenants  map[string]*tenantQueue
+	ring     []string
+	cursor   int
+	limits   func(tenant string) TenantLimits
+	depth    *queueDepth
+	changed  chan struct{}
+}
+
+func newFairScheduler(capacity int, limits func(string) TenantLimits, depth *queueDepth) *fairScheduler {
+	return &fairScheduler{
+		capacity: capacity,
+		tenants:  make(map[string]*tenantQueue),
+		limits:   limits,
+		depth:    depth,
+		changed:  make(chan struct{}),
+	}
+}
+
+// wake будит ожидающих, вызывается под mu
+func (s *fairScheduler) wake() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// tenant вызывается под mu
+func (s *fairScheduler) tenant(name string) *tenantQueue {
+	t, exists := s.tenants[name]
+	if !exists {
+		t = &tenantQueue{}
+		s.tenants[name] = t
+		s.ring = append(s.ring, name)
+	}
+	return t
+}
+
+// push добавляет задачу. checkCapacity - общий размер очереди,
+// checkTenant - лимит очереди арендатора. Повторы ставятся без проверок.
+func (s *fairScheduler) push(task *model.Task, checkCapacity, checkTenant bool) error {
+	name := tenantOf(task)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if checkCapacity && s.size >= s.capacity {
+		return errSchedulerFull
+	}
+	t := s.tenant(name)
+	if limit := s.limits(name).MaxQueued; checkTenant && limit > 0 && len(t.tasks) >= limit {
+		return &TenantLimitError{Tenant: name, Limit: "queued", RetryAfter: minRetryAfter}
+	}
+	t.tasks = append(t.tasks, task)
+	s.size++
+	s.depth.add(task.Queue, name, 1)
+	tenantQueued.Set(float64(len(t.tasks)), name)
+	s.wake()
+	return nil
+}
+
+// pop ждет задачу, доступную по лимитам; nil после закрытия stop
+func (s *fairScheduler) pop(stop <-chan struct{}) *model.Task {
+	for {
+		s.mu.Lock()
+		task := s.pick()
+		changed := s.changed
+		s.mu.Unlock()
+		if task != nil {
+			return task
+		}
+
+		select {
+		case <-changed:
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// pick - один шаг DRR: арендатор получает до Weight задач подряд,
+// затем ход переходит к следующему. Вызывается под mu.
+func (s *fairScheduler) pick() *model.Task {
+	for i := 0; i < len(s.ring); i++ {
+		name := s.ring[s.cursor]
+		t := s.tenants[name]
+		limits := s.limits(name)
+
+		eligible := len(t.tasks) > 0 &&
+			(limits.MaxConcurrency <= 0 || t.running < limits.MaxConcurrency)
+		if !eligible {
+			if len(t.tasks) == 0 {
+				t.deficit = 0
+			}
+			s.advance()
+			continue
+		}
+
+		if t.deficit <= 0 {
+			t.deficit += limits.Weight
+		}
+		task := t.tasks[0]
+		t.tasks[0] = nil
+		t.tasks = t.tasks[1:]
+		t.deficit--
+		t.running++
+		s.size--
+		s.depth.add(task.Queue, name, -1)
+		if t.deficit <= 0 || len(t.tasks) == 0 {
+			s.advance()
+		}
+
+		tenantQueued.Set(float64(len(t.tasks)), name)
+		tenantRunning.Set(float64(t.running), name)
+		return task
+	}
+	return nil
+}
+
+func (s *fairScheduler) advance() {
+	s.cursor = (s.cursor + 1) % len(s.ring)
+}
+
+// acquire занимает слот арендатора для удаленной аренды, false - лимит
+// одновременных задач исчерпан. Освобождается через done.
+func (s *fairScheduler) acquire(task *model.Task) bool {
+	name := tenantOf(task)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.tenant(name)
+	if limit := s.limits(name).MaxConcurrency; limit > 0 && t.running >= limit {
+		return false
+	}
+	t.running++
+	tenantRunning.Set(float64(t.running), name)
+	return true
+}
+
+// done освобождает слот арендатора после попытки
+func (s *fairScheduler) done(task *model.Task) {
+	name := tenantOf(task)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, exists := s.tenants[name]; exists && t.running > 0 {
+		t.running--
+		tenantRunning.Set(float64(t.running), name)
+		s.wake()
+	}
+}
+
+// remove убирает задачу из очереди (отмена)
+func (s *fairScheduler) remove(task *model.Task) bool {
+	name := tenantOf(task)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, exists := s.tenants[name]
+	if !exists {
+		return false
+	}
+	for i, queued := range t.tasks {
+		if queued == task {
+			t.tasks = append(t.tasks[:i], t.tasks[i+1:]...)
+			s.size--
+			s.depth.add(task.Queue, name, -1)
+			tenantQueued.Set(float64(len(t.tasks)), name)
+			s.wake()
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fairScheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// waitChange возвращает канал, закрываемый при любом изменении очереди
+func (s *fairScheduler) waitChange() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
@@ -0,0 +1,222 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"TaskQueue/internal/model"
+)
+
+var errSpillFull = errors.New("spill buffer is full")
+
+// spillBuffer - FIFO в файле для задач, не поместившихся в очередь.
+// Файл обнуляется, когда все записи прочитаны.
+type spillBuffer struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	writer   *os.File
+	reader   *bufio.Reader
+	file     *os.File
+	size     int64
+	offset   int64
+	count    int
+	maxBytes int64
+	// Прочитанная, но еще не переданная в очередь запись
+	head     string
+	headSize int64
+	closed   bool
+}
+
+type spillRecord struct {
+	ID string `json:"id"`
+	// Снимок для диагностики; задача берется из репозитория
+	Task model.TaskSnapshot `json:"task"`
+}
+
+func newSpillBuffer(dir string, maxBytes int64) (*spillBuffer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "overflow.jsonl")
+	writer, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	s := &spillBuffer{
+		writer:   writer,
+		file:     file,
+		reader:   bufio.NewReader(file),
+		maxBytes: maxBytes,
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s, nil
+}
+
+func (s *spillBuffer) push(task *model.Task) error {
+	data, err := json.Marshal(spillRecord{ID: task.ID, Task: task.Snapshot()})
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrQueueFull
+	}
+	if s.maxBytes > 0 && s.size-s.offset+int64(len(data)) > s.maxBytes {
+		return errSpillFull
+	}
+	if _, err := s.writer.Write(data); err != nil {
+		return err
+	}
+	s.size += int64(len(data))
+	s.count++
+	s.cond.Signal()
+	return nil
+}
+
+// peek ждет следующую запись и возвращает ее, не удаляя из буфера;
+// false после close
+func (s *spillBuffer) peek() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		for s.count == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			return "", false
+		}
+		if s.head != "" {
+			return s.head, true
+		}
+
+		line, err := s.reader.ReadBytes('\n')
+		s.headSize = int64(len(line))
+		var record spillRecord
+		if err == nil && json.Unmarshal(line, &record) == nil && record.ID != "" {
+			s.head = record.ID
+			return s.head, true
+		}
+		s.advanceLocked()
+	}
+}
+
+// advance удаляет запись, возвращенную peek
+func (s *spillBuffer) advance() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.count == 0 {
+		return
+	}
+	s.advanceLocked()
+}
+
+func (s *spillBuffer) advanceLocked() {
+	s.offset += s.headSize
+	s.head = ""
+	s.headSize = 0
+	s.count--
+	if s.count == 0 {
+		s.reset()
+	}
+}
+
+// reset обнуляет файл, вызывается под mu
+func (s *spillBuffer) reset() {
+	s.writer.Truncate(0)
+	s.writer.Seek(0, 0)
+	s.file.Seek(0, 0)
+	s.reader.Reset(s.file)
+	s.size = 0
+	s.offset = 0
+}
+
+func (s *spillBuffer) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+func (s *spillBuffer) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.cond.Broadcast()
+	s.writer.Close()
+	s.file.Close()
+}
+
+func (wp *workerPool) spillTask(task *model.Task) error {
+	if err := wp.spill.push(task); err != nil {
+		if errors.Is(err, errSpillFull) {
+			return wp.queueFull()
+		}
+		return err
+	}
+	tasksSpilled.Inc(task.Queue)
+	return nil
+}
+
+// feedSpill возвращает задачи из spill в очередь по мере освобождения места.
+// Запись удаляется из spill только после того, как задача принята очередью.
+func (wp *workerPool) feedSpill() {
+	defer wp.wg.Done()
+
+	for {
+		id, ok := wp.spill.peek()
+		if !ok {
+			return
+		}
+		if task, exists := wp.taskRepo.GetByID(id); exists && task.GetStatus() == "queued" {
+			if !wp.feedTask(task) {
+				return
+			}
+		}
+		wp.spill.advance()
+	}
+}
+
+// feedTask ждет места для задачи из spill; false при остановке пула.
+// Лимиты арендатора уже проверены при приеме.
+func (wp *workerPool) feedTask(task *model.Task) bool {
+	if wp.isRemote(task) {
+		ticker := time.NewTicker(pendingPollInterval)
+		defer ticker.Stop()
+		for !wp.feedPending(task) {
+			select {
+			case <-ticker.C:
+			case <-wp.shutdown:
+				return false
+			}
+		}
+		return true
+	}
+	for {
+		changed := wp.scheduler.waitChange()
+		if wp.scheduler.push(task, true, false) == nil {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-wp.shutdown:
+			return false
+		}
+	}
+}
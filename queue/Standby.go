@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"log/slog"
+	"sort"
+
+	"TaskQueue/internal/model"
+)
+
+// ErrPoolSuspended - пул в резерве (узел кластера не лидер) и не принимает задачи
+var ErrPoolSuspended = &QueueError{Message: "worker pool is on standby"}
+
+// Suspend переводит пул в резерв: локальная очередь и отложенные задачи
+// отбрасываются, выполняемые попытки отменяются и их результат игнорируется.
+// Состояние задач остается в хранилище, новый лидер продолжит с него.
+func (wp *workerPool) Suspend() {
+	if wp.suspended.Swap(true) {
+		return
+	}
+	wp.suspensions.Add(1)
+
+	wp.leaseMu.Lock()
+	dropped := wp.scheduler.clear() + len(wp.pending)
+	for _, task := range wp.pending {
+		wp.depth.add(task.Queue, tenantOf(task), -1)
+	}
+	wp.pending = nil
+	for _, task := range wp.leased {
+		wp.dropLease(task)
+	}
+	wp.leaseMu.Unlock()
+
+	dropped += wp.breakers.clearHeld()
+	slog.Info("Worker pool suspended", "dropped", dropped)
+}
+
+// Resume выводит пул из резерва и принимает незавершенные задачи хранилища:
+// queued ставятся в очередь, прерванные running обрабатываются как после аварии
+func (wp *workerPool) Resume() {
+	if !wp.suspended.Swap(false) {
+		return
+	}
+
+	var queued []*model.Task
+	for _, task := range wp.taskRepo.GetAll() {
+		if task.GetStatus() == "queued" {
+			queued = append(queued, task)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		return queued[i].GetEnqueuedAt().Before(queued[j].GetEnqueuedAt())
+	})
+	for _, task := range queued {
+		wp.requeue(task, task.GetGeneration())
+	}
+	wp.recoverRunning()
+	slog.Info("Worker pool resumed", "restored", len(queued))
+}
+
+// clear убирает из очереди все задачи, слоты выполняемых остаются
+func (s *fairScheduler) clear() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := s.size
+	for name, t := range s.tenants {
+		for _, task := range t.tasks {
+			s.depth.add(task.Queue, name, -1)
+		}
+		t.tasks = nil
+		t.deficit = 0
+		tenantQueued.Set(0, name)
+	}
+	s.size = 0
+	s.wake()
+	return dropped
+}
+
+// clearHeld отбрасывает задачи, отложенные breakers
+func (r *breakerRegistry) clearHeld() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dropped := 0
+	for key, b := range r.breakers {
+		dropped += len(b.takeHeld(key))
+		b.probes = nil
+	}
+	return dropped
+}
@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"TaskQueue/internal/model"
+)
+
+// TenantLimits - вес в планировщике и ограничения арендатора. Нулевой лимит - без ограничения.
+type TenantLimits struct {
+	// Доля воркеров при конкуренции: задач подряд за один круг планировщика
+	Weight int
+	// Одновременно выполняемые задачи, отдельно для локальных и удаленных воркеров
+	MaxConcurrency int
+	// Задачи в очереди
+	MaxQueued int
+	// Скорость постановки в очередь, задач в секунду, и допустимый всплеск
+	Rate  float64
+	Burst int
+}
+
+func DefaultTenantLimits() TenantLimits {
+	return TenantLimits{Weight: 1}
+}
+
+// ParseTenantLimits разбирает "weight=3,concurrency=2,queued=100,rate=50,burst=100"
+func ParseTenantLimits(spec string) (TenantLimits, error) {
+	limits := DefaultTenantLimits()
+	for _, field := range strings.Split(spec, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		name, value, found := strings.Cut(field, "=")
+		if !found {
+			return limits, fmt.Errorf("invalid tenant limit %q", field)
+		}
+
+		var err error
+		switch name {
+		case "weight":
+			limits.Weight, err = strconv.Atoi(value)
+		case "concurrency":
+			limits.MaxConcurrency, err = strconv.Atoi(value)
+		case "queued":
+			limits.MaxQueued, err = strconv.Atoi(value)
+		case "rate":
+			limits.Rate, err = strconv.ParseFloat(value, 64)
+		case "burst":
+			limits.Burst, err = strconv.Atoi(value)
+		default:
+			return limits, fmt.Errorf("unknown tenant limit %q", name)
+		}
+		if err != nil {
+			return limits, fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	if limits.Weight <= 0 {
+		return limits, fmt.Errorf("weight must be positive")
+	}
+	if limits.MaxConcurrency < 0 || limits.MaxQueued < 0 || limits.Rate < 0 || limits.Burst < 0 {
+		return limits, fmt.Errorf("limits must not be negative")
+	}
+	return limits, nil
+}
+
+// TenantLimitError - арендатор превысил свой лимит
+type TenantLimitError struct {
+	Tenant     string
+	Limit      string
+	RetryAfter time.Duration
+}
+
+func (e *TenantLimitError) Error() string {
+	return fmt.Sprintf("tenant %s exceeded %s limit, retry after %s", e.Tenant, e.Limit, e.RetryAfter)
+}
+
+func tenantOf(task *model.Task) string {
+	if task.Tenant == "" {
+		return model.DefaultTenant
+	}
+	return task.Tenant
+}
+
+func (wp *workerPool) tenantLimits(tenant string) TenantLimits {
+	if limits, exists := wp.tenants[tenant]; exists {
+		return limits
+	}
+	return wp.defaultTenantLimits
+}
+
+// rateLimiter - token bucket на каждого арендатора
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow списывает токен или возвращает время до появления следующего
+func (l *rateLimiter) allow(tenant string, limits TenantLimits) (bool, time.Duration) {
+	if limits.Rate <= 0 {
+		return true, 0
+	}
+	burst := float64(limits.Burst)
+	if burst < 1 {
+		burst = math.Max(1, limits.Rate)
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	bucket, exists := l.buckets[tenant]
+	if !exists {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		l.buckets[tenant] = bucket
+	}
+
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limits.Rate)
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / limits.Rate * float64(time.Second))
+	return false, wait
+}
+
+// checkTenantRate применяет лимит скорости арендатора к новой задаче
+func (wp *workerPool) checkTenantRate(task *model.Task) error {
+	tenant := tenantOf(task)
+	allowed, wait := wp.rateLimiter.allow(tenant, wp.tenantLimits(tenant))
+	if allowed {
+		return nil
+	}
+	if wait < minRetryAfter {
+		wait = minRetryAfter
+	}
+	return &TenantLimitError{Tenant: tenant, Limit: "rate", RetryAfter: wait.Round(time.Second)}
+}
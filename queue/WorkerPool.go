@@ -1,45 +1,191 @@
 package queue
 
 import (
-	"log"
-	"math/rand"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"TaskQueue/internal/events"
 	"TaskQueue/internal/model"
 	"TaskQueue/internal/repository"
+	"TaskQueue/internal/tasklog"
+	"TaskQueue/internal/tracing"
 )
 
 type WorkerPool interface {
 	Enqueue(task *model.Task) error
+	// EnqueueContext учитывает режим переполнения; в режиме block ждет до отмены ctx
+	EnqueueContext(ctx context.Context, task *model.Task) error
+	RegisterHandler(taskType string, handler Handler)
+	// Use и UseFor добавляют middleware для всех задач и для одного типа
+	Use(middlewares ...Middleware)
+	UseFor(taskType string, middlewares ...Middleware)
+
+	// Протокол удаленных воркеров
+	Lease(req LeaseRequest) []LeasedTask
+	Heartbeat(taskID string, holder LeaseHolder, timeout time.Duration) (time.Time, error)
+	Progress(taskID string, holder LeaseHolder, update ProgressUpdate) (time.Time, error)
+	Complete(taskID string, holder LeaseHolder) error
+	Fail(taskID string, holder LeaseHolder, reason string) error
+
+	// Subscribe подписывает на события статуса и прогресса задач
+	Subscribe(buffer int) (<-chan events.Event, func())
+
+	// Операции оператора
+	Cancel(task *model.Task) error
+	Replay(task *model.Task) error
+	// Release возвращает задачу из карантина в очередь
+	Release(task *model.Task) error
+	Breakers() []BreakerStatus
+	// Restore ставит в очередь импортированную задачу; задача в бэкоффе
+	// ждет до retry_at
+	Restore(task *model.Task)
+	// Remove убирает ожидающую задачу из очереди без отмены
+	Remove(task *model.Task) bool
+	// Suspend и Resume переводят пул в резерв и обратно (кластерный режим)
+	Suspend()
+	Resume()
+	ResetBreaker(key string) error
+	Stats() PoolStats
+	// QueueDepth - ожидающие задачи очереди без обхода хранилища
+	QueueDepth(queue string, allowed func(queue, tenant string) bool) int
+	// TaskLogs возвращает буфер логов попытки; attempt <= 0 - последняя
+	TaskLogs(taskID string, attempt int) (*tasklog.Buffer, int, bool)
+
 	Start()
 	Shutdown()
 }
 
 type workerPool struct {
-	tasks    chan *model.Task
-	workers  int
-	shutdown chan struct{}
-	wg       sync.WaitGroup
-	taskRepo repository.TaskRepository
+	scheduler *fairScheduler
+	depth     *queueDepth
+	queueSize int
+	workers   int
+	shutdown  chan struct{}
+	wg        sync.WaitGroup
+	taskRepo  repository.TaskRepository
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	handlersMu     sync.RWMutex
+	handlers       map[string]Handler
+	defaultHandler Handler
+	// Цепочка попытки: builtins, затем middlewares, затем typeMiddlewares
+	builtins        []Middleware
+	middlewares     []Middleware
+	typeMiddlewares map[string][]Middleware
+
+	defaultRetryPolicy model.RetryPolicy
+	queueRetryPolicies map[string]model.RetryPolicy
+
+	// Задачи для удаленных воркеров
+	remoteTypes  map[string]bool
+	leaseMu      sync.Mutex
+	pending      []*model.Task
+	pendingLimit int
+	leased       map[string]*model.Task
+	leaseCancels map[string]context.CancelFunc
+
+	visibilityTimeout time.Duration
+	reapInterval      time.Duration
+
+	events   *events.Broker
+	taskLogs *tasklog.Store
+
+	overflowMode   OverflowMode
+	maxEnqueueWait time.Duration
+	spillDir       string
+	spillMaxBytes  int64
+	spill          *spillBuffer
+	drain          drainMeter
+
+	defaultTenantLimits TenantLimits
+	tenants             map[string]TenantLimits
+	rateLimiter         rateLimiter
+
+	// Порог паник и аварий для карантина, 0 - без карантина
+	poisonThreshold int
+
+	breakers *breakerRegistry
+
+	// В резерве пул не выполняет и не выдает задачи. suspensions отличает
+	// таймеры повторов, запланированные до перехода в резерв.
+	suspended   atomic.Bool
+	suspensions atomic.Uint64
+
+	busyWorkers    atomic.Int32
+	completedTotal atomic.Uint64
+	failedTotal    atomic.Uint64
 }
 
-func NewWorkerPool(workers, queueSize int, taskRepo repository.TaskRepository) WorkerPool {
-	return &workerPool{
-		tasks:    make(chan *model.Task, queueSize),
-		workers:  workers,
-		shutdown: make(chan struct{}),
-		taskRepo: taskRepo,
+func NewWorkerPool(workers, queueSize int, taskRepo repository.TaskRepository, opts ...Option) WorkerPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	wp := &workerPool{
+		queueSize:           queueSize,
+		workers:             workers,
+		shutdown:            make(chan struct{}),
+		taskRepo:            taskRepo,
+		ctx:                 ctx,
+		cancel:              cancel,
+		handlers:            make(map[string]Handler),
+		defaultHandler:      SimulatedHandler,
+		typeMiddlewares:     make(map[string][]Middleware),
+		defaultRetryPolicy:  model.DefaultRetryPolicy(),
+		remoteTypes:         make(map[string]bool),
+		pendingLimit:        queueSize,
+		leased:              make(map[string]*model.Task),
+		leaseCancels:        make(map[string]context.CancelFunc),
+		visibilityTimeout:   10 * time.Minute,
+		reapInterval:        time.Second,
+		events:              events.NewBroker(),
+		taskLogs:            tasklog.NewStore(tasklog.DefaultMaxLines, tasklog.DefaultMaxTasks),
+		defaultTenantLimits: DefaultTenantLimits(),
+		tenants:             make(map[string]TenantLimits),
+		overflowMode:        OverflowReject,
+		maxEnqueueWait:      30 * time.Second,
+		poisonThreshold:     DefaultPoisonThreshold,
+		breakers:            newBreakerRegistry(),
 	}
+	wp.builtins = wp.builtinMiddlewares()
+	for _, opt := range opts {
+		opt(wp)
+	}
+	wp.depth = newQueueDepth()
+	wp.scheduler = newFairScheduler(queueSize, wp.tenantLimits, wp.depth)
+	if wp.overflowMode == OverflowSpill {
+		spill, err := newSpillBuffer(wp.spillDir, wp.spillMaxBytes)
+		if err != nil {
+			slog.Error("Spill buffer unavailable, overflowing tasks will be rejected", "dir", wp.spillDir, "error", err)
+		} else {
+			wp.spill = spill
+		}
+	}
+	wp.registerGauges()
+	return wp
 }
 
-func (wp *workerPool) Enqueue(task *model.Task) error {
-	select {
-	case wp.tasks <- task:
-		return nil
-	default:
-		return ErrQueueFull
+func (wp *workerPool) retryPolicyFor(task *model.Task) model.RetryPolicy {
+	if task.RetryPolicy != nil {
+		return *task.RetryPolicy
+	}
+	if policy, exists := wp.queueRetryPolicies[task.Queue]; exists {
+		return policy
 	}
+	return wp.defaultRetryPolicy
+}
+
+func (wp *workerPool) RegisterHandler(taskType string, handler Handler) {
+	wp.handlersMu.Lock()
+	defer wp.handlersMu.Unlock()
+	wp.handlers[taskType] = handler
 }
 
 func (wp *workerPool) Start() {
@@ -47,69 +193,218 @@ func (wp *workerPool) Start() {
 		wp.wg.Add(1)
 		go wp.worker(i)
 	}
+
+	if !wp.suspended.Load() {
+		wp.recoverRunning()
+	}
+	wp.wg.Add(1)
+	go wp.reaper()
+
+	if wp.spill != nil {
+		wp.wg.Add(1)
+		go wp.feedSpill()
+	}
 }
 
 func (wp *workerPool) worker(id int) {
 	defer wp.wg.Done()
 
 	for {
-		select {
-		case <-wp.shutdown:
+		task := wp.scheduler.pop(wp.shutdown)
+		if task == nil {
 			return
-		case task := <-wp.tasks:
-			wp.processTask(task, id)
 		}
+		// Задача из spill или таймера, успевшая попасть в очередь после Suspend
+		if wp.suspended.Load() {
+			wp.scheduler.done(task)
+			continue
+		}
+		if wp.holdForBreaker(task) {
+			wp.scheduler.done(task)
+			continue
+		}
+		wp.drain.record()
+		wp.processTask(task, id)
+		wp.scheduler.done(task)
 	}
 }
 
+func taskSpanAttributes(task *model.Task) trace.SpanStartOption {
+	return trace.WithAttributes(
+		attribute.String("task.id", task.ID),
+		attribute.String("task.type", task.Type),
+		attribute.String("task.queue", task.Queue),
+		attribute.Int("task.attempt", task.GetRetries()+1),
+	)
+}
+
 func (wp *workerPool) processTask(task *model.Task, workerID int) {
-	// Обновляем статус на "running"
-	task.SetStatus("running")
+	logger, logBuffer := wp.attemptLogger(task, workerID)
+	defer logBuffer.Close()
+	logger.Debug("Task started")
+
+	taskCtx := tracing.ContextFromTask(wp.ctx, task)
+	_, waitSpan := tracing.Tracer().Start(taskCtx, "queue.wait",
+		trace.WithTimestamp(task.GetEnqueuedAt()), taskSpanAttributes(task))
+	waitSpan.End()
+
+	owner := fmt.Sprintf("worker-%d", workerID)
+	attemptCtx, cancelAttempt := context.WithCancel(taskCtx)
+	defer cancelAttempt()
+	if !wp.acquireLocalLease(task, owner, cancelAttempt) {
+		wp.releaseProbe(task)
+		logger.Debug("Task cancelled before start")
+		return
+	}
 	wp.taskRepo.Update(task)
+	wp.publishStatus(task)
 
-	processingTime := time.Duration(100+rand.Intn(400)) * time.Millisecond
-	time.Sleep(processingTime)
+	attemptCtx = withAttempt(attemptCtx, &attempt{workerID: workerID, owner: owner, logger: logger})
+	err := wp.chainFor(task.Type)(attemptCtx, task)
 
-	// 20% вероятность ошибки
-	if rand.Float64() < 0.2 {
-		retries := task.IncrementRetries()
+	// Если reaper уже забрал задачу, результат попытки устарел
+	_, generation, leaseErr := wp.releaseLease(task.ID, LeaseHolder{WorkerID: owner})
+	if leaseErr != nil {
+		wp.releaseProbe(task)
+		logger.Warn("Attempt result discarded", "reason", leaseErr, "error", err)
+		return
+	}
 
-		if retries >= task.MaxRetries {
-			task.SetStatus("failed")
-			wp.taskRepo.Update(task)
-			log.Printf("Worker %d: Task %s failed after %d retries", workerID, task.ID, retries)
-		} else {
-			task.SetStatus("queued")
-			wp.taskRepo.Update(task)
-			//бэкофф
-			backoff := time.Duration(1<<uint(retries)) * time.Second
-			jitter := time.Duration(rand.Int63n(int64(backoff / 2)))
-			retryDelay := backoff + jitter
-
-			log.Printf("Worker %d: Task %s failed, retry %d/%d in %v",
-				workerID, task.ID, retries, task.MaxRetries, retryDelay)
-
-			// Перезапускаем задачу после задержки
-			time.AfterFunc(retryDelay, func() {
-				select {
-				case wp.tasks <- task:
-				case <-wp.shutdown:
-				}
-			})
-		}
+	if err != nil {
+		wp.handleFailure(taskCtx, task, generation, err, logger)
 	} else {
-		task.SetStatus("done")
-		wp.taskRepo.Update(task)
-		log.Printf("Worker %d: Task %s completed successfully", workerID, task.ID)
+		wp.complete(task, logger)
+	}
+}
+
+func (wp *workerPool) complete(task *model.Task, logger *slog.Logger) {
+	wp.recordBreaker(task, false)
+	wp.setStatus(task, "done")
+	tasksCompleted.Inc(task.Queue, task.Type)
+	tenantCompleted.Inc(tenantOf(task))
+	wp.completedTotal.Add(1)
+	logger.Info("Task completed")
+}
+
+// handleFailure применяет политику повторов после неудачной попытки.
+// generation - поколение задачи на момент снятия аренды. Возвращает false,
+// если задача окончательно провалена или повтор отброшен.
+func (wp *workerPool) handleFailure(taskCtx context.Context, task *model.Task, generation uint64, err error, logger *slog.Logger) bool {
+	retries := task.IncrementRetries()
+	recordAttemptError(task, retries, err, logger)
+	// Постоянная ошибка и паника - проблема самой задачи, а не ресурса:
+	// breaker ее не учитывает, но проба освобождается
+	if wp.poisoned(task, err) {
+		wp.releaseProbe(task)
+		wp.quarantine(task, logger)
+		return false
+	}
+
+	var permanentErr *PermanentError
+	if errors.As(err, &permanentErr) {
+		wp.releaseProbe(task)
+		wp.fail(task)
+		logger.Error("Task failed permanently", "retries", retries, "error", err)
+		return false
+	}
+	wp.recordBreaker(task, true)
+
+	policy := wp.retryPolicyFor(task)
+	retryDelay := policy.NextDelay(retries, task.GetLastRetryDelay())
+	var retryAfter *RetryAfterError
+	if errors.As(err, &retryAfter) && retryAfter.Delay > 0 {
+		retryDelay = retryAfter.Delay
+	}
+	elapsedExceeded := policy.MaxElapsed > 0 &&
+		time.Since(task.CreatedAt)+retryDelay > time.Duration(policy.MaxElapsed)
+
+	if retries >= task.MaxRetries || elapsedExceeded {
+		wp.fail(task)
+		logger.Error("Task failed, retries exhausted", "retries", retries,
+			"elapsed_exceeded", elapsedExceeded, "error", err)
+		return false
+	}
+
+	// Задача могла быть отменена и запущена заново, пока попытка завершалась
+	wp.leaseMu.Lock()
+	if task.GetGeneration() != generation {
+		wp.leaseMu.Unlock()
+		logger.Warn("Retry discarded, task was cancelled", "error", err)
+		return false
 	}
+	task.SetLastRetryDelay(retryDelay)
+	task.SetRetryAt(time.Now().Add(retryDelay))
+	task.SetStatus("queued")
+	wp.leaseMu.Unlock()
+	wp.taskRepo.Update(task)
+	wp.publishStatus(task)
+	taskRetries.Inc(task.Queue, task.Type)
+
+	logger.Warn("Task failed, scheduling retry", "retries", retries, "max_retries", task.MaxRetries,
+		"retry_strategy", policy.Strategy, "retry_delay", retryDelay, "error", err)
+
+	_, backoffSpan := tracing.Tracer().Start(taskCtx, "task.retry_backoff",
+		taskSpanAttributes(task), trace.WithAttributes(attribute.String("retry.delay", retryDelay.String())))
+
+	// Перезапускаем задачу после задержки
+	wp.requeueAfter(task, generation, retryDelay, func() { backoffSpan.End() })
+	return true
+}
+
+// requeueAfter возвращает задачу в очередь после задержки, если пул
+// за это время не уходил в резерв
+func (wp *workerPool) requeueAfter(task *model.Task, generation uint64, delay time.Duration, done func()) {
+	suspensions := wp.suspensions.Load()
+	time.AfterFunc(delay, func() {
+		if done != nil {
+			done()
+		}
+		if wp.suspensions.Load() == suspensions {
+			wp.requeue(task, generation)
+		}
+	})
+}
+
+func (wp *workerPool) fail(task *model.Task) {
+	wp.setStatus(task, "failed")
+	tasksFailed.Inc(task.Queue, task.Type)
+	tenantFailed.Inc(tenantOf(task))
+	wp.failedTotal.Add(1)
+}
+
+// requeue возвращает задачу в очередь после бэкоффа. В отличие от Enqueue
+// не проверяет лимиты, чтобы не потерять задачу. Задача, которую с тех пор
+// отменили или запустили заново (другое поколение), не возвращается.
+func (wp *workerPool) requeue(task *model.Task, generation uint64) {
+	wp.leaseMu.Lock()
+	defer wp.leaseMu.Unlock()
+	if task.GetGeneration() != generation || task.GetStatus() == "cancelled" || wp.suspended.Load() {
+		return
+	}
+	task.MarkEnqueued()
+	if wp.isRemote(task) {
+		wp.pending = append(wp.pending, task)
+		wp.depth.add(task.Queue, tenantOf(task), 1)
+		return
+	}
+	wp.scheduler.push(task, false, false)
 }
 
 func (wp *workerPool) Shutdown() {
 	close(wp.shutdown)
+	if wp.spill != nil {
+		wp.spill.close()
+	}
 	wp.wg.Wait()
+	wp.cancel()
 }
 
-var ErrQueueFull = &QueueError{Message: "queue is full"}
+var (
+	ErrQueueFull     = &QueueError{Message: "queue is full"}
+	ErrTaskNotLeased = &QueueError{Message: "task is not leased"}
+	ErrLeaseNotHeld  = &QueueError{Message: "lease is held by another worker"}
+	ErrLeaseExpired  = &QueueError{Message: "lease expired"}
+)
 
 type QueueError struct {
 	Message string
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"TaskQueue/internal/cluster"
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/service"
+	"TaskQueue/queue"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type clusterNode struct {
+	id       string
+	cluster  *cluster.Cluster
+	service  service.QueueService
+	server   *httptest.Server
+	executed atomic.Int32
+	stopped  bool
+}
+
+func (n *clusterNode) stop() {
+	if n.stopped {
+		return
+	}
+	n.stopped = true
+	n.server.Close()
+	n.cluster.Stop()
+	n.service.Shutdown()
+}
+
+func startTaskCluster(t *testing.T, size int, forward cluster.ForwardMode) []*clusterNode {
+	nodes := make([]*clusterNode, size)
+	handlers := make([]http.Handler, size)
+	peers := make(map[string]string)
+	for i := range nodes {
+		i := i
+		nodes[i] = &clusterNode{
+			id: fmt.Sprintf("n%d", i+1),
+			server: httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlers[i].ServeHTTP(w, r)
+			})),
+		}
+		peers[nodes[i].id] = "http://" + nodes[i].server.Listener.Addr().String()
+	}
+
+	for i, node := range nodes {
+		c, err := cluster.New(cluster.Config{
+			NodeID:            node.id,
+			Peers:             peers,
+			Secret:            "cluster-secret",
+			Forward:           forward,
+			ElectionTimeout:   150 * time.Millisecond,
+			HeartbeatInterval: 30 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.cluster = c
+		node.service = service.NewQueueService(c.Repository(), 1, 10,
+			queue.WithStandby(), queue.WithRemoteTypes("remote"))
+		node.service.RegisterHandler("work", func(ctx context.Context, task *model.Task) error {
+			node.executed.Add(1)
+			return nil
+		})
+
+		httpController := controller.NewHTTPController(node.service)
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /enqueue", httpController.EnqueueHandler)
+		mux.HandleFunc("GET /tasks/{id}", httpController.TaskHandler)
+		c.Register(mux)
+		handlers[i] = c.Middleware(mux)
+	}
+
+	for _, node := range nodes {
+		node.server.Start()
+		node.service.StartWorkers()
+		node.cluster.Start(node.service)
+	}
+	t.Cleanup(func() {
+		for _, node := range nodes {
+			node.stop()
+		}
+	})
+	return nodes
+}
+
+// waitForWritableLeader ждет узел, который стал лидером и применил лог
+func waitForWritableLeader(t *testing.T, nodes []*clusterNode) *clusterNode {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, node := range nodes {
+			if !node.stopped && node.cluster.Repository().IsLeader() {
+				return node
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Expected a writable cluster leader")
+	return nil
+}
+
+func followerOf(nodes []*clusterNode, leader *clusterNode) *clusterNode {
+	for _, node := range nodes {
+		if node != leader && !node.stopped {
+			return node
+		}
+	}
+	return nil
+}
+
+func waitForTaskStatus(t *testing.T, node *clusterNode, id, status string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(node.server.URL + "/tasks/" + id)
+		if err == nil {
+			var snapshot model.TaskSnapshot
+			json.NewDecoder(resp.Body).Decode(&snapshot)
+			resp.Body.Close()
+			if snapshot.Status == status {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Expected task %s to reach %s on %s", id, status, node.id)
+}
+
+func TestIntegration_ClusterRequiresSecret(t *testing.T) {
+	_, err := cluster.New(cluster.Config{
+		NodeID: "n1",
+		Peers:  map[string]string{"n1": "http://127.0.0.1:1"},
+	})
+	if err == nil {
+		t.Fatal("Expected cluster without secret to be rejected")
+	}
+}
+
+func TestIntegration_ClusterForwardsWritesToLeader(t *testing.T) {
+	nodes := startTaskCluster(t, 3, cluster.ForwardProxy)
+	leader := waitForWritableLeader(t, nodes)
+	follower := followerOf(nodes, leader)
+
+	resp := postJSON(t, follower.server.URL+"/enqueue", map[string]interface{}{
+		"id": "cluster-task", "type": "work", "payload": "data", "max_retries": 1,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202 from follower, got %d", resp.StatusCode)
+	}
+
+	// Чтение с ведомого обслуживается из реплики
+	for _, node := range nodes {
+		waitForTaskStatus(t, node, "cluster-task", "done")
+	}
+	for _, node := range nodes {
+		executed := node.executed.Load()
+		if node == leader && executed != 1 {
+			t.Errorf("Expected leader to execute the task once, got %d", executed)
+		}
+		if node != leader && executed != 0 {
+			t.Errorf("Expected follower %s not to execute tasks, got %d", node.id, executed)
+		}
+	}
+}
+
+func TestIntegration_ClusterRedirectsWrites(t *testing.T) {
+	nodes := startTaskCluster(t, 3, cluster.ForwardRedirect)
+	leader := waitForWritableLeader(t, nodes)
+	follower := followerOf(nodes, leader)
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Post(follower.server.URL+"/enqueue", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected 307 from follower, got %d", resp.StatusCode)
+	}
+	if location := resp.Header.Get("Location"); location != leader.server.URL+"/enqueue" {
+		t.Errorf("Expected redirect to leader, got %q", location)
+	}
+}
+
+func TestIntegration_ClusterFailover(t *testing.T) {
+	nodes := startTaskCluster(t, 3, cluster.ForwardProxy)
+	leader := waitForWritableLeader(t, nodes)
+
+	// Задача remote ждет удаленного воркера и остается в очереди лидера
+	resp := postJSON(t, followerOf(nodes, leader).server.URL+"/enqueue", map[string]interface{}{
+		"id": "survivor", "type": "remote", "payload": "data", "max_retries": 2,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", resp.StatusCode)
+	}
+	for _, node := range nodes {
+		waitForTaskStatus(t, node, "survivor", "queued")
+	}
+
+	leader.stop()
+	newLeader := waitForWritableLeader(t, nodes)
+
+	var leased []queue.LeasedTask
+	deadline := time.Now().Add(5 * time.Second)
+	for len(leased) == 0 && time.Now().Before(deadline) {
+		leased = newLeader.service.Lease(queue.LeaseRequest{WorkerID: "w1", Types: []string{"remote"}, Max: 1})
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(leased) != 1 || leased[0].ID != "survivor" {
+		t.Fatalf("Expected new leader to hand out the surviving task, got %+v", leased)
+	}
+	if err := newLeader.service.Complete("survivor", queue.LeaseHolder{WorkerID: "w1"}); err != nil {
+		t.Fatalf("Unexpected complete error: %v", err)
+	}
+	waitForTaskStatus(t, followerOf(nodes, newLeader), "survivor", "done")
+}
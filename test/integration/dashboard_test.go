@@ -0,0 +1,177 @@
+package main
+
+import (
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/service"
+	"TaskQueue/internal/ui"
+	"TaskQueue/queue"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDashboardServer(t *testing.T) (*httptest.Server, service.QueueService) {
+	repo := repository.NewInMemoryTaskRepository()
+	queueService := service.NewQueueService(repo, 1, 10, queue.WithRemoteTypes("remote"))
+	httpController := controller.NewHTTPController(queueService)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /enqueue", httpController.EnqueueHandler)
+	mux.HandleFunc("POST /lease", httpController.LeaseHandler)
+	mux.HandleFunc("POST /tasks/{id}/complete", httpController.CompleteHandler)
+	mux.HandleFunc("GET /tasks", httpController.ListTasksHandler)
+	mux.HandleFunc("GET /stats", httpController.StatsHandler)
+	mux.HandleFunc("POST /tasks/{id}/cancel", httpController.CancelHandler)
+	mux.HandleFunc("POST /tasks/{id}/replay", httpController.ReplayHandler)
+	mux.HandleFunc("POST /tasks/{id}/release", httpController.ReleaseHandler)
+	mux.Handle("GET /ui/", ui.Handler())
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		server.Close()
+		queueService.Shutdown()
+	})
+	return server, queueService
+}
+
+func enqueue(t *testing.T, server *httptest.Server, id, taskType string) {
+	resp := postJSON(t, server.URL+"/enqueue", map[string]interface{}{
+		"id": id, "type": taskType, "payload": "data", "max_retries": 1,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", resp.StatusCode)
+	}
+}
+
+func TestIntegration_CancelAndReplay(t *testing.T) {
+	server, queueService := newDashboardServer(t)
+	queueService.StartWorkers()
+	enqueue(t, server, "to-cancel", "remote")
+
+	resp := postJSON(t, server.URL+"/tasks/to-cancel/cancel", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 from cancel, got %d", resp.StatusCode)
+	}
+	if status, _ := queueService.GetTaskStatus("to-cancel"); status != "cancelled" {
+		t.Fatalf("Expected cancelled status, got %s", status)
+	}
+	if tasks := leaseTasks(t, server, "w1", "10s"); len(tasks) != 0 {
+		t.Fatalf("Expected cancelled task not to be leased, got %d", len(tasks))
+	}
+
+	resp = postJSON(t, server.URL+"/tasks/to-cancel/cancel", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409 for finished task, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, server.URL+"/tasks/to-cancel/replay", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 from replay, got %d", resp.StatusCode)
+	}
+	tasks := leaseTasks(t, server, "w1", "10s")
+	if len(tasks) != 1 || tasks[0]["attempt"] != float64(1) {
+		t.Fatalf("Expected replayed task to be leased as attempt 1, got %v", tasks)
+	}
+
+	resp = postJSON(t, server.URL+"/tasks/to-cancel/replay", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409 when replaying a running task, got %d", resp.StatusCode)
+	}
+}
+
+func TestIntegration_CancelRunningTask(t *testing.T) {
+	server, queueService := newDashboardServer(t)
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	queueService.RegisterHandler("blocking", func(ctx context.Context, task *model.Task) error {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+	queueService.StartWorkers()
+	enqueue(t, server, "running", "blocking")
+	<-started
+
+	resp := postJSON(t, server.URL+"/tasks/running/cancel", nil)
+	resp.Body.Close()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected running attempt to be cancelled")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if status, _ := queueService.GetTaskStatus("running"); status != "cancelled" {
+		t.Errorf("Expected cancelled status to stick, got %s", status)
+	}
+}
+
+func TestIntegration_DashboardAPI(t *testing.T) {
+	server, queueService := newDashboardServer(t)
+	queueService.StartWorkers()
+	enqueue(t, server, "search-a", "remote")
+	enqueue(t, server, "search-b", "remote")
+	enqueue(t, server, "other", "remote")
+
+	resp, err := http.Get(server.URL + "/tasks?q=search&status=queued")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Tasks []model.TaskSnapshot `json:"tasks"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list.Tasks) != 2 {
+		t.Errorf("Expected 2 matching tasks, got %d", len(list.Tasks))
+	}
+
+	resp, _ = http.Get(server.URL + "/stats")
+	var stats service.Stats
+	json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if stats.Workers != 1 || stats.Queued != 3 || stats.Queues[model.DefaultQueue]["queued"] != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	resp, _ = http.Get(server.URL + "/ui/")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "app.js") {
+		t.Errorf("Expected dashboard page, got %d", resp.StatusCode)
+	}
+	if strings.Contains(string(body), "https://") {
+		t.Error("Dashboard must not load external resources")
+	}
+}
+
+func TestIntegration_ReleaseRequiresQuarantine(t *testing.T) {
+	server, queueService := newDashboardServer(t)
+	queueService.StartWorkers()
+	enqueue(t, server, "not-poison", "remote")
+
+	resp := postJSON(t, server.URL+"/tasks/not-poison/release", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409 for queued task, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, server.URL+"/tasks/missing/release", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown task, got %d", resp.StatusCode)
+	}
+}
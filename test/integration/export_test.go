@@ -0,0 +1,204 @@
+package main
+
+import (
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/service"
+	"TaskQueue/queue"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newAdminServer(t *testing.T, opts ...queue.Option) (*httptest.Server, service.QueueService) {
+	repo := repository.NewInMemoryTaskRepository()
+	queueService := service.NewQueueService(repo, 1, 10, opts...)
+	httpController := controller.NewHTTPController(queueService)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /enqueue", httpController.EnqueueHandler)
+	mux.HandleFunc("GET /admin/export", httpController.ExportHandler)
+	mux.HandleFunc("POST /admin/import", httpController.ImportHandler)
+	mux.HandleFunc("GET /admin/schemas", httpController.SchemasHandler)
+	mux.HandleFunc("GET /admin/schemas/{type}", httpController.GetSchemaHandler)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		server.Close()
+		queueService.Shutdown()
+	})
+	return server, queueService
+}
+
+func exportTasks(t *testing.T, server *httptest.Server, query string) []byte {
+	resp, err := http.Get(server.URL + "/admin/export" + query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 from export, got %d", resp.StatusCode)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return data
+}
+
+func importTasks(t *testing.T, server *httptest.Server, mode string, data []byte) (int, service.ImportResult) {
+	resp, err := http.Post(server.URL+"/admin/import?mode="+mode, "application/x-ndjson", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result service.ImportResult
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestIntegration_ExportImport(t *testing.T) {
+	// Задачи типа remote ждут удаленного воркера и остаются в очереди
+	source, sourceService := newAdminServer(t, queue.WithRemoteTypes("remote"))
+	sourceService.RegisterHandler("flaky", func(ctx context.Context, task *model.Task) error {
+		return errors.New("broken")
+	})
+	sourceService.RegisterHandler("ok", func(ctx context.Context, task *model.Task) error {
+		return nil
+	})
+	sourceService.StartWorkers()
+
+	for _, spec := range []map[string]interface{}{
+		{"id": "done-task", "type": "ok", "payload": "a", "max_retries": 1},
+		{"id": "failed-task", "type": "flaky", "payload": "b", "max_retries": 1},
+		{"id": "queued-task", "type": "remote", "payload": "c", "max_retries": 2},
+	} {
+		resp := postJSON(t, source.URL+"/enqueue", spec)
+		resp.Body.Close()
+	}
+	for _, id := range []string{"done-task", "failed-task"} {
+		task, _ := sourceService.GetTask(id)
+		deadline := time.Now().Add(2 * time.Second)
+		for !model.IsTerminalStatus(task.GetStatus()) && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	data := exportTasks(t, source, "")
+	var exported []model.TaskSnapshot
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var snapshot model.TaskSnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			t.Fatalf("Invalid JSONL line %q: %v", scanner.Text(), err)
+		}
+		exported = append(exported, snapshot)
+	}
+	if len(exported) != 3 {
+		t.Fatalf("Expected 3 exported tasks, got %d", len(exported))
+	}
+	if exported[1].ID != "failed-task" || exported[1].Status != "failed" || len(exported[1].AttemptErrors) != 1 {
+		t.Errorf("Expected failed task with attempt error in creation order, got %+v", exported[1])
+	}
+
+	if filtered := exportTasks(t, source, "?status=failed"); bytes.Count(filtered, []byte("\n")) != 1 {
+		t.Errorf("Expected one failed task in filtered export, got %q", filtered)
+	}
+
+	target, targetService := newAdminServer(t)
+	processed := make(chan string, 1)
+	targetService.RegisterHandler("remote", func(ctx context.Context, task *model.Task) error {
+		processed <- task.ID
+		return nil
+	})
+	targetService.StartWorkers()
+
+	code, result := importTasks(t, target, "fail", data)
+	if code != http.StatusOK || result.Imported != 3 || result.Requeued != 1 {
+		t.Fatalf("Expected 3 imported and 1 requeued, got %d %+v", code, result)
+	}
+	select {
+	case id := <-processed:
+		if id != "queued-task" {
+			t.Errorf("Expected queued task to run, got %s", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected imported queued task to be processed")
+	}
+	failed, _ := targetService.GetTask("failed-task")
+	if snapshot := failed.Snapshot(); snapshot.Status != "failed" || snapshot.Retries != 1 {
+		t.Errorf("Expected failed task with its attempts, got %+v", snapshot)
+	}
+
+	if code, _ := importTasks(t, target, "fail", data); code != http.StatusConflict {
+		t.Errorf("Expected status 409 in fail mode, got %d", code)
+	}
+	if _, result := importTasks(t, target, "skip", data); result.Skipped != 3 || result.Imported != 0 {
+		t.Errorf("Expected all tasks skipped, got %+v", result)
+	}
+	if _, result := importTasks(t, target, "overwrite", data); result.Overwritten != 3 || result.Imported != 3 {
+		t.Errorf("Expected all tasks overwritten, got %+v", result)
+	}
+	if code, _ := importTasks(t, target, "merge", data); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown mode, got %d", code)
+	}
+	if code, _ := importTasks(t, target, "skip", []byte("{broken\n")); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid JSONL, got %d", code)
+	}
+	if code, _ := importTasks(t, target, "skip", []byte(`{"id":"odd","status":"paused"}`+"\n")); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown status, got %d", code)
+	}
+}
+
+func TestIntegration_ExportImportKeepsRetryBackoff(t *testing.T) {
+	backoff := model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(time.Second)}
+	source, sourceService := newAdminServer(t, queue.WithRetryPolicies(backoff, nil))
+	sourceService.RegisterHandler("flaky", func(ctx context.Context, task *model.Task) error {
+		return errors.New("broken")
+	})
+	sourceService.StartWorkers()
+
+	resp := postJSON(t, source.URL+"/enqueue", map[string]interface{}{
+		"id": "backoff-task", "type": "flaky", "payload": "a", "max_retries": 3,
+	})
+	resp.Body.Close()
+	task, _ := sourceService.GetTask("backoff-task")
+	deadline := time.Now().Add(2 * time.Second)
+	for task.GetRetries() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var snapshot model.TaskSnapshot
+	if err := json.Unmarshal(exportTasks(t, source, ""), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Status != "queued" || snapshot.RetryAt == nil || time.Until(*snapshot.RetryAt) < 500*time.Millisecond {
+		t.Fatalf("Expected task in backoff with retry_at, got %+v", snapshot)
+	}
+	data, _ := json.Marshal(snapshot)
+
+	target, targetService := newAdminServer(t)
+	processed := make(chan time.Time, 1)
+	targetService.RegisterHandler("flaky", func(ctx context.Context, task *model.Task) error {
+		processed <- time.Now()
+		return nil
+	})
+	targetService.StartWorkers()
+
+	if code, result := importTasks(t, target, "fail", append(data, '\n')); code != http.StatusOK || result.Requeued != 1 {
+		t.Fatalf("Expected task to be imported and requeued, got %d %+v", code, result)
+	}
+	select {
+	case startedAt := <-processed:
+		if startedAt.Before(*snapshot.RetryAt) {
+			t.Errorf("Expected imported task to wait until %v, started at %v", *snapshot.RetryAt, startedAt)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected imported task to run after its backoff")
+	}
+}
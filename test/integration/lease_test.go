@@ -0,0 +1,265 @@
+package main
+
+import (
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/service"
+	"TaskQueue/queue"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newLeaseServer(t *testing.T) (*httptest.Server, repository.TaskRepository) {
+	return newLeaseServerWithAuth(t, nil)
+}
+
+// newLeaseServerWithAuth - сервер аренды за auth.Middleware, если задан authenticator
+func newLeaseServerWithAuth(t *testing.T, authenticator auth.Authenticator) (*httptest.Server, repository.TaskRepository) {
+	repo := repository.NewInMemoryTaskRepository()
+	queueService := service.NewQueueService(repo, 1, 10,
+		queue.WithRemoteTypes("remote"),
+		queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}, nil),
+	)
+	httpController := controller.NewHTTPController(queueService)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /enqueue", httpController.EnqueueHandler)
+	mux.HandleFunc("POST /lease", httpController.LeaseHandler)
+	mux.HandleFunc("POST /tasks/{id}/heartbeat", httpController.HeartbeatHandler)
+	mux.HandleFunc("POST /tasks/{id}/progress", httpController.ProgressHandler)
+	mux.HandleFunc("POST /tasks/{id}/complete", httpController.CompleteHandler)
+	mux.HandleFunc("POST /tasks/{id}/fail", httpController.FailHandler)
+
+	var handler http.Handler = mux
+	if authenticator != nil {
+		handler = auth.Middleware(authenticator, mux)
+	}
+	queueService.StartWorkers()
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		server.Close()
+		queueService.Shutdown()
+	})
+	return server, repo
+}
+
+func postJSON(t *testing.T, url string, body interface{}) *http.Response {
+	data, _ := json.Marshal(body)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func leaseTasks(t *testing.T, server *httptest.Server, workerID string, timeout string) []map[string]interface{} {
+	resp := postJSON(t, server.URL+"/lease", map[string]interface{}{
+		"worker_id": workerID, "types": []string{"remote"}, "max": 10, "visibility_timeout": timeout,
+	})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 from /lease, got %d", resp.StatusCode)
+	}
+	var result struct {
+		Tasks []map[string]interface{} `json:"tasks"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return result.Tasks
+}
+
+func TestIntegration_RemoteLease(t *testing.T) {
+	server, repo := newLeaseServer(t)
+
+	for _, id := range []string{"remote-1", "remote-2"} {
+		resp := postJSON(t, server.URL+"/enqueue", map[string]interface{}{
+			"id": id, "type": "remote", "payload": "data", "max_retries": 3,
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("Expected status 202, got %d", resp.StatusCode)
+		}
+	}
+
+	tasks := leaseTasks(t, server, "w1", "10s")
+	if len(tasks) != 2 {
+		t.Fatalf("Expected 2 leased tasks, got %d", len(tasks))
+	}
+	if task, _ := repo.GetByID("remote-1"); task.GetStatus() != "running" {
+		t.Errorf("Expected leased task to be running, got %s", task.GetStatus())
+	}
+	if again := leaseTasks(t, server, "w2", "10s"); len(again) != 0 {
+		t.Errorf("Expected no tasks for second worker, got %d", len(again))
+	}
+
+	resp := postJSON(t, server.URL+"/tasks/remote-1/heartbeat", map[string]string{"worker_id": "w2"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409 for foreign heartbeat, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, server.URL+"/tasks/remote-1/heartbeat", map[string]string{"worker_id": "w1", "visibility_timeout": "20s"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 for heartbeat, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, server.URL+"/tasks/remote-1/complete", map[string]string{"worker_id": "w1"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status 204 for complete, got %d", resp.StatusCode)
+	}
+	if task, _ := repo.GetByID("remote-1"); task.GetStatus() != "done" {
+		t.Errorf("Expected done, got %s", task.GetStatus())
+	}
+
+	resp = postJSON(t, server.URL+"/tasks/remote-2/fail", map[string]string{"worker_id": "w1", "error": "boom"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status 204 for fail, got %d", resp.StatusCode)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	retried := leaseTasks(t, server, "w2", "10s")
+	if len(retried) != 1 || retried[0]["id"] != "remote-2" || retried[0]["attempt"].(float64) != 2 {
+		t.Errorf("Expected failed task to be leased again as attempt 2, got %v", retried)
+	}
+}
+
+func TestIntegration_RemoteProgress(t *testing.T) {
+	server, repo := newLeaseServer(t)
+
+	resp := postJSON(t, server.URL+"/enqueue", map[string]interface{}{
+		"id": "remote-progress", "type": "remote", "payload": "data", "max_retries": 3,
+	})
+	resp.Body.Close()
+	if tasks := leaseTasks(t, server, "w1", "1s"); len(tasks) != 1 {
+		t.Fatalf("Expected 1 leased task, got %d", len(tasks))
+	}
+
+	for name, body := range map[string]map[string]interface{}{
+		"foreign":  {"worker_id": "w2", "progress": 10},
+		"empty":    {"worker_id": "w1"},
+		"null":     {"worker_id": "w1", "checkpoint": nil},
+		"no owner": {"progress": 10},
+	} {
+		resp := postJSON(t, server.URL+"/tasks/remote-progress/progress", body)
+		resp.Body.Close()
+		expected := http.StatusBadRequest
+		if name == "foreign" {
+			expected = http.StatusConflict
+		}
+		if resp.StatusCode != expected {
+			t.Errorf("Expected status %d for %s progress, got %d", expected, name, resp.StatusCode)
+		}
+	}
+
+	resp = postJSON(t, server.URL+"/tasks/remote-progress/progress", map[string]interface{}{
+		"worker_id": "w1", "progress": 40, "message": "400/1000",
+		"checkpoint": map[string]int{"offset": 400}, "visibility_timeout": "20s",
+	})
+	var lease struct {
+		LeaseExpiresAt time.Time `json:"lease_expires_at"`
+	}
+	json.NewDecoder(resp.Body).Decode(&lease)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || time.Until(lease.LeaseExpiresAt) < 10*time.Second {
+		t.Fatalf("Expected progress to extend the lease, got %d %v", resp.StatusCode, lease.LeaseExpiresAt)
+	}
+	task, _ := repo.GetByID("remote-progress")
+	if progress, ok := task.GetProgress(); !ok || progress.Percent != 40 || progress.Message != "400/1000" {
+		t.Errorf("Expected stored progress, got %+v", progress)
+	}
+
+	// Следующая попытка продолжает с чекпоинта
+	resp = postJSON(t, server.URL+"/tasks/remote-progress/fail", map[string]string{"worker_id": "w1", "error": "boom"})
+	resp.Body.Close()
+	time.Sleep(50 * time.Millisecond)
+	retried := leaseTasks(t, server, "w2", "10s")
+	if len(retried) != 1 {
+		t.Fatalf("Expected task to be leased again, got %v", retried)
+	}
+	if checkpoint, _ := json.Marshal(retried[0]["checkpoint"]); string(checkpoint) != `{"offset":400}` {
+		t.Errorf("Expected checkpoint from previous attempt, got %s", checkpoint)
+	}
+}
+
+func TestIntegration_RemoteLeaseExpiry(t *testing.T) {
+	server, repo := newLeaseServer(t)
+
+	resp := postJSON(t, server.URL+"/enqueue", map[string]interface{}{
+		"id": "expiring", "type": "remote", "payload": "data", "max_retries": 3,
+	})
+	resp.Body.Close()
+
+	if tasks := leaseTasks(t, server, "w1", "100ms"); len(tasks) != 1 {
+		t.Fatalf("Expected 1 leased task, got %d", len(tasks))
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	task, _ := repo.GetByID("expiring")
+	if task.GetRetries() != 1 {
+		t.Errorf("Expected expired lease to count as an attempt, got %d retries", task.GetRetries())
+	}
+
+	resp = postJSON(t, server.URL+"/tasks/expiring/complete", map[string]string{"worker_id": "w1"})
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		t.Error("Expected complete to be rejected after lease expiry")
+	}
+
+	if tasks := leaseTasks(t, server, "w2", "10s"); len(tasks) != 1 {
+		t.Errorf("Expected expired task to be leased again, got %d", len(tasks))
+	}
+}
+
+func TestIntegration_RemoteLeaseBelongsToKey(t *testing.T) {
+	keys, err := auth.NewAPIKeyStore([]auth.APIKey{
+		{Name: "worker-a", Key: "key-a", Scopes: []string{"worker", "enqueue"}, Queues: []string{"jobs"}},
+		{Name: "worker-b", Key: "key-b", Scopes: []string{"worker"}, Queues: []string{"jobs"}},
+		{Name: "worker-c", Key: "key-c", Scopes: []string{"worker"}, Queues: []string{"other"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _ := newLeaseServerWithAuth(t, keys)
+	post := func(key, path string, body interface{}) int {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, bytes.NewReader(data))
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post("key-a", "/enqueue", map[string]interface{}{"id": "job-1", "type": "remote", "queue": "jobs", "payload": "data", "max_retries": 1}); code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", code)
+	}
+	if code := post("key-a", "/lease", map[string]interface{}{"worker_id": "w1", "types": []string{"remote"}}); code != http.StatusOK {
+		t.Fatalf("Expected status 200 from /lease, got %d", code)
+	}
+
+	// Тот же worker_id под другим ключом аренду не держит
+	if code := post("key-b", "/tasks/job-1/heartbeat", map[string]string{"worker_id": "w1"}); code != http.StatusConflict {
+		t.Errorf("Expected status 409 for heartbeat with another key, got %d", code)
+	}
+	if code := post("key-b", "/tasks/job-1/complete", map[string]string{"worker_id": "w1"}); code != http.StatusConflict {
+		t.Errorf("Expected status 409 for complete with another key, got %d", code)
+	}
+	if code := post("key-c", "/tasks/job-1/fail", map[string]string{"worker_id": "w1"}); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for task of a foreign queue, got %d", code)
+	}
+
+	if code := post("key-a", "/tasks/job-1/complete", map[string]string{"worker_id": "w1"}); code != http.StatusNoContent {
+		t.Errorf("Expected status 204 for complete by the lease owner, got %d", code)
+	}
+}
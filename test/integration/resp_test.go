@@ -0,0 +1,284 @@
+package main
+
+import (
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/resp"
+	"TaskQueue/internal/service"
+	"TaskQueue/queue"
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type respError string
+
+type respClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func startRespServer(t *testing.T, authenticator auth.Authenticator) (service.QueueService, string) {
+	queueService := service.NewQueueService(repository.NewInMemoryTaskRepository(), 1, 100,
+		queue.WithRemoteTypes("redis"))
+	queueService.StartWorkers()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := resp.NewServer(resp.Config{
+		TaskType:      "redis",
+		Authenticator: authenticator,
+		PollInterval:  50 * time.Millisecond,
+	}, queueService)
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Close()
+		queueService.Shutdown()
+	})
+	return queueService, listener.Addr().String()
+}
+
+func dialResp(t *testing.T, addr string) *respClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &respClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *respClient) send(args ...string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *respClient) do(args ...string) interface{} {
+	c.send(args...)
+	return c.reply()
+}
+
+// reply читает ответ; map RESP3 возвращается плоским списком ключей и значений
+func (c *respClient) reply() interface{} {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("Failed to read reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return respError(line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '_':
+		return nil
+	case '$':
+		size, _ := strconv.Atoi(line[1:])
+		if size < 0 {
+			return nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			c.t.Fatal(err)
+		}
+		return string(data[:size])
+	case '*', '%':
+		count, _ := strconv.Atoi(line[1:])
+		if count < 0 {
+			return nil
+		}
+		if line[0] == '%' {
+			count *= 2
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			items[i] = c.reply()
+		}
+		return items
+	}
+	c.t.Fatalf("Unexpected reply %q", line)
+	return nil
+}
+
+func expectReply(t *testing.T, got, want interface{}) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Expected reply %v, got %v", want, got)
+	}
+}
+
+func TestIntegration_RespListRoundTrip(t *testing.T) {
+	queueService, addr := startRespServer(t, nil)
+	producer := dialResp(t, addr)
+	consumer := dialResp(t, addr)
+
+	expectReply(t, producer.do("PING"), "PONG")
+	expectReply(t, producer.do("LPUSH", "emails", "first", "second"), int64(2))
+	expectReply(t, producer.do("LLEN", "emails"), int64(2))
+
+	expectReply(t, consumer.do("BRPOP", "emails", "1"), []interface{}{"emails", "first"})
+	expectReply(t, consumer.do("BRPOP", "other", "emails", "1"), []interface{}{"emails", "second"})
+	expectReply(t, producer.do("LLEN", "emails"), int64(0))
+
+	// Ожидающий BRPOP получает задачу, поставленную позже
+	consumer.send("BRPOP", "emails", "0")
+	time.Sleep(100 * time.Millisecond)
+	expectReply(t, producer.do("RPUSH", "emails", "late"), int64(1))
+	expectReply(t, consumer.reply(), []interface{}{"emails", "late"})
+
+	expectReply(t, consumer.do("BRPOP", "emails", "0.1"), nil)
+
+	stats := queueService.Stats(nil)
+	if done := stats.Queues["emails"]["done"]; done != 3 {
+		t.Errorf("Expected popped tasks to be done, got %v", stats.Queues["emails"])
+	}
+}
+
+func TestIntegration_RespHelloAndPipeline(t *testing.T) {
+	_, addr := startRespServer(t, nil)
+	client := dialResp(t, addr)
+
+	hello, ok := client.do("HELLO", "3").([]interface{})
+	if !ok || len(hello) != 14 || hello[5] != int64(3) {
+		t.Fatalf("Expected RESP3 HELLO map, got %v", hello)
+	}
+	expectReply(t, client.do("BRPOP", "empty", "0.05"), nil)
+
+	// Конвейер: ответы в порядке команд
+	if _, err := client.conn.Write([]byte("*1\r\n$4\r\nPING\r\n*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\nLLEN empty\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	expectReply(t, client.reply(), "PONG")
+	expectReply(t, client.reply(), "hi")
+	expectReply(t, client.reply(), int64(0))
+
+	reply := client.do("FLUSHALL")
+	if err, ok := reply.(respError); !ok || !strings.HasPrefix(string(err), "ERR unknown command") {
+		t.Errorf("Expected unknown command error, got %v", reply)
+	}
+}
+
+func TestIntegration_RespAuth(t *testing.T) {
+	keys, err := auth.NewAPIKeyStore([]auth.APIKey{
+		{Name: "producer", Key: "producer-key", Scopes: []string{"enqueue"}, Queues: []string{"emails"}},
+		{Name: "worker", Key: "worker-key", Scopes: []string{"worker", "read"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := startRespServer(t, keys)
+	client := dialResp(t, addr)
+
+	expectErrorPrefix := func(reply interface{}, prefix string) {
+		t.Helper()
+		if err, ok := reply.(respError); !ok || !strings.HasPrefix(string(err), prefix) {
+			t.Fatalf("Expected %s error, got %v", prefix, reply)
+		}
+	}
+	expectErrorPrefix(client.do("LPUSH", "emails", "x"), "NOAUTH")
+	expectErrorPrefix(client.do("HELLO", "3"), "NOAUTH")
+	expectErrorPrefix(client.do("AUTH", "wrong"), "WRONGPASS")
+
+	expectReply(t, client.do("AUTH", "default", "producer-key"), "OK")
+	expectReply(t, client.do("LPUSH", "emails", "x"), int64(1))
+	expectErrorPrefix(client.do("LPUSH", "reports", "x"), "NOPERM")
+	expectErrorPrefix(client.do("BRPOP", "emails", "1"), "NOPERM")
+
+	worker := dialResp(t, addr)
+	if hello, ok := worker.do("HELLO", "2", "AUTH", "default", "worker-key").([]interface{}); !ok || len(hello) != 14 {
+		t.Fatalf("Expected HELLO with AUTH to succeed, got %v", hello)
+	}
+	expectReply(t, worker.do("BRPOP", "emails", "1"), []interface{}{"emails", "x"})
+
+	// До AUTH большие команды отклоняются без выделения памяти под объявленный размер
+	for _, header := range []string{"*2\r\n$4\r\nECHO\r\n$1048576\r\n", "*100\r\n"} {
+		anonymous := dialResp(t, addr)
+		if _, err := anonymous.conn.Write([]byte(header)); err != nil {
+			t.Fatal(err)
+		}
+		expectErrorPrefix(anonymous.reply(), "ERR Protocol error")
+	}
+	large := strings.Repeat("x", 1<<20)
+	expectReply(t, client.do("LPUSH", "emails", large), int64(1))
+	expectReply(t, worker.do("BRPOP", "emails", "1"), []interface{}{"emails", large})
+}
+
+func TestIntegration_RespLeaseBelongsToKey(t *testing.T) {
+	keys, err := auth.NewAPIKeyStore([]auth.APIKey{
+		{Name: "worker-a", Key: "key-a", Scopes: []string{"worker", "enqueue"}, Queues: []string{"reports"}},
+		{Name: "worker-b", Key: "key-b", Scopes: []string{"worker"}, Queues: []string{"reports"}},
+		{Name: "worker-c", Key: "key-c", Scopes: []string{"worker"}, Queues: []string{"other"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := startRespServer(t, keys)
+	owner := dialResp(t, addr)
+	expectReply(t, owner.do("AUTH", "default", "key-a"), "OK")
+	expectReply(t, owner.do("TQ.ENQUEUE", "reports", "payload", "ID", "report-1"), "report-1")
+	if leased, ok := owner.do("TQ.LEASE", "w1", "redis").([]interface{}); !ok || len(leased) != 1 {
+		t.Fatalf("Expected one leased task, got %v", leased)
+	}
+
+	sameQueue := dialResp(t, addr)
+	expectReply(t, sameQueue.do("AUTH", "default", "key-b"), "OK")
+	if reply, ok := sameQueue.do("TQ.ACK", "report-1", "w1").(respError); !ok || !strings.Contains(string(reply), "another worker") {
+		t.Fatalf("Expected lease ownership error for another key, got %v", reply)
+	}
+	otherQueue := dialResp(t, addr)
+	expectReply(t, otherQueue.do("AUTH", "default", "key-c"), "OK")
+	if reply, ok := otherQueue.do("TQ.NACK", "report-1", "w1").(respError); !ok || !strings.Contains(string(reply), "not leased") {
+		t.Fatalf("Expected task of a foreign queue to look unleased, got %v", reply)
+	}
+
+	expectReply(t, owner.do("TQ.ACK", "report-1", "w1"), "OK")
+}
+
+func TestIntegration_RespLeaseAndAck(t *testing.T) {
+	queueService, addr := startRespServer(t, nil)
+	client := dialResp(t, addr)
+
+	expectReply(t, client.do("TQ.ENQUEUE", "reports", "payload", "ID", "report-1", "RETRIES", "2"), "report-1")
+	expectReply(t, client.do("TQ.STATUS", "report-1"), "queued")
+
+	leased, ok := client.do("TQ.LEASE", "w1", "redis", "QUEUE", "reports", "COUNT", "5").([]interface{})
+	if !ok || len(leased) != 1 {
+		t.Fatalf("Expected one leased task, got %v", leased)
+	}
+	fields := leased[0].([]interface{})
+	if fields[1] != "report-1" || fields[7] != "payload" || fields[9] != int64(1) {
+		t.Fatalf("Unexpected leased task %v", fields)
+	}
+	expectReply(t, client.do("TQ.STATUS", "report-1"), "running")
+
+	if _, ok := client.do("TQ.HEARTBEAT", "report-1", "w1", "30").(int64); !ok {
+		t.Fatal("Expected heartbeat to return lease expiry")
+	}
+	if err, ok := client.do("TQ.ACK", "report-1", "w2").(respError); !ok || !strings.Contains(string(err), "another worker") {
+		t.Fatalf("Expected lease ownership error, got %v", err)
+	}
+	expectReply(t, client.do("TQ.ACK", "report-1", "w1"), "OK")
+	expectReply(t, client.do("TQ.STATUS", "report-1"), "done")
+	expectReply(t, client.do("TQ.STATUS", "missing"), nil)
+
+	if status, _ := queueService.GetTaskStatus("report-1"); status != "done" {
+		t.Errorf("Expected task done in service, got %s", status)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"TaskQueue/internal/schema"
+	"TaskQueue/queue"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const emailSchema = `{
+	"type": "object",
+	"required": ["to", "subject"],
+	"properties": {
+		"to": {"type": "string", "format": "email"},
+		"subject": {"type": "string", "minLength": 1},
+		"attachments": {"type": "array", "items": {"type": "string"}}
+	},
+	"additionalProperties": false
+}`
+
+func doRequest(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestIntegration_PayloadSchemaValidation(t *testing.T) {
+	server, queueService := newAdminServer(t, queue.WithRemoteTypes("email"))
+
+	if err := queueService.RegisterSchema("email", json.RawMessage(`{"type":"nope"}`)); err == nil {
+		t.Fatal("Expected invalid schema to be rejected")
+	}
+	if err := queueService.RegisterSchema("email", json.RawMessage(emailSchema)); err != nil {
+		t.Fatalf("Expected schema to be registered: %v", err)
+	}
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/enqueue",
+		`{"id":"bad-email","type":"email","max_retries":1,"payload":{"to":"nobody","attachments":["a.pdf",7],"cc":"x"}}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Error  string              `json:"error"`
+		Errors []schema.FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{}
+	for _, fieldErr := range body.Errors {
+		fields[fieldErr.Field] = fieldErr.Keyword
+	}
+	expected := map[string]string{
+		"":               "additionalProperties",
+		"/attachments/1": "type",
+		"/subject":       "required",
+		"/to":            "format",
+	}
+	if len(fields) != len(expected) {
+		t.Errorf("Expected field errors %v, got %+v", expected, body.Errors)
+	}
+	for field, keyword := range expected {
+		if fields[field] != keyword {
+			t.Errorf("Expected %s error for %q, got %+v", keyword, field, body.Errors)
+		}
+	}
+	if _, exists := queueService.GetTask("bad-email"); exists {
+		t.Error("Expected rejected task not to be stored")
+	}
+
+	payload := `{"subject":"Hi","to":"user@example.com"}`
+	resp = doRequest(t, http.MethodPost, server.URL+"/enqueue",
+		`{"id":"good-email","type":"email","max_retries":1,"payload":`+payload+`}`)
+	if resp.StatusCode != http.StatusAccepted {
+		data, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected valid payload to be accepted, got %d %s", resp.StatusCode, data)
+	}
+	task, exists := queueService.GetTask("good-email")
+	if !exists || string(task.Payload) != payload {
+		t.Fatalf("Expected JSON payload to be stored as is, got %+v", task)
+	}
+
+	// Типы без схемы принимают любой JSON
+	resp = doRequest(t, http.MethodPost, server.URL+"/enqueue",
+		`{"id":"free","type":"other","max_retries":1,"payload":[1,2,3]}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected payload of type without schema to be accepted, got %d", resp.StatusCode)
+	}
+	for name, request := range map[string]string{
+		"null":       `{"id":"null-payload","type":"other","max_retries":1,"payload":null}`,
+		"missing":    `{"id":"no-payload","type":"other","max_retries":1}`,
+		"text":       `{"id":"text-object","type":"other","max_retries":1,"content_type":"text/csv","payload":{"a":1}}`,
+		"after-type": `{"id":"bad-again","type":"email","max_retries":1,"payload":"user@example.com"}`,
+	} {
+		if resp := doRequest(t, http.MethodPost, server.URL+"/enqueue", request); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %s payload to be rejected, got %d", name, resp.StatusCode)
+		}
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/enqueue",
+		`{"id":"csv","type":"other","max_retries":1,"content_type":"text/csv","payload":"a,b\n1,2"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected text payload as JSON string to be accepted, got %d", resp.StatusCode)
+	}
+	if task, _ := queueService.GetTask("csv"); task == nil || string(task.PayloadBytes()) != "a,b\n1,2" {
+		t.Errorf("Expected text body to be decoded, got %+v", task)
+	}
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/admin/schemas", "")
+	var list struct {
+		Types []string `json:"types"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	if len(list.Types) != 1 || list.Types[0] != "email" {
+		t.Errorf("Expected email schema in list, got %v", list.Types)
+	}
+	resp = doRequest(t, http.MethodGet, server.URL+"/admin/schemas/email", "")
+	if data, _ := io.ReadAll(resp.Body); !bytes.Equal(data, []byte(emailSchema)) {
+		t.Errorf("Expected registered schema, got %s", data)
+	}
+
+	// Схемы задаются только через SCHEMAS_DIR и не меняются по API
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		if resp := doRequest(t, method, server.URL+"/admin/schemas/email", emailSchema); resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected %s of schema to be unavailable, got %d", method, resp.StatusCode)
+		}
+	}
+
+	// Схема описывает JSON: текстовый payload для типа со схемой не принимается
+	resp = doRequest(t, http.MethodPost, server.URL+"/enqueue",
+		`{"id":"csv-email","type":"email","max_retries":1,"content_type":"text/plain","payload":"user@example.com"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected text payload of type with schema to be rejected, got %d", resp.StatusCode)
+	}
+	if _, exists := queueService.GetTask("csv-email"); exists {
+		t.Error("Expected rejected text task not to be stored")
+	}
+	resp = doRequest(t, http.MethodPost, server.URL+"/enqueue",
+		`{"id":"json-email","type":"email","max_retries":1,"content_type":"application/vnd.mail+json","payload":{"to":"nobody"}}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected +json payload to be validated, got %d", resp.StatusCode)
+	}
+}
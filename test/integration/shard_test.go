@@ -0,0 +1,306 @@
+package main
+
+import (
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/service"
+	"TaskQueue/internal/shard"
+	"TaskQueue/queue"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type shardNode struct {
+	id      string
+	router  *shard.Router
+	service service.QueueService
+	server  *httptest.Server
+	handler http.Handler
+	stopped bool
+}
+
+func (n *shardNode) stop() {
+	if n.stopped {
+		return
+	}
+	n.stopped = true
+	n.server.Close()
+	n.router.Stop()
+	n.service.Shutdown()
+}
+
+func newShardNode(id string) *shardNode {
+	node := &shardNode{id: id}
+	node.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		node.handler.ServeHTTP(w, r)
+	}))
+	return node
+}
+
+func (n *shardNode) addr() string {
+	return "http://" + n.server.Listener.Addr().String()
+}
+
+// start поднимает узел на loopback; membership создается по адресу узла
+func (n *shardNode) start(t *testing.T, membership func(addr string) shard.Membership) *shardNode {
+	// Задачи remote ждут удаленного воркера и остаются в очереди узла
+	n.service = service.NewQueueService(repository.NewInMemoryTaskRepository(), 1, 100,
+		queue.WithRemoteTypes("remote"))
+	n.router = shard.NewRouter(shard.Config{
+		NodeID:            n.id,
+		Secret:            "shard-secret",
+		RebalanceInterval: 100 * time.Millisecond,
+	}, membership(n.addr()), n.service)
+
+	httpController := controller.NewHTTPController(n.service)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /enqueue", httpController.EnqueueHandler)
+	mux.HandleFunc("GET /tasks/{id}", httpController.TaskHandler)
+	n.router.Register(mux)
+	n.handler = n.router.Middleware(mux)
+
+	n.server.Start()
+	n.service.StartWorkers()
+	n.router.Start()
+	t.Cleanup(n.stop)
+	return n
+}
+
+func gossipMembership(id string, seeds ...string) func(addr string) shard.Membership {
+	return func(addr string) shard.Membership {
+		return shard.NewGossip(shard.GossipConfig{
+			ID:       id,
+			Addr:     addr,
+			Seeds:    seeds,
+			Interval: 50 * time.Millisecond,
+			Secret:   "shard-secret",
+		})
+	}
+}
+
+func enqueueRemote(t *testing.T, node *shardNode, id, queueName string) {
+	resp := postJSON(t, node.server.URL+"/enqueue", map[string]interface{}{
+		"id": id, "type": "remote", "queue": queueName, "payload": "data", "max_retries": 1,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202 for %s, got %d", id, resp.StatusCode)
+	}
+}
+
+// waitForPlacement ждет, что каждая задача лежит ровно на узле-владельце ее очереди
+func waitForPlacement(t *testing.T, nodes []*shardNode, ring *shard.Ring, queues map[string]string) {
+	byID := make(map[string]*shardNode)
+	for _, node := range nodes {
+		byID[node.id] = node
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var misplaced string
+	for time.Now().Before(deadline) {
+		misplaced = ""
+		for id, queueName := range queues {
+			owner, _ := ring.Owner(shard.Key(queueName, ""))
+			for _, node := range nodes {
+				_, exists := node.service.GetTask(id)
+				if exists != (node == byID[owner]) {
+					misplaced = fmt.Sprintf("task %s (owner %s) on %s: %v", id, owner, node.id, exists)
+				}
+			}
+		}
+		if misplaced == "" {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Expected tasks on their owners: %s", misplaced)
+}
+
+func TestIntegration_ShardRoutesToOwner(t *testing.T) {
+	var nodes []*shardNode
+	peers := make(map[string]string)
+	for _, id := range []string{"n1", "n2", "n3"} {
+		node := newShardNode(id)
+		peers[id] = node.addr()
+		nodes = append(nodes, node)
+	}
+	for _, node := range nodes {
+		node.start(t, func(addr string) shard.Membership { return shard.NewStatic(peers) })
+	}
+
+	queues := make(map[string]string)
+	for i := 0; i < 30; i++ {
+		id := fmt.Sprintf("routed-%d", i)
+		queues[id] = fmt.Sprintf("q%d", i)
+		enqueueRemote(t, nodes[0], id, queues[id])
+	}
+	waitForPlacement(t, nodes, shard.NewRing(shard.DefaultVirtualNodes, "n1", "n2", "n3"), queues)
+
+	// Любой узел отвечает о задаче, которая лежит на другом
+	for id := range queues {
+		resp, err := http.Get(nodes[2].server.URL + "/tasks/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected task %s through any node, got %d", id, resp.StatusCode)
+		}
+	}
+	resp, _ := http.Get(nodes[2].server.URL + "/tasks/missing")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown task, got %d", resp.StatusCode)
+	}
+}
+
+func TestIntegration_ShardRebalancesOnJoinAndLeave(t *testing.T) {
+	n1 := newShardNode("n1").start(t, gossipMembership("n1"))
+	n2 := newShardNode("n2").start(t, gossipMembership("n2", n1.server.URL))
+	waitForRingSize(t, []*shardNode{n1, n2}, 2)
+
+	queues := make(map[string]string)
+	for i := 0; i < 30; i++ {
+		id := fmt.Sprintf("moving-%d", i)
+		queues[id] = fmt.Sprintf("q%d", i)
+		enqueueRemote(t, n1, id, queues[id])
+	}
+	waitForPlacement(t, []*shardNode{n1, n2}, shard.NewRing(shard.DefaultVirtualNodes, "n1", "n2"), queues)
+
+	n3 := newShardNode("n3").start(t, gossipMembership("n3", n2.server.URL))
+	all := []*shardNode{n1, n2, n3}
+	waitForRingSize(t, all, 3)
+	waitForPlacement(t, all, shard.NewRing(shard.DefaultVirtualNodes, "n1", "n2", "n3"), queues)
+
+	// Уходящий узел отдает свои ожидающие задачи оставшимся
+	n2.router.Leave()
+	n2.stop()
+	waitForRingSize(t, []*shardNode{n1, n3}, 2)
+	waitForPlacement(t, []*shardNode{n1, n3}, shard.NewRing(shard.DefaultVirtualNodes, "n1", "n3"), queues)
+}
+
+func waitForRingSize(t *testing.T, nodes []*shardNode, size int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		converged := true
+		for _, node := range nodes {
+			owner, _, _ := node.router.Owner("probe")
+			if owner == "" || len(membersOf(node)) != size {
+				converged = false
+			}
+		}
+		if converged {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Expected ring of %d nodes", size)
+}
+
+func membersOf(node *shardNode) map[string]string {
+	recorder := httptest.NewRecorder()
+	node.router.StatusHandler(recorder, httptest.NewRequest("GET", "/shard/status", nil))
+	var status shard.Status
+	json.Unmarshal(recorder.Body.Bytes(), &status)
+	return status.Members
+}
+
+func TestIntegration_ShardPeerRequestsRequireSecret(t *testing.T) {
+	var nodes []*shardNode
+	peers := make(map[string]string)
+	for _, id := range []string{"n1", "n2"} {
+		node := newShardNode(id)
+		peers[id] = node.addr()
+		nodes = append(nodes, node)
+	}
+	keys, err := auth.NewAPIKeyStore([]auth.APIKey{{Name: "producer", Key: "producer-key", Scopes: []string{"enqueue"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forwardedAuth []string
+	var mu sync.Mutex
+	for _, node := range nodes {
+		node.start(t, func(addr string) shard.Membership { return shard.NewStatic(peers) })
+		routed := node.handler
+		protected := auth.Middleware(auth.Chain(node.router.Authenticator(), keys), routed)
+		node.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(shard.ForwardedHeader) != "" {
+				mu.Lock()
+				forwardedAuth = append(forwardedAuth, r.Header.Get("Authorization"))
+				mu.Unlock()
+			}
+			protected.ServeHTTP(w, r)
+		})
+	}
+
+	for name, secret := range map[string]string{"missing": "", "wrong": "guess"} {
+		req, _ := http.NewRequest(http.MethodPost, nodes[0].server.URL+"/shard/handoff",
+			strings.NewReader(`{"id":"injected","type":"remote","queue":"q","payload":"x","status":"queued","max_retries":1}`+"\n"))
+		if secret != "" {
+			req.Header.Set(shard.SecretHeader, secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected handoff with %s secret to be rejected, got %d", name, resp.StatusCode)
+		}
+	}
+	if _, exists := nodes[0].service.GetTask("injected"); exists {
+		t.Error("Expected injected task not to be imported")
+	}
+
+	// Принципал с чужим секретом не принимается
+	req, _ := http.NewRequest(http.MethodPost, nodes[0].server.URL+"/enqueue",
+		strings.NewReader(`{"id":"forged","type":"remote","payload":"x","max_retries":1}`))
+	req.Header.Set(shard.ForwardedHeader, "n2")
+	req.Header.Set(shard.PrincipalHeader, `{"name":"admin","scopes":["admin"]}`)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected forged forwarded request to be rejected, got %d", resp.StatusCode)
+	}
+
+	// Задача для владельца n2 проходит через n1 без передачи ключа клиента
+	ring := shard.NewRing(shard.DefaultVirtualNodes, "n1", "n2")
+	queueName := ""
+	for i := 0; queueName == ""; i++ {
+		if owner, _ := ring.Owner(shard.Key(fmt.Sprintf("q%d", i), "")); owner == "n2" {
+			queueName = fmt.Sprintf("q%d", i)
+		}
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"id": "routed", "type": "remote", "queue": queueName, "payload": "data", "max_retries": 1,
+	})
+	req, _ = http.NewRequest(http.MethodPost, nodes[0].server.URL+"/enqueue", bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer producer-key")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected routed task to be accepted, got %d", resp.StatusCode)
+	}
+	if _, exists := nodes[1].service.GetTask("routed"); !exists {
+		t.Error("Expected task on owner n2")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(forwardedAuth) != 2 || forwardedAuth[1] != "" {
+		t.Errorf("Expected client credentials to be stripped when forwarding, got %q", forwardedAuth)
+	}
+}
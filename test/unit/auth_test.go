@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/controller"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newAuthHandler(t *testing.T, spec string) http.Handler {
+	store, err := auth.LoadAPIKeys(spec, "")
+	if err != nil {
+		t.Fatalf("Failed to load api keys: %v", err)
+	}
+
+	c := controller.NewHTTPController(&MockQueueService{status: "queued", exists: true})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /enqueue", c.EnqueueHandler)
+	mux.HandleFunc("GET /healthz", c.HealthHandler)
+	mux.HandleFunc("GET /status", c.StatusHandler)
+	return auth.Middleware(store, mux)
+}
+
+func enqueueRequest(key, queue string) *http.Request {
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":          "test1",
+		"queue":       queue,
+		"payload":     "data",
+		"max_retries": 1,
+	})
+	req := httptest.NewRequest("POST", "/enqueue", bytes.NewReader(body))
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	return req
+}
+
+func TestAuth_LoadAPIKeys_InvalidScope(t *testing.T) {
+	if _, err := auth.LoadAPIKeys("secret:write", ""); err == nil {
+		t.Error("Expected error for unknown scope")
+	}
+}
+
+func TestAuth_LoadAPIKeys_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(file, []byte(`[{"name":"producer","key":"k1","scopes":["enqueue"],"queues":["emails"]}]`), 0600)
+
+	store, err := auth.LoadAPIKeys("k2:read", file)
+	if err != nil {
+		t.Fatalf("Failed to load api keys: %v", err)
+	}
+	if store.Len() != 2 {
+		t.Errorf("Expected 2 keys, got %d", store.Len())
+	}
+
+	req := httptest.NewRequest("GET", "/status", nil)
+	req.Header.Set("Authorization", "Bearer k1")
+	principal, err := store.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Expected key to authenticate: %v", err)
+	}
+	if principal.Name != "producer" || !principal.HasScope(auth.ScopeEnqueue) || principal.HasScope(auth.ScopeRead) {
+		t.Errorf("Unexpected principal: %+v", principal)
+	}
+}
+
+func TestAuth_Middleware_Unauthorized(t *testing.T) {
+	handler := newAuthHandler(t, "secret:enqueue")
+
+	for _, key := range []string{"", "wrong"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, enqueueRequest(key, ""))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for key %q, got %d", key, w.Code)
+		}
+	}
+}
+
+func TestAuth_Middleware_HealthIsPublic(t *testing.T) {
+	handler := newAuthHandler(t, "secret:enqueue")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestAuth_Scopes(t *testing.T) {
+	handler := newAuthHandler(t, "producer:enqueue:emails;reader:read;root:admin")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, enqueueRequest("producer", "emails"))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, enqueueRequest("producer", "reports"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for foreign queue, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, enqueueRequest("reader", "emails"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without enqueue scope, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/status?id=test1", nil)
+	req.Header.Set("Authorization", "Bearer producer")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without read scope, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/status?id=test1", nil)
+	req.Header.Set("Authorization", "Bearer root")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for admin, got %d", w.Code)
+	}
+}
@@ -0,0 +1,325 @@
+package unit
+
+import (
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/service"
+	"TaskQueue/queue"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func findBreaker(statuses []queue.BreakerStatus, key string) (queue.BreakerStatus, bool) {
+	for _, status := range statuses {
+		if status.Key == key {
+			return status, true
+		}
+	}
+	return queue.BreakerStatus{}, false
+}
+
+func TestBreaker_HoldsTasksWithoutCountingAttempts(t *testing.T) {
+	breakerConfig, err := queue.ParseBreakerConfig("rate=0.5,min=2,cooldown=200ms")
+	if err != nil {
+		t.Fatalf("ParseBreakerConfig failed: %v", err)
+	}
+
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(2, 10, repo,
+		queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}, nil),
+		queue.WithBreakers(breakerConfig, nil),
+	)
+
+	var down atomic.Bool
+	down.Store(true)
+	var attempts atomic.Int32
+	pool.RegisterHandler("api", func(ctx context.Context, task *model.Task) error {
+		attempts.Add(1)
+		if down.Load() {
+			return errors.New("downstream unavailable")
+		}
+		return nil
+	})
+
+	pool.Start()
+	defer pool.Shutdown()
+
+	var tasks []*model.Task
+	for i := 0; i < 2; i++ {
+		task := &model.Task{ID: fmt.Sprintf("breaker-%d", i), Type: "api", Queue: model.DefaultQueue,
+			Resource: "billing", MaxRetries: 3, CreatedAt: time.Now()}
+		repo.Create(task)
+		pool.Enqueue(task)
+		tasks = append(tasks, task)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		status, ok := findBreaker(pool.Breakers(), "billing")
+		if ok && status.State == queue.BreakerOpen && status.Held == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected open breaker holding both tasks, got %+v", pool.Breakers())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("Expected 2 attempts before breaker opened, got %d", n)
+	}
+	if held := pool.Stats().Held; held != 2 {
+		t.Errorf("Expected 2 held tasks in stats, got %d", held)
+	}
+
+	down.Store(false)
+	for _, task := range tasks {
+		waitForStatus(t, task, "done")
+		if retries := task.GetRetries(); retries != 1 {
+			t.Errorf("Expected held task to keep 1 counted failure, got %d", retries)
+		}
+	}
+
+	status, _ := findBreaker(pool.Breakers(), "billing")
+	if status.State != queue.BreakerClosed {
+		t.Errorf("Expected breaker to close after successful probe, got %s", status.State)
+	}
+}
+
+func TestBreaker_FailedProbeReopens(t *testing.T) {
+	breakerConfig, _ := queue.ParseBreakerConfig("rate=1,min=1,cooldown=50ms")
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 10, repo,
+		queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}, nil),
+		queue.WithBreakers(queue.BreakerConfig{}, map[string]queue.BreakerConfig{"flaky": breakerConfig}),
+	)
+	pool.RegisterHandler("flaky", func(ctx context.Context, task *model.Task) error {
+		return errors.New("still down")
+	})
+
+	pool.Start()
+	defer pool.Shutdown()
+
+	task := &model.Task{ID: "probe", Type: "flaky", Queue: model.DefaultQueue, MaxRetries: 3, CreatedAt: time.Now()}
+	repo.Create(task)
+	pool.Enqueue(task)
+
+	// Первая ошибка открывает breaker, каждая проба после cooldown снова его открывает
+	waitForStatus(t, task, "failed")
+	status, ok := findBreaker(pool.Breakers(), "flaky")
+	if !ok || status.State != queue.BreakerOpen {
+		t.Errorf("Expected breaker to be open after failed probes, got %+v", status)
+	}
+
+	// Ключи без конфигурации не получают breaker
+	other := &model.Task{ID: "other", Type: "unconfigured", Queue: model.DefaultQueue, MaxRetries: 1, CreatedAt: time.Now()}
+	repo.Create(other)
+	pool.Enqueue(other)
+	deadline := time.Now().Add(2 * time.Second)
+	for !model.IsTerminalStatus(other.GetStatus()) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := findBreaker(pool.Breakers(), "unconfigured"); ok {
+		t.Error("Expected no breaker for unconfigured key")
+	}
+}
+
+func TestBreaker_IgnoresPermanentErrors(t *testing.T) {
+	breakerConfig, _ := queue.ParseBreakerConfig("rate=1,min=1,cooldown=1m")
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 10, repo,
+		queue.WithBreakers(breakerConfig, nil),
+	)
+	pool.RegisterHandler("validate", func(ctx context.Context, task *model.Task) error {
+		if task.ID == "bad-input" {
+			return queue.Permanent(errors.New("invalid input"))
+		}
+		return nil
+	})
+
+	pool.Start()
+	defer pool.Shutdown()
+
+	// Ошибка во входных данных не говорит о недоступности ресурса
+	for _, id := range []string{"bad-input", "good-input"} {
+		task := &model.Task{ID: id, Type: "validate", Queue: model.DefaultQueue, MaxRetries: 3, CreatedAt: time.Now()}
+		repo.Create(task)
+		pool.Enqueue(task)
+		if id == "bad-input" {
+			waitForStatus(t, task, "failed")
+		} else {
+			waitForStatus(t, task, "done")
+		}
+	}
+	if status, ok := findBreaker(pool.Breakers(), "validate"); ok && (status.State != queue.BreakerClosed || status.Failures != 0) {
+		t.Errorf("Expected permanent error not to count as breaker failure, got %+v", status)
+	}
+}
+
+func TestBreaker_CancelledProbeFreesSlot(t *testing.T) {
+	breakerConfig, _ := queue.ParseBreakerConfig("rate=1,min=1,cooldown=100ms")
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 10, repo,
+		queue.WithRemoteTypes("remote"),
+		queue.WithBreakers(breakerConfig, nil),
+	)
+	pool.Start()
+	defer pool.Shutdown()
+
+	enqueue := func(id string) *model.Task {
+		task := &model.Task{ID: id, Type: "remote", Queue: model.DefaultQueue, MaxRetries: 1, CreatedAt: time.Now()}
+		repo.Create(task)
+		if err := pool.Enqueue(task); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+		return task
+	}
+	lease := func() []queue.LeasedTask {
+		return pool.Lease(queue.LeaseRequest{WorkerID: "w1", Types: []string{"remote"}, Max: 10})
+	}
+
+	enqueue("opener")
+	if leased := lease(); len(leased) != 1 {
+		t.Fatalf("Expected one leased task, got %+v", leased)
+	}
+	if err := pool.Fail("opener", queue.LeaseHolder{WorkerID: "w1"}, "down"); err != nil {
+		t.Fatalf("Fail failed: %v", err)
+	}
+	enqueue("probe-1")
+	enqueue("probe-2")
+	time.Sleep(150 * time.Millisecond)
+
+	probe := lease()
+	if len(probe) != 1 {
+		t.Fatalf("Expected a single half-open probe, got %+v", probe)
+	}
+	task, _ := repo.GetByID(probe[0].ID)
+	if err := pool.Cancel(task); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	// Без освобождения пробы следующая выдача ждала бы cooldown
+	if next := lease(); len(next) != 1 || next[0].ID == probe[0].ID {
+		t.Errorf("Expected another probe right after cancellation, got %+v", next)
+	}
+}
+
+func TestBreaker_CancelDropsHeldTask(t *testing.T) {
+	breakerConfig, _ := queue.ParseBreakerConfig("rate=1,min=1,cooldown=1h")
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 10, repo,
+		queue.WithRemoteTypes("remote"),
+		queue.WithBreakers(breakerConfig, nil),
+	)
+	pool.Start()
+	defer pool.Shutdown()
+
+	enqueue := func(id string) *model.Task {
+		task := &model.Task{ID: id, Type: "remote", Queue: model.DefaultQueue, MaxRetries: 1,
+			Status: "queued", CreatedAt: time.Now()}
+		repo.Create(task)
+		if err := pool.Enqueue(task); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+		return task
+	}
+	lease := func() []queue.LeasedTask {
+		return pool.Lease(queue.LeaseRequest{WorkerID: "w1", Types: []string{"remote"}, Max: 10})
+	}
+
+	enqueue("opener")
+	lease()
+	if err := pool.Fail("opener", queue.LeaseHolder{WorkerID: "w1"}, "down"); err != nil {
+		t.Fatalf("Fail failed: %v", err)
+	}
+	task := enqueue("held")
+	if leased := lease(); len(leased) != 0 {
+		t.Fatalf("Expected task to be held by open breaker, got %+v", leased)
+	}
+
+	if err := pool.Cancel(task); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if held := pool.Stats().Held; held != 0 {
+		t.Errorf("Expected cancelled task to leave held, got %d", held)
+	}
+	if err := pool.Replay(task); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	lease()
+	if held := pool.Stats().Held; held != 1 {
+		t.Errorf("Expected replayed task to be held once, got %d", held)
+	}
+}
+
+func TestBreaker_ParseConfig(t *testing.T) {
+	config, err := queue.ParseBreakerConfig("rate=0.25,min=5,window=1m,cooldown=10s,probes=2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.ErrorRate != 0.25 || config.MinRequests != 5 || config.Window != time.Minute ||
+		config.Cooldown != 10*time.Second || config.Probes != 2 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	for _, spec := range []string{"rate=2", "min=0", "cooldown=abc", "unknown=1", "rate"} {
+		if _, err := queue.ParseBreakerConfig(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestBreaker_AdminEndpoints(t *testing.T) {
+	breakerConfig, _ := queue.ParseBreakerConfig("rate=1,min=1,cooldown=1h")
+	repo := repository.NewInMemoryTaskRepository()
+	queueService := service.NewQueueService(repo, 1, 10, queue.WithBreakers(breakerConfig, nil))
+	queueService.RegisterHandler("api", func(ctx context.Context, task *model.Task) error {
+		return errors.New("down")
+	})
+	httpController := controller.NewHTTPController(queueService)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/breakers", httpController.BreakersHandler)
+	mux.HandleFunc("POST /admin/breakers/{key}/reset", httpController.ResetBreakerHandler)
+
+	queueService.StartWorkers()
+	defer queueService.Shutdown()
+
+	task := &model.Task{ID: "admin-breaker", Type: "api", Queue: model.DefaultQueue, MaxRetries: 1, CreatedAt: time.Now()}
+	if err := queueService.Enqueue(task); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	waitForStatus(t, task, "failed")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/admin/breakers", nil))
+	var body struct {
+		Breakers []queue.BreakerStatus `json:"breakers"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if status, ok := findBreaker(body.Breakers, "api"); !ok || status.State != queue.BreakerOpen {
+		t.Fatalf("Expected open breaker in response, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/admin/breakers/api/reset", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if status, _ := findBreaker(queueService.Breakers(), "api"); status.State != queue.BreakerClosed {
+		t.Errorf("Expected breaker to be closed after reset, got %s", status.State)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/admin/breakers/missing/reset", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown breaker, got %d", w.Code)
+	}
+}
@@ -3,23 +3,40 @@ package unit
 import (
 	"TaskQueue/internal/controller"
 	"TaskQueue/internal/model"
+	"TaskQueue/internal/service"
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 )
 
+// Методы, не нужные тестам контроллера, берутся из встроенного интерфейса
 type MockQueueService struct {
+	service.QueueService
 	enqueueErr error
 	status     string
 	exists     bool
+	enqueued   *model.Task
 }
 
 func (m *MockQueueService) Enqueue(task *model.Task) error {
+	m.enqueued = task
 	return m.enqueueErr
 }
 
+func (m *MockQueueService) EnqueueContext(ctx context.Context, task *model.Task) error {
+	return m.Enqueue(task)
+}
+
+func (m *MockQueueService) GetTask(id string) (*model.Task, bool) {
+	if !m.exists {
+		return nil, false
+	}
+	return &model.Task{ID: id, Queue: model.DefaultQueue, Status: m.status}, true
+}
+
 func (m *MockQueueService) GetTaskStatus(id string) (string, bool) {
 	return m.status, m.exists
 }
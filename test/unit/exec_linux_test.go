@@ -0,0 +1,219 @@
+package unit
+
+import (
+	"TaskQueue/internal/handlers"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/queue"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newExecPool(t *testing.T, commands map[string]*handlers.ExecCommand) (queue.WorkerPool, repository.TaskRepository) {
+	t.Helper()
+	handler, err := handlers.NewExecHandler(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newHandlerPool(t, func(pool queue.WorkerPool) {
+		pool.RegisterHandler(handlers.ExecTaskType, handler.Handle)
+	})
+}
+
+func execTask(id string, payload handlers.ExecPayload) *model.Task {
+	data, _ := json.Marshal(payload)
+	return &model.Task{ID: id, Type: handlers.ExecTaskType, Payload: data}
+}
+
+func execOutput(t *testing.T, pool queue.WorkerPool, taskID string) []string {
+	t.Helper()
+	buffer, _, ok := pool.TaskLogs(taskID, 0)
+	if !ok {
+		t.Fatalf("Expected logs for %s", taskID)
+	}
+	lines, _, _, _ := buffer.Read(0)
+	var output []string
+	for _, line := range lines {
+		if line.Attrs["stream"] != nil {
+			output = append(output, line.Message)
+		}
+	}
+	return output
+}
+
+// execStreams разбирает вывод по потокам: stdout и stderr читаются
+// параллельно, и порядок строк между ними не определен
+func execStreams(t *testing.T, pool queue.WorkerPool, taskID string) map[string][]string {
+	t.Helper()
+	buffer, _, ok := pool.TaskLogs(taskID, 0)
+	if !ok {
+		t.Fatalf("Expected logs for %s", taskID)
+	}
+	lines, _, _, _ := buffer.Read(0)
+	streams := make(map[string][]string)
+	for _, line := range lines {
+		if stream, ok := line.Attrs["stream"].(string); ok {
+			streams[stream] = append(streams[stream], line.Message)
+		}
+	}
+	return streams
+}
+
+func TestExec_ArgsAreNotInterpretedByShell(t *testing.T) {
+	pool, repo := newExecPool(t, map[string]*handlers.ExecCommand{
+		"echo": {
+			Path:   "/bin/echo",
+			Args:   []string{"hello", "{name}"},
+			Params: map[string]handlers.ExecParam{"name": {}},
+		},
+	})
+
+	task := runTask(t, pool, repo, execTask("exec-echo", handlers.ExecPayload{
+		Command: "echo",
+		Args:    map[string]string{"name": "$(id); rm -rf / `whoami`"},
+	}), "done")
+
+	output := execOutput(t, pool, task.ID)
+	if len(output) != 1 || output[0] != "hello $(id); rm -rf / `whoami`" {
+		t.Errorf("Expected literal argument in output, got %q", output)
+	}
+	if result := taskResult[handlers.ExecResult](t, task); result.ExitCode != 0 {
+		t.Errorf("Expected exit code 0, got %+v", result)
+	}
+}
+
+func TestExec_RejectsUndeclaredAndFlagArgs(t *testing.T) {
+	defaultDir := "."
+	if _, err := handlers.NewExecHandler(map[string]*handlers.ExecCommand{
+		"ls": {Path: "/bin/ls", Args: []string{"{dir}"}},
+	}); err == nil {
+		t.Fatal("Expected error for undeclared placeholder")
+	}
+
+	handler, err := handlers.NewExecHandler(map[string]*handlers.ExecCommand{
+		"ls": {
+			Path:   "/bin/ls",
+			Args:   []string{"--color={mode}", "{dir}"},
+			Params: map[string]handlers.ExecParam{"dir": {Default: &defaultDir}, "mode": {Pattern: "never|auto"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, args := range map[string]map[string]string{
+		"undeclared": {"mode": "never", "extra": "x"},
+		"flag":       {"mode": "never", "dir": "--help"},
+		"pattern":    {"mode": "always"},
+		"missing":    {"dir": "."},
+	} {
+		data, _ := json.Marshal(handlers.ExecPayload{Command: "ls", Args: args})
+		task := &model.Task{ID: "exec-" + name, Type: handlers.ExecTaskType, Payload: data}
+		if err := handler.Handle(context.Background(), task); err == nil {
+			t.Errorf("Expected %s args to be rejected", name)
+		}
+	}
+
+	if _, err := handlers.NewExecHandler(map[string]*handlers.ExecCommand{"rel": {Path: "ls"}}); err == nil {
+		t.Error("Expected relative path to be rejected")
+	}
+	data, _ := json.Marshal(handlers.ExecPayload{Command: "missing"})
+	if err := handler.Handle(context.Background(), &model.Task{ID: "exec-unknown", Payload: data}); err == nil {
+		t.Error("Expected unknown command to be rejected")
+	}
+}
+
+func TestExec_NonZeroExitCodeFailsTask(t *testing.T) {
+	os.Setenv("EXEC_TEST_ALLOWED", "visible")
+	os.Setenv("EXEC_TEST_HIDDEN", "secret")
+	defer os.Unsetenv("EXEC_TEST_ALLOWED")
+	defer os.Unsetenv("EXEC_TEST_HIDDEN")
+
+	pool, repo := newExecPool(t, map[string]*handlers.ExecCommand{
+		"script": {
+			Path:   "/bin/sh",
+			Args:   []string{"-c", `echo "allowed=$EXEC_TEST_ALLOWED hidden=$EXEC_TEST_HIDDEN fixed=$FIXED"; echo oops >&2; exit 3`},
+			Env:    []string{"EXEC_TEST_ALLOWED"},
+			SetEnv: map[string]string{"FIXED": "1"},
+		},
+	})
+
+	task := runTask(t, pool, repo, execTask("exec-exit", handlers.ExecPayload{Command: "script"}), "failed")
+	if result := taskResult[handlers.ExecResult](t, task); result.ExitCode != 3 || result.Signal != "" || result.TimedOut {
+		t.Errorf("Expected exit code 3, got %+v", result)
+	}
+	streams := execStreams(t, pool, task.ID)
+	if stdout := streams["stdout"]; len(stdout) != 1 || stdout[0] != "allowed=visible hidden= fixed=1" {
+		t.Errorf("Expected stdout with allowlisted env, got %q", stdout)
+	}
+	if stderr := streams["stderr"]; len(stderr) != 1 || stderr[0] != "oops" {
+		t.Errorf("Expected stderr line, got %q", stderr)
+	}
+	if errs := task.Snapshot().AttemptErrors; len(errs) == 0 || !strings.Contains(errs[len(errs)-1].Error, "exited with code 3") {
+		t.Errorf("Expected exit code in attempt error, got %+v", errs)
+	}
+}
+
+func TestExec_TimeoutKillsProcessGroup(t *testing.T) {
+	pool, repo := newExecPool(t, map[string]*handlers.ExecCommand{
+		"sleep": {
+			Path: "/bin/sh",
+			// Потомок в фоне должен умереть вместе с группой
+			Args:      []string{"-c", "sleep 30 & echo $!; wait"},
+			Timeout:   model.Duration(300 * time.Millisecond),
+			KillGrace: model.Duration(100 * time.Millisecond),
+		},
+	})
+
+	started := time.Now()
+	task := runTask(t, pool, repo, execTask("exec-timeout", handlers.ExecPayload{Command: "sleep"}), "failed")
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("Expected command to be stopped by timeout, took %v", elapsed)
+	}
+	result := taskResult[handlers.ExecResult](t, task)
+	if !result.TimedOut || result.Signal == "" {
+		t.Errorf("Expected timed out result with signal, got %+v", result)
+	}
+
+	output := execOutput(t, pool, task.ID)
+	if len(output) != 1 {
+		t.Fatalf("Expected child pid in output, got %q", output)
+	}
+	deadline := time.Now().Add(time.Second)
+	for processAlive(output[0]) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected background child %s to be killed", output[0])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// processAlive считает зомби (не дождавшийся init потомок) завершенным
+func processAlive(pid string) bool {
+	stat, err := os.ReadFile("/proc/" + pid + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestExec_AppliesResourceLimits(t *testing.T) {
+	pool, repo := newExecPool(t, map[string]*handlers.ExecCommand{
+		"limits": {
+			Path:   "/bin/sh",
+			Args:   []string{"-c", "ulimit -n; ulimit -t"},
+			Limits: handlers.ExecLimits{OpenFiles: 64, CPUSeconds: 7},
+		},
+	})
+
+	task := runTask(t, pool, repo, execTask("exec-limits", handlers.ExecPayload{Command: "limits"}), "done")
+	streams := execStreams(t, pool, task.ID)
+	if stdout := streams["stdout"]; len(stdout) != 2 || stdout[0] != "64" || stdout[1] != "7" || len(streams["stderr"]) != 0 {
+		t.Errorf("Expected open files 64 and cpu 7 limits, got %q", streams)
+	}
+}
@@ -0,0 +1,56 @@
+package unit
+
+import (
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/queue"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func waitForStatus(t *testing.T, task *model.Task, status string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for task.GetStatus() != status {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected status %s, got %s", status, task.GetStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newHandlerPool запускает пул с одним воркером; register подключает
+// обработчики до старта
+func newHandlerPool(t *testing.T, register func(pool queue.WorkerPool), opts ...queue.Option) (queue.WorkerPool, repository.TaskRepository) {
+	t.Helper()
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 5, repo, opts...)
+	register(pool)
+	pool.Start()
+	t.Cleanup(pool.Shutdown)
+	return pool, repo
+}
+
+// runTask ставит задачу в очередь и ждет нужного статуса
+func runTask(t *testing.T, pool queue.WorkerPool, repo repository.TaskRepository, task *model.Task, status string) *model.Task {
+	t.Helper()
+	if task.Queue == "" {
+		task.Queue = model.DefaultQueue
+	}
+	task.CreatedAt = time.Now()
+	repo.Create(task)
+	pool.Enqueue(task)
+	waitForStatus(t, task, status)
+	return task
+}
+
+// taskResult разбирает результат, сохраненный обработчиком
+func taskResult[T any](t *testing.T, task *model.Task) T {
+	t.Helper()
+	var result T
+	if err := json.Unmarshal(task.GetResult(), &result); err != nil {
+		t.Fatalf("Expected %T result, got %q: %v", result, task.GetResult(), err)
+	}
+	return result
+}
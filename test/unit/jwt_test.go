@@ -0,0 +1,188 @@
+package unit
+
+import (
+	"TaskQueue/internal/auth"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testKeySet struct {
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	file   string
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newTestKeySet(t *testing.T) *testKeySet {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": "rsa1", "use": "sig",
+				"n": b64(rsaKey.N.Bytes()),
+				"e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC", "kid": "ec1", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))),
+				"y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		},
+	}
+	data, _ := json.Marshal(jwks)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &testKeySet{rsaKey: rsaKey, ecKey: ecKey, file: file}
+}
+
+func (ks *testKeySet) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	signingInput := b64(header) + "." + b64(body)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		sig, err := rsa.SignPKCS1v15(rand.Reader, ks.rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = sig
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, ks.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signingInput + "." + b64(signature)
+}
+
+func newTestJWTAuthenticator(t *testing.T, ks *testKeySet) *auth.JWTAuthenticator {
+	keys, err := auth.NewJWKS(ks.file, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to load jwks: %v", err)
+	}
+	return auth.NewJWTAuthenticator(keys, auth.JWTConfig{Issuer: "https://idp.test", Audience: "taskqueue"})
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":    "svc-billing",
+		"iss":    "https://idp.test",
+		"aud":    []string{"taskqueue"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"tenant": "billing",
+		"scope":  "enqueue read openid",
+		"queues": []string{"invoices"},
+	}
+}
+
+func authenticateToken(a auth.Authenticator, token string) (*auth.Principal, error) {
+	req := httptest.NewRequest("GET", "/status", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return a.Authenticate(req)
+}
+
+func TestJWT_ValidTokens(t *testing.T) {
+	ks := newTestKeySet(t)
+	authenticator := newTestJWTAuthenticator(t, ks)
+
+	for _, tc := range []struct{ alg, kid string }{{"RS256", "rsa1"}, {"ES256", "ec1"}} {
+		principal, err := authenticateToken(authenticator, ks.sign(t, tc.alg, tc.kid, validClaims()))
+		if err != nil {
+			t.Fatalf("%s: expected valid token, got %v", tc.alg, err)
+		}
+		if principal.Name != "svc-billing" || principal.Tenant != "billing" {
+			t.Errorf("%s: unexpected principal %+v", tc.alg, principal)
+		}
+		if !principal.HasScope(auth.ScopeEnqueue) || !principal.HasScope(auth.ScopeRead) || principal.HasScope(auth.ScopeAdmin) {
+			t.Errorf("%s: unexpected scopes %v", tc.alg, principal.Scopes)
+		}
+		if !principal.CanAccessQueue("invoices") || principal.CanAccessQueue("emails") {
+			t.Errorf("%s: unexpected queues %v", tc.alg, principal.Queues)
+		}
+	}
+}
+
+func TestJWT_InvalidTokens(t *testing.T) {
+	ks := newTestKeySet(t)
+	authenticator := newTestJWTAuthenticator(t, ks)
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "other"
+
+	wrongIssuer := validClaims()
+	wrongIssuer["iss"] = "https://evil.test"
+
+	valid := ks.sign(t, "RS256", "rsa1", validClaims())
+	tampered := valid[:len(valid)-4] + "AAAA"
+
+	tokens := map[string]string{
+		"expired":        ks.sign(t, "RS256", "rsa1", expired),
+		"wrong audience": ks.sign(t, "RS256", "rsa1", wrongAudience),
+		"wrong issuer":   ks.sign(t, "ES256", "ec1", wrongIssuer),
+		"unknown kid":    ks.sign(t, "RS256", "missing", validClaims()),
+		"alg mismatch":   ks.sign(t, "ES256", "rsa1", validClaims()),
+		"tampered":       tampered,
+		"malformed":      "not-a-jwt",
+	}
+	for name, token := range tokens {
+		if _, err := authenticateToken(authenticator, token); err == nil {
+			t.Errorf("Expected error for %s token", name)
+		}
+	}
+}
+
+func TestJWT_JWKSSkipsUnsupportedKeys(t *testing.T) {
+	ks := newTestKeySet(t)
+	var set map[string][]map[string]string
+	data, _ := os.ReadFile(ks.file)
+	json.Unmarshal(data, &set)
+	set["keys"] = append(set["keys"],
+		map[string]string{"kty": "OKP", "kid": "ed1", "crv": "Ed25519", "x": b64(make([]byte, 32))},
+		map[string]string{"kty": "EC", "kid": "ec384", "crv": "P-384", "x": "AA", "y": "AA"},
+	)
+	data, _ = json.Marshal(set)
+	os.WriteFile(ks.file, data, 0600)
+
+	a := newTestJWTAuthenticator(t, ks)
+	if _, err := authenticateToken(a, ks.sign(t, "RS256", "rsa1", validClaims())); err != nil {
+		t.Errorf("Expected supported key to work next to unsupported ones, got %v", err)
+	}
+
+	onlyUnsupported, _ := json.Marshal(map[string]interface{}{"keys": set["keys"][2:]})
+	os.WriteFile(ks.file, onlyUnsupported, 0600)
+	if _, err := auth.NewJWKS(ks.file, time.Minute); err == nil {
+		t.Error("Expected error for JWKS without usable keys")
+	}
+}
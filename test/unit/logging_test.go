@@ -0,0 +1,114 @@
+package unit
+
+import (
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/logging"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/queue"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogging_Setup_Invalid(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	if _, err := logging.Setup(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+	if _, err := logging.Setup(&bytes.Buffer{}, "json", "loud"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+}
+
+func TestLogging_RequestIDPropagation(t *testing.T) {
+	mockService := &MockQueueService{}
+	c := controller.NewHTTPController(mockService)
+	handler := logging.RequestIDMiddleware(http.HandlerFunc(c.EnqueueHandler))
+
+	body, _ := json.Marshal(map[string]interface{}{"id": "test1", "payload": "data", "max_retries": 1})
+	req := httptest.NewRequest("POST", "/enqueue", bytes.NewReader(body))
+	req.Header.Set(logging.RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get(logging.RequestIDHeader) != "req-42" {
+		t.Errorf("Expected request id header 'req-42', got '%s'", w.Header().Get(logging.RequestIDHeader))
+	}
+	if mockService.enqueued == nil || mockService.enqueued.RequestID != "req-42" {
+		t.Error("Expected request id to be stored on the task")
+	}
+
+	req = httptest.NewRequest("POST", "/enqueue", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get(logging.RequestIDHeader) == "" {
+		t.Error("Expected generated request id")
+	}
+}
+
+func TestLogging_TaskFields(t *testing.T) {
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+
+	out := &syncBuffer{}
+	if _, err := logging.Setup(out, "json", "debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 1, repo)
+	task := &model.Task{ID: "log-task", Type: "email", Queue: "mail", MaxRetries: 1, RequestID: "req-7"}
+	repo.Create(task)
+	pool.Start()
+	pool.Enqueue(task)
+	time.Sleep(600 * time.Millisecond)
+	pool.Shutdown()
+
+	var found bool
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %s", line)
+		}
+		if entry["task_id"] != "log-task" {
+			continue
+		}
+		found = true
+		for _, key := range []string{"type", "queue", "attempt", "worker_id", "request_id"} {
+			if _, ok := entry[key]; !ok {
+				t.Errorf("Expected field %s in %s", key, line)
+			}
+		}
+		if entry["request_id"] != "req-7" {
+			t.Errorf("Expected request_id 'req-7', got %v", entry["request_id"])
+		}
+	}
+	if !found {
+		t.Error("Expected log lines for the task")
+	}
+}
@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/queue"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func recordingMiddleware(name string, mu *sync.Mutex, calls *[]string) queue.Middleware {
+	return func(next queue.Handler) queue.Handler {
+		return func(ctx context.Context, task *model.Task) error {
+			mu.Lock()
+			*calls = append(*calls, name)
+			mu.Unlock()
+			return next(ctx, task)
+		}
+	}
+}
+
+func TestMiddleware_ChainOrder(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 5, repo,
+		queue.WithMiddleware(recordingMiddleware("option", &mu, &calls)),
+	)
+	pool.Use(recordingMiddleware("global", &mu, &calls))
+	pool.UseFor("report", recordingMiddleware("report", &mu, &calls))
+	pool.UseFor("email", recordingMiddleware("email", &mu, &calls))
+
+	done := make(chan struct{}, 1)
+	pool.RegisterHandler("report", func(ctx context.Context, task *model.Task) error {
+		if queue.LoggerFromContext(ctx) == nil {
+			t.Error("Expected attempt logger in handler context")
+		}
+		done <- struct{}{}
+		return nil
+	})
+
+	pool.Start()
+	defer pool.Shutdown()
+
+	task := &model.Task{ID: "mw-order", Type: "report", Queue: model.DefaultQueue, MaxRetries: 1, CreatedAt: time.Now()}
+	repo.Create(task)
+	pool.Enqueue(task)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected handler to run")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"option", "global", "report"}
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("Expected calls %v, got %v", expected, calls)
+			break
+		}
+	}
+}
+
+func TestMiddleware_PanicBecomesFailure(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 5, repo)
+	pool.RegisterHandler("crash", func(ctx context.Context, task *model.Task) error {
+		panic("boom")
+	})
+
+	pool.Start()
+	defer pool.Shutdown()
+
+	task := &model.Task{ID: "mw-panic", Type: "crash", Queue: model.DefaultQueue, MaxRetries: 1, CreatedAt: time.Now()}
+	repo.Create(task)
+	pool.Enqueue(task)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for task.GetStatus() != "failed" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected panicking task to fail, got %s", task.GetStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMiddleware_Recover(t *testing.T) {
+	handler := queue.Chain(func(ctx context.Context, task *model.Task) error {
+		panic("boom")
+	}, queue.Recover())
+
+	err := handler(context.Background(), &model.Task{ID: "recover"})
+	var panicErr *queue.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected PanicError, got %v", err)
+	}
+	if panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Errorf("Expected panic value and stack, got %+v", panicErr)
+	}
+}
+
+func TestMiddleware_DecodeJSON(t *testing.T) {
+	type emailPayload struct {
+		To string `json:"to"`
+	}
+
+	var got emailPayload
+	handler := queue.Chain(func(ctx context.Context, task *model.Task) error {
+		payload, ok := queue.PayloadFromContext[emailPayload](ctx)
+		if !ok {
+			return errors.New("payload missing")
+		}
+		got = payload
+		return nil
+	}, queue.DecodeJSON[emailPayload]())
+
+	if err := handler(context.Background(), &model.Task{Payload: json.RawMessage(`{"to":"a@example.com"}`)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.To != "a@example.com" {
+		t.Errorf("Expected decoded payload, got %+v", got)
+	}
+
+	if err := handler(context.Background(), &model.Task{Payload: json.RawMessage("not json")}); err == nil {
+		t.Error("Expected decode error for invalid payload")
+	}
+}
@@ -0,0 +1,218 @@
+package unit
+
+import (
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/service"
+	"TaskQueue/queue"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newGatedPool - пул с одним воркером, который ждет закрытия gate
+func newGatedPool(t *testing.T, queueSize int, opts ...queue.Option) (queue.WorkerPool, repository.TaskRepository, chan struct{}) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, queueSize, repo, opts...)
+	gate := make(chan struct{})
+	pool.RegisterHandler("gated", func(ctx context.Context, task *model.Task) error {
+		<-gate
+		return nil
+	})
+	pool.Start()
+	t.Cleanup(pool.Shutdown)
+	return pool, repo, gate
+}
+
+func newGatedTask(repo repository.TaskRepository, id string) *model.Task {
+	task := &model.Task{ID: id, Type: "gated", Queue: model.DefaultQueue, MaxRetries: 1, Status: "queued", CreatedAt: time.Now()}
+	repo.Create(task)
+	return task
+}
+
+func TestOverflow_BlockWaitsForSpace(t *testing.T) {
+	pool, repo, gate := newGatedPool(t, 1, queue.WithOverflow(queue.OverflowBlock, time.Second))
+
+	pool.Enqueue(newGatedTask(repo, "running"))
+	waitForStatus(t, mustGet(repo, "running"), "running")
+	if err := pool.Enqueue(newGatedTask(repo, "buffered")); err != nil {
+		t.Fatalf("Expected buffered task to fit, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := pool.EnqueueContext(ctx, newGatedTask(repo, "timed-out"))
+	var fullErr *queue.QueueFullError
+	if !errors.As(err, &fullErr) || !errors.Is(err, queue.ErrQueueFull) {
+		t.Fatalf("Expected QueueFullError after client timeout, got %v", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- pool.EnqueueContext(context.Background(), newGatedTask(repo, "blocked"))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(gate)
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Expected blocked enqueue to succeed once space frees, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Blocked enqueue did not return")
+	}
+}
+
+func TestOverflow_ThrottleReturnsRetryAfter(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(0, 1, repo, queue.WithOverflow(queue.OverflowThrottle, 0))
+
+	pool.Enqueue(newGatedTask(repo, "first"))
+	err := pool.Enqueue(newGatedTask(repo, "second"))
+
+	var fullErr *queue.QueueFullError
+	if !errors.As(err, &fullErr) {
+		t.Fatalf("Expected QueueFullError, got %v", err)
+	}
+	// Очередь не разбирается - максимальная оценка
+	if fullErr.RetryAfter != time.Minute {
+		t.Errorf("Expected 1m retry after without drain, got %s", fullErr.RetryAfter)
+	}
+}
+
+func TestOverflow_SpillFeedsTasksBack(t *testing.T) {
+	dir := t.TempDir()
+	pool, repo, gate := newGatedPool(t, 1,
+		queue.WithOverflow(queue.OverflowSpill, 0),
+		queue.WithSpill(dir, 1<<20),
+	)
+
+	var tasks []*model.Task
+	for _, id := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		task := newGatedTask(repo, id)
+		tasks = append(tasks, task)
+		if err := pool.Enqueue(task); err != nil {
+			t.Fatalf("Expected %s to be accepted, got %v", id, err)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "overflow.jsonl"))
+	if err != nil || info.Size() == 0 {
+		t.Fatalf("Expected spilled tasks on disk, got %v", err)
+	}
+
+	close(gate)
+	for _, task := range tasks {
+		waitForStatus(t, task, "done")
+	}
+
+	info, _ = os.Stat(filepath.Join(dir, "overflow.jsonl"))
+	if info.Size() != 0 {
+		t.Errorf("Expected spill file to be truncated after draining, got %d bytes", info.Size())
+	}
+}
+
+func TestOverflow_SpillLimitThrottles(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(0, 1, repo,
+		queue.WithOverflow(queue.OverflowSpill, 0),
+		queue.WithSpill(t.TempDir(), 10),
+	)
+
+	pool.Enqueue(newGatedTask(repo, "fits"))
+	if err := pool.Enqueue(newGatedTask(repo, "too-big")); !errors.Is(err, queue.ErrQueueFull) {
+		t.Errorf("Expected queue full when spill limit is exceeded, got %v", err)
+	}
+}
+
+func TestOverflow_SpillRemoteTasks(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(0, 1, repo,
+		queue.WithRemoteTypes("remote"),
+		queue.WithOverflow(queue.OverflowSpill, 0),
+		queue.WithSpill(t.TempDir(), 1<<20),
+	)
+	pool.Start()
+	t.Cleanup(pool.Shutdown)
+
+	for _, id := range []string{"r1", "r2", "r3"} {
+		task := &model.Task{ID: id, Type: "remote", Queue: model.DefaultQueue, MaxRetries: 1, Status: "queued", CreatedAt: time.Now()}
+		repo.Create(task)
+		if err := pool.Enqueue(task); err != nil {
+			t.Fatalf("Expected remote task %s to be spilled, got %v", id, err)
+		}
+	}
+
+	// Задачи из spill доходят до удаленных воркеров по порядку приема
+	var leased []string
+	deadline := time.Now().Add(2 * time.Second)
+	for len(leased) < 3 && time.Now().Before(deadline) {
+		for _, task := range pool.Lease(queue.LeaseRequest{WorkerID: "w1", Types: []string{"remote"}, Max: 1}) {
+			leased = append(leased, task.ID)
+			pool.Complete(task.ID, queue.LeaseHolder{WorkerID: "w1"})
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(leased) != 3 || leased[0] != "r1" || leased[1] != "r2" || leased[2] != "r3" {
+		t.Errorf("Expected spilled remote tasks leased in order, got %v", leased)
+	}
+}
+
+func TestService_RejectedTaskIsNotStored(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	queueService := service.NewQueueService(repo, 0, 1)
+
+	if err := queueService.Enqueue(&model.Task{ID: "a", Payload: json.RawMessage(`1`), MaxRetries: 1}); err != nil {
+		t.Fatal(err)
+	}
+	err := queueService.Enqueue(&model.Task{ID: "b", Payload: json.RawMessage(`1`), MaxRetries: 1})
+	if !errors.Is(err, queue.ErrQueueFull) {
+		t.Fatalf("Expected queue full, got %v", err)
+	}
+	// Как и при 429, отклоненную задачу можно повторить с тем же id
+	if _, exists := queueService.GetTask("b"); exists {
+		t.Error("Expected rejected task not to be stored")
+	}
+}
+
+func TestController_EnqueueThrottled(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	queueService := service.NewQueueService(repo, 0, 1, queue.WithOverflow(queue.OverflowThrottle, 0))
+	ctl := controller.NewHTTPController(queueService)
+
+	enqueue := func(id string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"id": id, "payload": "data", "max_retries": 1})
+		w := httptest.NewRecorder()
+		ctl.EnqueueHandler(w, httptest.NewRequest("POST", "/enqueue?timeout=1", bytes.NewReader(body)))
+		return w
+	}
+
+	if w := enqueue("a"); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", w.Code)
+	}
+	w := enqueue("b")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected 429 with Retry-After 60, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	// Отклоненная задача не сохраняется, клиент повторяет с тем же id
+	if _, exists := queueService.GetTask("b"); exists {
+		t.Error("Expected throttled task not to be stored")
+	}
+	if w := enqueue("a"); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for duplicate id, got %d", w.Code)
+	}
+}
+
+func mustGet(repo repository.TaskRepository, id string) *model.Task {
+	task, _ := repo.GetByID(id)
+	return task
+}
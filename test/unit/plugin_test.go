@@ -0,0 +1,294 @@
+package unit
+
+import (
+	"TaskQueue/internal/handlers"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/queue"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Минимальный кодировщик модулей WebAssembly для тестовых плагинов
+
+const wasmI32 = 0x7f
+
+type wasmImport struct {
+	name   string
+	params int
+}
+
+type wasmFunc struct {
+	name   string
+	params int
+	body   []byte
+}
+
+func uleb(v uint32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+func sleb(v int32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func wasmName(name string) []byte {
+	return append(uleb(uint32(len(name))), name...)
+}
+
+func wasmVec(items [][]byte) []byte {
+	out := uleb(uint32(len(items)))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func wasmSection(id byte, items [][]byte) []byte {
+	content := wasmVec(items)
+	return append(append([]byte{id}, uleb(uint32(len(content)))...), content...)
+}
+
+func wasmFuncType(params, results int) []byte {
+	out := append([]byte{0x60}, uleb(uint32(params))...)
+	for i := 0; i < params; i++ {
+		out = append(out, wasmI32)
+	}
+	out = append(out, uleb(uint32(results))...)
+	for i := 0; i < results; i++ {
+		out = append(out, wasmI32)
+	}
+	return out
+}
+
+// buildPlugin собирает модуль с памятью в одну страницу, импортами из
+// "taskqueue" и функциями i32... -> i32
+func buildPlugin(imports []wasmImport, funcs []wasmFunc) []byte {
+	var types, importEntries, funcEntries, exports, codes [][]byte
+	for i, imp := range imports {
+		types = append(types, wasmFuncType(imp.params, 0))
+		entry := append(wasmName("taskqueue"), wasmName(imp.name)...)
+		importEntries = append(importEntries, append(append(entry, 0x00), uleb(uint32(i))...))
+	}
+	exports = append(exports, append(wasmName("memory"), 0x02, 0x00))
+	for i, fn := range funcs {
+		typeIndex := uint32(len(types))
+		types = append(types, wasmFuncType(fn.params, 1))
+		funcEntries = append(funcEntries, uleb(typeIndex))
+		exports = append(exports, append(append(wasmName(fn.name), 0x00), uleb(uint32(len(imports)+i))...))
+		body := append([]byte{0x00}, fn.body...)
+		body = append(body, 0x0b)
+		codes = append(codes, append(uleb(uint32(len(body))), body...))
+	}
+
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, wasmSection(1, types)...)
+	if len(importEntries) > 0 {
+		module = append(module, wasmSection(2, importEntries)...)
+	}
+	module = append(module, wasmSection(3, funcEntries)...)
+	module = append(module, wasmSection(5, [][]byte{{0x00, 0x01}})...)
+	module = append(module, wasmSection(7, exports)...)
+	module = append(module, wasmSection(10, codes)...)
+	return module
+}
+
+func i32Const(v int32) []byte {
+	return append([]byte{0x41}, sleb(v)...)
+}
+
+var allocAt1024 = wasmFunc{name: "alloc", params: 1, body: i32Const(1024)}
+
+// echoPlugin пишет payload в лог и результат, код возврата - первая цифра payload
+func echoPlugin() []byte {
+	handle := []byte{}
+	handle = append(handle, i32Const(1)...)
+	handle = append(handle, 0x20, 0x00, 0x20, 0x01, 0x10, 0x01) // log(1, ptr, len)
+	handle = append(handle, 0x20, 0x00, 0x20, 0x01, 0x10, 0x00) // set_result(ptr, len)
+	handle = append(handle, 0x20, 0x00, 0x2d, 0x00, 0x00)       // i32.load8_u(ptr)
+	handle = append(handle, i32Const('0')...)
+	handle = append(handle, 0x6b) // i32.sub
+	return buildPlugin(
+		[]wasmImport{{"set_result", 2}, {"log", 3}},
+		[]wasmFunc{allocAt1024, {name: "handle", params: 2, body: handle}},
+	)
+}
+
+// errorPlugin возвращает payload как ошибку с кодом 2 (постоянная ошибка)
+func errorPlugin() []byte {
+	handle := []byte{0x20, 0x00, 0x20, 0x01, 0x10, 0x00} // set_error(ptr, len)
+	handle = append(handle, i32Const(2)...)
+	return buildPlugin(
+		[]wasmImport{{"set_error", 2}},
+		[]wasmFunc{allocAt1024, {name: "handle", params: 2, body: handle}},
+	)
+}
+
+// loopPlugin не завершается
+func loopPlugin() []byte {
+	handle := []byte{0x03, 0x40, 0x0c, 0x00, 0x0b} // loop br 0 end
+	handle = append(handle, i32Const(0)...)
+	return buildPlugin(nil, []wasmFunc{allocAt1024, {name: "handle", params: 2, body: handle}})
+}
+
+// growPlugin запрашивает 16 страниц памяти и возвращает 1, если их не дали
+func growPlugin() []byte {
+	handle := append(i32Const(16), 0x40, 0x00) // memory.grow
+	handle = append(handle, i32Const(-1)...)
+	handle = append(handle, 0x46) // i32.eq
+	return buildPlugin(nil, []wasmFunc{allocAt1024, {name: "handle", params: 2, body: handle}})
+}
+
+func writePlugin(t *testing.T, dir, taskType string, module []byte, modTime time.Time) {
+	t.Helper()
+	file := filepath.Join(dir, taskType+".wasm")
+	if err := os.WriteFile(file, module, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(file, modTime, modTime)
+}
+
+func newPluginPool(t *testing.T, cfg handlers.PluginConfig) (*handlers.Plugins, queue.WorkerPool, repository.TaskRepository) {
+	t.Helper()
+	var plugins *handlers.Plugins
+	pool, repo := newHandlerPool(t, func(pool queue.WorkerPool) {
+		var err error
+		if plugins, err = handlers.NewPlugins(cfg, pool); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { plugins.Close() })
+	}, queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}, nil))
+	return plugins, pool, repo
+}
+
+func pluginTask(id, taskType, payload string) *model.Task {
+	return &model.Task{ID: id, Type: taskType, Payload: model.TextPayload(payload), ContentType: "text/plain", MaxRetries: 2}
+}
+
+func TestPlugins_RunsModuleAndStoresResult(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "echo", echoPlugin(), time.Now())
+	plugins, pool, repo := newPluginPool(t, handlers.PluginConfig{Dir: dir})
+	if types := plugins.Types(); len(types) != 1 || types[0] != "echo" {
+		t.Fatalf("Expected echo plugin, got %v", types)
+	}
+
+	task := runTask(t, pool, repo, pluginTask("plugin-ok", "echo", `0`), "done")
+	if string(task.GetResult()) != `0` {
+		t.Errorf("Expected payload as result, got %s", task.GetResult())
+	}
+	buffer, _, _ := pool.TaskLogs(task.ID, 0)
+	lines, _, _, _ := buffer.Read(0)
+	if !hasLine(lines, "0") {
+		t.Errorf("Expected plugin log line, got %+v", lines)
+	}
+
+	retried := runTask(t, pool, repo, pluginTask("plugin-retry", "echo", `1 busy`), "failed")
+	if retried.GetRetries() != 2 || string(retried.GetResult()) != `"1 busy"` {
+		t.Errorf("Expected code 1 to be retried, got %d attempts and result %s", retried.GetRetries(), retried.GetResult())
+	}
+}
+
+func TestPlugins_HotReloadReplacesModule(t *testing.T) {
+	dir := t.TempDir()
+	started := time.Now().Add(-time.Hour)
+	writePlugin(t, dir, "job", echoPlugin(), started)
+	plugins, pool, repo := newPluginPool(t, handlers.PluginConfig{Dir: dir})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go plugins.Watch(20*time.Millisecond, stop)
+
+	runTask(t, pool, repo, pluginTask("before-reload", "job", `0`), "done")
+
+	writePlugin(t, dir, "job", errorPlugin(), started.Add(time.Minute))
+	time.Sleep(100 * time.Millisecond)
+	task := runTask(t, pool, repo, pluginTask("after-reload", "job", `invalid input`), "failed")
+	errs := task.Snapshot().AttemptErrors
+	if task.GetRetries() != 1 || len(errs) != 1 || errs[0].Error != "invalid input" {
+		t.Errorf("Expected permanent plugin error after reload, got %d attempts %+v", task.GetRetries(), errs)
+	}
+
+	// Битый модуль не заменяет рабочий
+	writePlugin(t, dir, "job", []byte("not wasm"), started.Add(2*time.Minute))
+	time.Sleep(100 * time.Millisecond)
+	runTask(t, pool, repo, pluginTask("after-broken", "job", `still works`), "failed")
+
+	os.Remove(filepath.Join(dir, "job.wasm"))
+	time.Sleep(100 * time.Millisecond)
+	removed := runTask(t, pool, repo, pluginTask("after-remove", "job", `0`), "failed")
+	if errs := removed.Snapshot().AttemptErrors; len(errs) == 0 || !strings.Contains(errs[0].Error, "not loaded") {
+		t.Errorf("Expected removed plugin error, got %+v", errs)
+	}
+	if len(plugins.Types()) != 0 {
+		t.Errorf("Expected no plugins, got %v", plugins.Types())
+	}
+}
+
+func TestPlugins_EnforcesTimeAndMemoryLimits(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "loop", loopPlugin(), time.Now())
+	writePlugin(t, dir, "grow", growPlugin(), time.Now())
+	_, pool, repo := newPluginPool(t, handlers.PluginConfig{
+		Dir:              dir,
+		Timeout:          100 * time.Millisecond,
+		MemoryLimitBytes: 4 * 64 << 10,
+	})
+
+	started := time.Now()
+	looped := runTask(t, pool, repo, pluginTask("plugin-loop", "loop", `x`), "failed")
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("Expected infinite loop to be stopped, took %v", elapsed)
+	}
+	if errs := looped.Snapshot().AttemptErrors; len(errs) == 0 || !strings.Contains(errs[0].Error, "timed out") {
+		t.Errorf("Expected timeout error, got %+v", errs)
+	}
+
+	grown := runTask(t, pool, repo, pluginTask("plugin-grow", "grow", `x`), "failed")
+	if errs := grown.Snapshot().AttemptErrors; len(errs) == 0 || !strings.Contains(errs[0].Error, "returned code 1") {
+		t.Errorf("Expected memory.grow beyond limit to fail, got %+v", errs)
+	}
+}
+
+func TestPlugins_RejectsModulesWithoutABI(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "bad", buildPlugin(nil, []wasmFunc{allocAt1024}), time.Now())
+	writePlugin(t, dir, "good", echoPlugin(), time.Now())
+	// Встроенные типы не заменяются модулями
+	writePlugin(t, dir, handlers.ExecTaskType, echoPlugin(), time.Now())
+	writePlugin(t, dir, handlers.HTTPTaskType, echoPlugin(), time.Now())
+	plugins, pool, repo := newPluginPool(t, handlers.PluginConfig{Dir: dir})
+	if types := plugins.Types(); len(types) != 1 || types[0] != "good" {
+		t.Fatalf("Expected only the good plugin to load, got %v", types)
+	}
+	runTask(t, pool, repo, pluginTask("good-task", "good", `0`), "done")
+	if err := plugins.Reload(); err != nil {
+		t.Errorf("Expected module errors not to fail reload, got %v", err)
+	}
+
+	missing := repository.NewInMemoryTaskRepository()
+	if _, err := handlers.NewPlugins(handlers.PluginConfig{Dir: filepath.Join(dir, "missing")},
+		queue.NewWorkerPool(1, 5, missing)); err == nil {
+		t.Error("Expected unreadable plugins dir to fail")
+	}
+}
@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/queue"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoison_RepeatedPanicsQuarantineTask(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 5, repo,
+		queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}, nil),
+		queue.WithPoisonThreshold(2),
+	)
+
+	var healthy atomic.Bool
+	pool.RegisterHandler("poison", func(ctx context.Context, task *model.Task) error {
+		if !healthy.Load() {
+			panic("corrupted payload")
+		}
+		return nil
+	})
+
+	pool.Start()
+	defer pool.Shutdown()
+
+	task := &model.Task{ID: "poison-task", Type: "poison", Queue: model.DefaultQueue, MaxRetries: 5, CreatedAt: time.Now()}
+	repo.Create(task)
+	pool.Enqueue(task)
+
+	waitForStatus(t, task, "quarantined")
+
+	snapshot := task.Snapshot()
+	if snapshot.Panics != 2 || snapshot.Retries != 2 {
+		t.Errorf("Expected 2 panics and 2 retries, got %d and %d", snapshot.Panics, snapshot.Retries)
+	}
+	if len(snapshot.AttemptErrors) != 2 {
+		t.Fatalf("Expected 2 attempt errors, got %d", len(snapshot.AttemptErrors))
+	}
+	attemptError := snapshot.AttemptErrors[1]
+	if attemptError.Attempt != 2 || !strings.Contains(attemptError.Error, "corrupted payload") ||
+		!strings.Contains(attemptError.Stack, "goroutine") {
+		t.Errorf("Expected panic with stack recorded, got %+v", attemptError)
+	}
+
+	// Без ручного освобождения задача остается в карантине
+	time.Sleep(50 * time.Millisecond)
+	if status := task.GetStatus(); status != "quarantined" {
+		t.Fatalf("Expected task to stay quarantined, got %s", status)
+	}
+
+	healthy.Store(true)
+	if err := pool.Release(task); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	waitForStatus(t, task, "done")
+	if task.Snapshot().Panics != 0 {
+		t.Error("Expected panic counter to be reset on release")
+	}
+
+	if err := pool.Release(task); err != queue.ErrTaskNotQuarantined {
+		t.Errorf("Expected ErrTaskNotQuarantined, got %v", err)
+	}
+}
+
+func TestPoison_CrashAcrossRestartsQuarantinesTask(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	// Задача уже уронила воркер до прошлого рестарта и снова осталась в running
+	task := &model.Task{ID: "crash-task", Type: "crash", Queue: model.DefaultQueue, MaxRetries: 5,
+		Status: "running", Crashes: 1, CreatedAt: time.Now()}
+	repo.Create(task)
+
+	pool := queue.NewWorkerPool(1, 5, repo, queue.WithPoisonThreshold(2))
+	pool.Start()
+	defer pool.Shutdown()
+
+	waitForStatus(t, task, "quarantined")
+	snapshot := task.Snapshot()
+	if snapshot.Crashes != 2 || len(snapshot.AttemptErrors) != 1 {
+		t.Errorf("Expected 2 crashes and one attempt error, got %+v", snapshot)
+	}
+}
+
+func TestPoison_ThresholdZeroDisablesQuarantine(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 5, repo,
+		queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}, nil),
+		queue.WithPoisonThreshold(0),
+	)
+	pool.RegisterHandler("poison", func(ctx context.Context, task *model.Task) error {
+		panic("boom")
+	})
+
+	pool.Start()
+	defer pool.Shutdown()
+
+	task := &model.Task{ID: "no-quarantine", Type: "poison", Queue: model.DefaultQueue, MaxRetries: 3, CreatedAt: time.Now()}
+	repo.Create(task)
+	pool.Enqueue(task)
+
+	waitForStatus(t, task, "failed")
+	if panics := task.Snapshot().Panics; panics != 3 {
+		t.Errorf("Expected 3 panics, got %d", panics)
+	}
+}
+
+func TestPoison_CountersNotDecodedFromRequest(t *testing.T) {
+	var task model.Task
+	body := `{"id":"t1","panics":5,"crashes":5,"attempt_errors":[{"attempt":1,"error":"fake"}]}`
+	if err := json.Unmarshal([]byte(body), &task); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	snapshot := task.Snapshot()
+	if snapshot.Panics != 0 || snapshot.Crashes != 0 || len(snapshot.AttemptErrors) != 0 {
+		t.Errorf("Expected error history to be ignored in request, got %+v", snapshot)
+	}
+}
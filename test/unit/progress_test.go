@@ -0,0 +1,96 @@
+package unit
+
+import (
+	"TaskQueue/internal/events"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/queue"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProgress_CheckpointSurvivesRetry(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 5, repo,
+		queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}, nil),
+	)
+
+	resumedFrom := make(chan int, 1)
+	pool.RegisterHandler("batch", func(ctx context.Context, task *model.Task) error {
+		reporter := queue.ReporterFromContext(ctx)
+
+		var state struct{ Offset int }
+		if last := reporter.LastCheckpoint(); last != nil {
+			json.Unmarshal(last, &state)
+			resumedFrom <- state.Offset
+			reporter.Progress(100, "done")
+			return nil
+		}
+
+		reporter.Progress(50, "half way")
+		state.Offset = 500
+		if err := reporter.Checkpoint(state); err != nil {
+			return err
+		}
+		return errors.New("interrupted")
+	})
+
+	stream, unsubscribe := pool.Subscribe(32)
+	defer unsubscribe()
+
+	pool.Start()
+	defer pool.Shutdown()
+
+	task := &model.Task{ID: "progress-task", Type: "batch", Queue: model.DefaultQueue, MaxRetries: 3, CreatedAt: time.Now()}
+	repo.Create(task)
+	pool.Enqueue(task)
+
+	select {
+	case offset := <-resumedFrom:
+		if offset != 500 {
+			t.Errorf("Expected retry to resume from offset 500, got %d", offset)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected handler to be retried with checkpoint")
+	}
+
+	var progressEvents []events.Event
+	deadline := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case event := <-stream:
+			if event.Type == events.TypeProgress {
+				progressEvents = append(progressEvents, event)
+			}
+			done = event.Type == events.TypeStatus && event.Status == "done"
+		case <-deadline:
+			t.Fatal("Expected done status event")
+		}
+	}
+
+	if len(progressEvents) != 2 || progressEvents[0].Progress.Percent != 50 || progressEvents[0].Attempt != 1 {
+		t.Errorf("Unexpected progress events: %+v", progressEvents)
+	}
+
+	snapshot := task.Snapshot()
+	if snapshot.Progress == nil || snapshot.Progress.Percent != 100 || snapshot.Progress.Message != "done" {
+		t.Errorf("Expected final progress on snapshot, got %+v", snapshot.Progress)
+	}
+	if string(snapshot.Checkpoint) != `{"Offset":500}` {
+		t.Errorf("Expected checkpoint on snapshot, got %s", snapshot.Checkpoint)
+	}
+}
+
+func TestProgress_ReporterOutsidePool(t *testing.T) {
+	reporter := queue.ReporterFromContext(context.Background())
+	reporter.Progress(10, "ignored")
+	if err := reporter.Checkpoint(map[string]int{"a": 1}); err != nil {
+		t.Errorf("Expected no-op checkpoint, got %v", err)
+	}
+	if reporter.LastCheckpoint() != nil {
+		t.Error("Expected no checkpoint outside pool")
+	}
+}
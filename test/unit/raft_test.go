@@ -0,0 +1,336 @@
+package unit
+
+import (
+	"TaskQueue/internal/raft"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type recordingFSM struct {
+	mu       sync.Mutex
+	commands []string
+	leader   bool
+}
+
+func (f *recordingFSM) Apply(entry raft.Entry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, string(entry.Command))
+}
+
+func (f *recordingFSM) LeadershipChanged(leader bool, term int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.leader = leader
+}
+
+func (f *recordingFSM) Snapshot() (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return json.Marshal(f.commands)
+}
+
+func (f *recordingFSM) Restore(snapshot json.RawMessage) error {
+	var commands []string
+	if err := json.Unmarshal(snapshot, &commands); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = commands
+	return nil
+}
+
+func (f *recordingFSM) applied() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+type raftMember struct {
+	node    *raft.Node
+	fsm     *recordingFSM
+	server  *httptest.Server
+	stopped bool
+	// Отрезан от сети: на RPC отвечает 503
+	isolated atomic.Bool
+}
+
+func (m *raftMember) stop() {
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	m.server.Close()
+	m.node.Stop()
+}
+
+// startRaftCluster поднимает узлы на loopback; dataDirs может быть nil
+func startRaftCluster(t *testing.T, size int, dataDirs []string) []*raftMember {
+	return startRaftClusterWithSnapshots(t, size, dataDirs, 0)
+}
+
+func startRaftClusterWithSnapshots(t *testing.T, size int, dataDirs []string, snapshotEntries int) []*raftMember {
+	members := make([]*raftMember, size)
+	servers := make([]*httptest.Server, size)
+	handlers := make([]http.Handler, size)
+	peers := make(map[string]string)
+	for i := range servers {
+		i := i
+		servers[i] = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if members[i].isolated.Load() {
+				http.Error(w, "isolated", http.StatusServiceUnavailable)
+				return
+			}
+			handlers[i].ServeHTTP(w, r)
+		}))
+		peers[fmt.Sprintf("n%d", i+1)] = "http://" + servers[i].Listener.Addr().String()
+	}
+
+	for i := range members {
+		cfg := raft.Config{
+			ID:                fmt.Sprintf("n%d", i+1),
+			Peers:             peers,
+			ElectionTimeout:   150 * time.Millisecond,
+			HeartbeatInterval: 30 * time.Millisecond,
+			Secret:            "cluster-secret",
+			SnapshotEntries:   snapshotEntries,
+		}
+		if dataDirs != nil {
+			cfg.DataDir = dataDirs[i]
+		}
+		node, err := raft.NewNode(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		handlers[i] = node.Handler()
+		members[i] = &raftMember{node: node, fsm: &recordingFSM{}, server: servers[i]}
+	}
+	for _, m := range members {
+		m.server.Start()
+		m.node.Start(m.fsm)
+	}
+	t.Cleanup(func() {
+		for _, m := range members {
+			m.stop()
+		}
+	})
+	return members
+}
+
+func waitForRaftLeader(t *testing.T, members []*raftMember) *raftMember {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var leaders []*raftMember
+		for _, m := range members {
+			if !m.stopped && m.node.IsLeader() {
+				leaders = append(leaders, m)
+			}
+		}
+		if len(leaders) == 1 {
+			return leaders[0]
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Expected a single raft leader")
+	return nil
+}
+
+func waitForApplied(t *testing.T, m *raftMember, count int) []string {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if applied := m.fsm.applied(); len(applied) >= count {
+			return applied
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d applied entries on %s, got %v", count, m.node.ID(), m.fsm.applied())
+	return nil
+}
+
+func proposeWithRetry(t *testing.T, members []*raftMember, command string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		leader := waitForRaftLeader(t, members)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := leader.node.Propose(ctx, []byte(command))
+		cancel()
+		if err == nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Failed to commit %s", command)
+}
+
+func TestRaft_ReplicatesInOrder(t *testing.T) {
+	members := startRaftCluster(t, 3, nil)
+	leader := waitForRaftLeader(t, members)
+
+	var expected []string
+	for i := 0; i < 5; i++ {
+		command := fmt.Sprintf(`{"n":%d}`, i)
+		expected = append(expected, command)
+		proposeWithRetry(t, members, command)
+	}
+
+	for _, m := range members {
+		applied := waitForApplied(t, m, len(expected))
+		for i := range expected {
+			if applied[i] != expected[i] {
+				t.Fatalf("Expected %v on %s, got %v", expected, m.node.ID(), applied)
+			}
+		}
+	}
+
+	for _, m := range members {
+		if m == leader {
+			continue
+		}
+		if err := m.node.Propose(context.Background(), []byte(`{}`)); !errors.Is(err, raft.ErrNotLeader) {
+			t.Errorf("Expected ErrNotLeader from follower, got %v", err)
+		}
+		if id, _ := m.node.Leader(); id != leader.node.ID() {
+			t.Errorf("Expected follower to know leader %s, got %q", leader.node.ID(), id)
+		}
+	}
+}
+
+func TestRaft_Failover(t *testing.T) {
+	members := startRaftCluster(t, 3, nil)
+	proposeWithRetry(t, members, `"before"`)
+
+	old := waitForRaftLeader(t, members)
+	old.stop()
+
+	leader := waitForRaftLeader(t, members)
+	if leader == old {
+		t.Fatal("Expected a new leader after failover")
+	}
+	proposeWithRetry(t, members, `"after"`)
+
+	for _, m := range members {
+		if m.stopped {
+			continue
+		}
+		applied := waitForApplied(t, m, 2)
+		if applied[0] != `"before"` || applied[len(applied)-1] != `"after"` {
+			t.Errorf("Expected entries from both terms on %s, got %v", m.node.ID(), applied)
+		}
+	}
+
+	leader.fsm.mu.Lock()
+	defer leader.fsm.mu.Unlock()
+	if !leader.fsm.leader {
+		t.Error("Expected FSM of the new leader to be notified")
+	}
+}
+
+func TestRaft_RejectsWrongSecret(t *testing.T) {
+	members := startRaftCluster(t, 3, nil)
+
+	resp, err := http.Post(members[0].server.URL+"/raft/vote", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without raft secret, got %d", resp.StatusCode)
+	}
+}
+
+func TestRaft_RestoresLogFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	members := startRaftCluster(t, 1, []string{dir})
+	proposeWithRetry(t, members, `"persisted"`)
+	members[0].stop()
+
+	restarted := startRaftCluster(t, 1, []string{dir})
+	waitForRaftLeader(t, restarted)
+	applied := waitForApplied(t, restarted[0], 1)
+	if applied[0] != `"persisted"` {
+		t.Errorf("Expected persisted entry after restart, got %v", applied)
+	}
+}
+
+func waitForSnapshot(t *testing.T, m *raftMember) int64 {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if index := m.node.Status().SnapshotIndex; index > 0 {
+			return index
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected a snapshot on %s", m.node.ID())
+	return 0
+}
+
+func TestRaft_CompactsLogAndSendsSnapshotToLaggingFollower(t *testing.T) {
+	members := startRaftClusterWithSnapshots(t, 3, nil, 4)
+	leader := waitForRaftLeader(t, members)
+	var lagging *raftMember
+	for _, m := range members {
+		if m != leader {
+			lagging = m
+			break
+		}
+	}
+	lagging.isolated.Store(true)
+
+	var expected []string
+	for i := 0; i < 12; i++ {
+		command := fmt.Sprintf(`{"n":%d}`, i)
+		expected = append(expected, command)
+		proposeWithRetry(t, members, command)
+	}
+	leader = waitForRaftLeader(t, members)
+	snapshotIndex := waitForSnapshot(t, leader)
+	deadline := time.Now().Add(5 * time.Second)
+	for status := leader.node.Status(); status.LastIndex-status.SnapshotIndex >= 4; status = leader.node.Status() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected compacted log on leader, got %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lagging.isolated.Store(false)
+	applied := waitForApplied(t, lagging, len(expected))
+	if !slices.Equal(applied, expected) {
+		t.Errorf("Expected %v on lagging follower, got %v", expected, applied)
+	}
+	if index := lagging.node.Status().SnapshotIndex; index < snapshotIndex {
+		t.Errorf("Expected follower to install snapshot %d, got %d", snapshotIndex, index)
+	}
+}
+
+func TestRaft_RestoresSnapshotFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	members := startRaftClusterWithSnapshots(t, 1, []string{dir}, 2)
+	var expected []string
+	for i := 0; i < 5; i++ {
+		command := fmt.Sprintf(`"e%d"`, i)
+		expected = append(expected, command)
+		proposeWithRetry(t, members, command)
+	}
+	waitForSnapshot(t, members[0])
+	members[0].stop()
+
+	restarted := startRaftClusterWithSnapshots(t, 1, []string{dir}, 2)
+	if restarted[0].node.Status().SnapshotIndex == 0 {
+		t.Error("Expected snapshot to be loaded from disk")
+	}
+	waitForRaftLeader(t, restarted)
+	applied := waitForApplied(t, restarted[0], len(expected))
+	if !slices.Equal(applied, expected) {
+		t.Errorf("Expected %v after restart, got %v", expected, applied)
+	}
+}
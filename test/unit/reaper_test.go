@@ -0,0 +1,99 @@
+package unit
+
+import (
+	"TaskQueue/internal/metrics"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/queue"
+	"bytes"
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReaper_RequeuesStuckTask(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(2, 5, repo,
+		queue.WithVisibilityTimeout(100*time.Millisecond),
+		queue.WithReapInterval(20*time.Millisecond),
+		queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}, nil),
+	)
+
+	var calls int32
+	cancelled := make(chan struct{})
+	pool.RegisterHandler("stuck", func(ctx context.Context, task *model.Task) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// Первая попытка зависает до отмены reaper'ом
+			<-ctx.Done()
+			close(cancelled)
+		}
+		return nil
+	})
+	pool.Start()
+	defer pool.Shutdown()
+
+	task := &model.Task{ID: "stuck-task", Type: "stuck", Queue: "reaper-test", MaxRetries: 3, CreatedAt: time.Now()}
+	repo.Create(task)
+	pool.Enqueue(task)
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected stuck attempt to be cancelled")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if task.GetStatus() != "done" {
+		t.Errorf("Expected reaped task to complete on retry, got %s", task.GetStatus())
+	}
+	if task.GetRetries() != 1 {
+		t.Errorf("Expected expired lease to count as an attempt, got %d retries", task.GetRetries())
+	}
+
+	var out bytes.Buffer
+	metrics.Default.Write(&out)
+	if !strings.Contains(out.String(), `taskqueue_reaper_requeued_total{queue="reaper-test",type="stuck"} 1`) {
+		t.Errorf("Expected reaper metric, got:\n%s", out.String())
+	}
+}
+
+func TestReaper_RecoversRunningTasksOnStart(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	task := &model.Task{ID: "orphan", Type: "orphan", Queue: model.DefaultQueue, MaxRetries: 1, Status: "running", CreatedAt: time.Now()}
+	repo.Create(task)
+
+	pool := queue.NewWorkerPool(1, 5, repo)
+	pool.Start()
+	defer pool.Shutdown()
+
+	if task.GetStatus() != "failed" || task.GetRetries() != 1 {
+		t.Errorf("Expected orphaned running task to fail after its only attempt, got %s after %d", task.GetStatus(), task.GetRetries())
+	}
+}
+
+func TestMetrics_TextFormat(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.Counter("test_events_total", "Events.", "kind")
+	counter.Inc("a")
+	counter.Add(2, `b"q`)
+	registry.Gauge("test_level", "Level.").Set(1.5)
+	registry.GaugeFunc("test_func", "Func.", func() float64 { return 7 })
+
+	var out bytes.Buffer
+	registry.Write(&out)
+	expected := []string{
+		"# TYPE test_events_total counter",
+		`test_events_total{kind="a"} 1`,
+		`test_events_total{kind="b\"q"} 2`,
+		"# TYPE test_level gauge",
+		"test_level 1.5",
+		"test_func 7",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, out.String())
+		}
+	}
+}
@@ -2,15 +2,18 @@ package unit
 
 import (
 	"TaskQueue/internal/model"
+	"TaskQueue/internal/raft"
 	"TaskQueue/internal/repository"
+	"encoding/json"
 	"testing"
+	"time"
 )
 
 func TestRepository_InMemoryTaskRepository(t *testing.T) {
 	repo := repository.NewInMemoryTaskRepository()
 	task := &model.Task{
 		ID:         "test1",
-		Payload:    "test payload",
+		Payload:    json.RawMessage(`"test payload"`),
 		MaxRetries: 3,
 	}
 
@@ -45,3 +48,68 @@ func TestRepository_UpdateNonExistentTask(t *testing.T) {
 		t.Error("Expected error when updating non-existent task")
 	}
 }
+
+func replicatedEntry(t *testing.T, index int64, command map[string]interface{}) raft.Entry {
+	data, err := json.Marshal(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raft.Entry{Index: index, Term: 1, Command: data}
+}
+
+func TestRepository_ReplicatedSnapshotRestoresCommittedTasks(t *testing.T) {
+	repo := repository.NewReplicatedTaskRepository(nil)
+	keep := (&model.Task{ID: "keep", Payload: json.RawMessage(`"a"`), MaxRetries: 2}).Snapshot()
+	gone := (&model.Task{ID: "gone", Payload: json.RawMessage(`"b"`)}).Snapshot()
+	repo.Apply(replicatedEntry(t, 1, map[string]interface{}{"op": "create", "id": "keep", "task": keep}))
+	repo.Apply(replicatedEntry(t, 2, map[string]interface{}{"op": "create", "id": "gone", "task": gone}))
+	repo.Apply(replicatedEntry(t, 3, map[string]interface{}{"op": "delete", "id": "gone"}))
+
+	snapshot, err := repo.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+
+	restored := repository.NewReplicatedTaskRepository(nil)
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+	if restored.Exists("gone") {
+		t.Error("Expected deleted task to stay deleted after restore")
+	}
+	task, exists := restored.GetByID("keep")
+	if !exists {
+		t.Fatal("Expected task from snapshot")
+	}
+	if string(task.Payload) != `"a"` || task.MaxRetries != 2 {
+		t.Errorf("Expected restored task to match snapshot, got %+v", task.Snapshot())
+	}
+}
+
+func TestRepository_ReplicatedConditionalDeleteKeepsRestartedTask(t *testing.T) {
+	repo := repository.NewReplicatedTaskRepository(nil)
+	finished := &model.Task{ID: "job"}
+	finished.SetStatus("done")
+	finishedAt := finished.GetFinishedAt()
+	restarted := finished.Snapshot()
+	restarted.Status = "queued"
+	restarted.FinishedAt = nil
+
+	repo.Apply(replicatedEntry(t, 1, map[string]interface{}{"op": "create", "id": "job", "task": finished.Snapshot()}))
+	repo.Apply(replicatedEntry(t, 2, map[string]interface{}{"op": "update", "id": "job", "task": restarted}))
+	repo.Apply(replicatedEntry(t, 3, map[string]interface{}{"op": "delete", "id": "job", "finished_at": finishedAt}))
+	if !repo.Exists("job") {
+		t.Fatal("Expected restarted task to survive delete of its old finish")
+	}
+
+	finished.SetStatus("failed")
+	repo.Apply(replicatedEntry(t, 4, map[string]interface{}{"op": "update", "id": "job", "task": finished.Snapshot()}))
+	repo.Apply(replicatedEntry(t, 5, map[string]interface{}{"op": "delete", "id": "job", "finished_at": finished.GetFinishedAt().Add(-time.Second)}))
+	if !repo.Exists("job") {
+		t.Fatal("Expected task finished again to survive delete of its old finish")
+	}
+	repo.Apply(replicatedEntry(t, 6, map[string]interface{}{"op": "delete", "id": "job", "finished_at": finished.GetFinishedAt()}))
+	if repo.Exists("job") {
+		t.Error("Expected task to be deleted at its finish time")
+	}
+}
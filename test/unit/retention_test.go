@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetention_TTLPerStatus(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	for _, task := range []*model.Task{
+		{ID: "done", Status: "done"},
+		{ID: "failed", Status: "failed"},
+		{ID: "queued", Status: "queued"},
+	} {
+		task.SetStatus(task.Status)
+		repo.Create(task)
+	}
+
+	collector := repository.NewCollector(repo, repository.RetentionPolicy{
+		TTL: map[string]time.Duration{"done": time.Hour, "failed": 24 * time.Hour},
+	})
+
+	evicted, err := collector.Collect(time.Now().Add(2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evicted != 1 || repo.Exists("done") || !repo.Exists("failed") || !repo.Exists("queued") {
+		t.Errorf("Expected only the done task to be evicted, evicted %d", evicted)
+	}
+
+	collector.Collect(time.Now().Add(48 * time.Hour))
+	if repo.Exists("failed") || !repo.Exists("queued") {
+		t.Error("Expected failed task evicted and queued task kept")
+	}
+}
+
+func TestRetention_MaxTasksAndArchive(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	for _, id := range []string{"old", "mid", "new"} {
+		task := &model.Task{ID: id, Payload: json.RawMessage(`"payload-` + id + `"`)}
+		task.SetStatus("done")
+		repo.Create(task)
+		time.Sleep(2 * time.Millisecond)
+	}
+	running := &model.Task{ID: "running", Status: "running"}
+	repo.Create(running)
+
+	dir := t.TempDir()
+	collector := repository.NewCollector(repo, repository.RetentionPolicy{MaxTasks: 2, ArchiveDir: dir})
+
+	evicted, err := collector.Collect(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evicted != 2 || repo.Exists("old") || repo.Exists("mid") || !repo.Exists("new") || !repo.Exists("running") {
+		t.Errorf("Expected two oldest finished tasks evicted, evicted %d", evicted)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl.gz"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one archive file, got %d", len(files))
+	}
+	f, _ := os.Open(files[0])
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var snapshot model.TaskSnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		if string(snapshot.Payload) != `"payload-`+snapshot.ID+`"` || snapshot.FinishedAt == nil {
+			t.Errorf("Unexpected archived record: %+v", snapshot)
+		}
+		ids = append(ids, snapshot.ID)
+	}
+	if len(ids) != 2 {
+		t.Errorf("Expected 2 archived records, got %v", ids)
+	}
+}
+
+// restartingRepo перезапускает задачу сразу после выборки коллектором
+type restartingRepo struct {
+	repository.TaskRepository
+	restart string
+}
+
+func (r *restartingRepo) GetAll() map[string]*model.Task {
+	all := r.TaskRepository.GetAll()
+	if task, ok := all[r.restart]; ok {
+		task.SetStatus("queued")
+	}
+	return all
+}
+
+func TestRetention_SkipsTasksRestartedAfterScan(t *testing.T) {
+	inner := repository.NewInMemoryTaskRepository()
+	for _, id := range []string{"stale", "restarted"} {
+		task := &model.Task{ID: id}
+		task.SetStatus("failed")
+		inner.Create(task)
+	}
+	repo := &restartingRepo{TaskRepository: inner, restart: "restarted"}
+
+	collector := repository.NewCollector(repo, repository.RetentionPolicy{
+		TTL: map[string]time.Duration{"failed": time.Hour},
+	})
+	evicted, err := collector.Collect(time.Now().Add(2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evicted != 1 || inner.Exists("stale") || !inner.Exists("restarted") {
+		t.Errorf("Expected only the stale task to be evicted, evicted %d", evicted)
+	}
+
+	task, _ := inner.GetByID("restarted")
+	finishedAt := time.Now()
+	task.SetStatus("done")
+	if inner.DeleteFinished("restarted", finishedAt.Add(-time.Hour)) {
+		t.Error("Expected task finished again to be kept")
+	}
+	if !inner.DeleteFinished("restarted", task.GetFinishedAt()) || inner.Exists("restarted") {
+		t.Error("Expected task to be deleted at its finish time")
+	}
+}
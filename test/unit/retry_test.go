@@ -0,0 +1,178 @@
+package unit
+
+import (
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/queue"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_NextDelay(t *testing.T) {
+	second := model.Duration(time.Second)
+
+	fixed := model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: second}
+	if d := fixed.NextDelay(5, 0); d != time.Second {
+		t.Errorf("Expected fixed delay 1s, got %v", d)
+	}
+
+	linear := model.RetryPolicy{Strategy: model.RetryLinear, BaseDelay: second, MaxDelay: model.Duration(3 * time.Second)}
+	if d := linear.NextDelay(2, 0); d != 2*time.Second {
+		t.Errorf("Expected linear delay 2s, got %v", d)
+	}
+	if d := linear.NextDelay(10, 0); d != 3*time.Second {
+		t.Errorf("Expected linear delay capped at 3s, got %v", d)
+	}
+
+	exponential := model.RetryPolicy{Strategy: model.RetryExponential, BaseDelay: second}
+	if d := exponential.NextDelay(2, 0); d < 4*time.Second || d >= 6*time.Second {
+		t.Errorf("Expected exponential delay in [4s, 6s), got %v", d)
+	}
+
+	decorrelated := model.RetryPolicy{Strategy: model.RetryDecorrelatedJitter, BaseDelay: second, MaxDelay: model.Duration(time.Minute)}
+	for i := 0; i < 100; i++ {
+		if d := decorrelated.NextDelay(1, 10*time.Second); d < time.Second || d > 30*time.Second {
+			t.Fatalf("Expected decorrelated delay in [1s, 30s], got %v", d)
+		}
+	}
+}
+
+func TestRetryPolicy_NextDelaySaturatesLargeBase(t *testing.T) {
+	huge := model.Duration(100 * 365 * 24 * time.Hour)
+	for _, strategy := range []model.RetryStrategy{model.RetryExponential, model.RetryLinear, model.RetryDecorrelatedJitter} {
+		unbounded := model.RetryPolicy{Strategy: strategy, BaseDelay: huge}
+		for _, retries := range []int{1, 10, 30, 100} {
+			if d := unbounded.NextDelay(retries, time.Duration(huge)); d < time.Duration(huge) {
+				t.Errorf("Expected %s delay after %d retries to saturate, got %v", strategy, retries, d)
+			}
+		}
+
+		capped := model.RetryPolicy{Strategy: strategy, BaseDelay: huge / 1000, MaxDelay: model.Duration(time.Hour)}
+		if d := capped.NextDelay(30, 0); d != time.Hour {
+			t.Errorf("Expected %s delay capped at 1h, got %v", strategy, d)
+		}
+	}
+}
+
+func TestRetryPolicy_Parse(t *testing.T) {
+	policy, err := model.ParseRetryPolicy("linear:2s:1m:1h")
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	if policy.Strategy != model.RetryLinear || policy.BaseDelay != model.Duration(2*time.Second) ||
+		policy.MaxDelay != model.Duration(time.Minute) || policy.MaxElapsed != model.Duration(time.Hour) {
+		t.Errorf("Unexpected policy: %+v", policy)
+	}
+
+	for _, spec := range []string{"random:1s", "fixed", "fixed:-1s", "fixed:10s:1s"} {
+		if _, err := model.ParseRetryPolicy(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestRetryPolicy_JSON(t *testing.T) {
+	var task model.Task
+	body := `{"id":"t","retry_policy":{"strategy":"fixed","base_delay":"500ms","max_elapsed":30}}`
+	if err := json.Unmarshal([]byte(body), &task); err != nil {
+		t.Fatalf("Failed to decode task: %v", err)
+	}
+	if task.RetryPolicy.BaseDelay != model.Duration(500*time.Millisecond) ||
+		task.RetryPolicy.MaxElapsed != model.Duration(30*time.Second) {
+		t.Errorf("Unexpected policy: %+v", task.RetryPolicy)
+	}
+}
+
+func TestController_EnqueueHandler_InvalidRetryPolicy(t *testing.T) {
+	controller := controller.NewHTTPController(&MockQueueService{})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"id": "test1", "payload": "data", "max_retries": 3,
+		"retry_policy": map[string]interface{}{"strategy": "random", "base_delay": "1s"},
+	})
+	req := httptest.NewRequest("POST", "/enqueue", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	controller.EnqueueHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid retry policy, got %d", w.Code)
+	}
+}
+
+func TestWorkerPool_RetryPolicies(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(2, 5, repo, queue.WithRetryPolicies(
+		model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(time.Hour)},
+		map[string]model.RetryPolicy{
+			"fast": {Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)},
+		},
+	))
+	pool.RegisterHandler("failing", func(ctx context.Context, task *model.Task) error {
+		return errors.New("always fails")
+	})
+	pool.Start()
+	defer pool.Shutdown()
+
+	queueTask := &model.Task{ID: "queue-policy", Type: "failing", Queue: "fast", MaxRetries: 3, CreatedAt: time.Now()}
+	taskTask := &model.Task{ID: "task-policy", Type: "failing", Queue: model.DefaultQueue, MaxRetries: 3, CreatedAt: time.Now(),
+		RetryPolicy: &model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}}
+	elapsedTask := &model.Task{ID: "elapsed-policy", Type: "failing", Queue: model.DefaultQueue, MaxRetries: 10, CreatedAt: time.Now(),
+		RetryPolicy: &model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(time.Second), MaxElapsed: model.Duration(time.Second)}}
+
+	for _, task := range []*model.Task{queueTask, taskTask, elapsedTask} {
+		repo.Create(task)
+		pool.Enqueue(task)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	for _, task := range []*model.Task{queueTask, taskTask} {
+		if task.GetStatus() != "failed" || task.GetRetries() != 3 {
+			t.Errorf("Task %s: expected failed after 3 retries, got %s after %d", task.ID, task.GetStatus(), task.GetRetries())
+		}
+	}
+	if elapsedTask.GetStatus() != "failed" || elapsedTask.GetRetries() != 1 {
+		t.Errorf("Expected max_elapsed to stop retries, got %s after %d", elapsedTask.GetStatus(), elapsedTask.GetRetries())
+	}
+}
+
+func TestWorkerPool_PermanentAndRetryAfterErrors(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(2, 5, repo, queue.WithRetryPolicies(
+		model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(time.Hour)}, nil,
+	))
+	pool.RegisterHandler("permanent", func(ctx context.Context, task *model.Task) error {
+		return queue.Permanent(errors.New("bad input"))
+	})
+	pool.RegisterHandler("throttled", func(ctx context.Context, task *model.Task) error {
+		if task.GetRetries() == 0 {
+			return queue.RetryAfter(errors.New("slow down"), 50*time.Millisecond)
+		}
+		return nil
+	})
+	pool.Start()
+	defer pool.Shutdown()
+
+	permanentTask := &model.Task{ID: "permanent-task", Type: "permanent", Queue: model.DefaultQueue, MaxRetries: 5, CreatedAt: time.Now()}
+	throttledTask := &model.Task{ID: "throttled-task", Type: "throttled", Queue: model.DefaultQueue, MaxRetries: 5, CreatedAt: time.Now()}
+	for _, task := range []*model.Task{permanentTask, throttledTask} {
+		repo.Create(task)
+		pool.Enqueue(task)
+	}
+
+	waitForStatus(t, permanentTask, "failed")
+	if permanentTask.GetRetries() != 1 {
+		t.Errorf("Expected permanent error to stop retries, got %d attempts", permanentTask.GetRetries())
+	}
+	// Задержка из RetryAfter заменяет часовую задержку политики
+	waitForStatus(t, throttledTask, "done")
+	if throttledTask.LastRetryDelay != 50*time.Millisecond {
+		t.Errorf("Expected retry after 50ms, got %v", throttledTask.LastRetryDelay)
+	}
+}
@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"TaskQueue/internal/schema"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func validationErrors(t *testing.T, err error) []schema.FieldError {
+	t.Helper()
+	var schemaErr *schema.ValidationError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("Expected validation error, got %v", err)
+	}
+	return schemaErr.Errors
+}
+
+func TestSchemaRegistry_ReportsFieldErrors(t *testing.T) {
+	registry := schema.NewRegistry()
+	err := registry.Register("order", json.RawMessage(`{
+		"type": "object",
+		"required": ["id", "a/b", "items"],
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"items": {"type": "array", "items": {"$ref": "#/$defs/item"}},
+			"ref": {"oneOf": [{"type": "string"}, {"type": "integer"}]}
+		},
+		"$defs": {"item": {"type": "object", "required": ["sku"]}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := validationErrors(t, registry.Validate("order", json.RawMessage(`{"id":0,"items":[{"sku":"x"},{}],"ref":true}`)))
+	expected := []schema.FieldError{
+		{Field: "/a~1b", Keyword: "required"},
+		{Field: "/id", Keyword: "minimum"},
+		{Field: "/items/1/sku", Keyword: "required"},
+		{Field: "/ref", Keyword: "oneOf"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d field errors, got %+v", len(expected), errs)
+	}
+	for i, fieldErr := range errs {
+		if fieldErr.Field != expected[i].Field || fieldErr.Keyword != expected[i].Keyword || fieldErr.Message == "" {
+			t.Errorf("Expected %+v, got %+v", expected[i], fieldErr)
+		}
+	}
+
+	if err := registry.Validate("order", json.RawMessage(`{"id":1,"a/b":null,"items":[]}`)); err != nil {
+		t.Errorf("Expected valid payload, got %v", err)
+	}
+	if err := registry.Validate("unknown", json.RawMessage(`"anything"`)); err != nil {
+		t.Errorf("Expected type without schema to accept payload, got %v", err)
+	}
+}
+
+func TestSchemaRegistry_RejectsInvalidAndExternalSchemas(t *testing.T) {
+	registry := schema.NewRegistry()
+	for name, raw := range map[string]string{
+		"json":     `{"type":`,
+		"keyword":  `{"type":"text"}`,
+		"file ref": `{"$ref":"file:///etc/passwd"}`,
+		"http ref": `{"$ref":"http://example.com/schema.json"}`,
+	} {
+		if err := registry.Register("bad", json.RawMessage(raw)); err == nil {
+			t.Errorf("Expected %s schema to be rejected", name)
+		}
+	}
+	if len(registry.Types()) != 0 {
+		t.Errorf("Expected no registered schemas, got %v", registry.Types())
+	}
+}
+
+func TestSchemaRegistry_ReadDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "email.json"), []byte(`{"type":"object"}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(`ignored`), 0o644)
+
+	schemas, err := schema.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 1 || string(schemas["email"]) != `{"type":"object"}` {
+		t.Errorf("Expected only email schema, got %v", schemas)
+	}
+}
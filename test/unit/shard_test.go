@@ -0,0 +1,70 @@
+package unit
+
+import (
+	"TaskQueue/internal/shard"
+	"fmt"
+	"testing"
+)
+
+func TestRing_OwnerIsStableAndBalanced(t *testing.T) {
+	ring := shard.NewRing(64, "n1", "n2", "n3")
+	reordered := shard.NewRing(64, "n3", "n1", "n2")
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("queue-%d", i)
+		owner, ok := ring.Owner(key)
+		if !ok {
+			t.Fatal("Expected owner for non-empty ring")
+		}
+		if other, _ := reordered.Owner(key); other != owner {
+			t.Fatalf("Expected owner not to depend on member order, got %s and %s", owner, other)
+		}
+		counts[owner]++
+	}
+	for _, member := range []string{"n1", "n2", "n3"} {
+		if counts[member] < 600 || counts[member] > 1400 {
+			t.Errorf("Expected roughly even distribution, got %v", counts)
+			break
+		}
+	}
+}
+
+func TestRing_JoinMovesKeysOnlyToNewNode(t *testing.T) {
+	ring := shard.NewRing(64, "n1", "n2", "n3")
+	before := make(map[string]string)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("queue-%d", i)
+		before[key], _ = ring.Owner(key)
+	}
+
+	if !ring.Set([]string{"n1", "n2", "n3", "n4"}) {
+		t.Fatal("Expected ring change")
+	}
+	if ring.Set([]string{"n4", "n3", "n2", "n1"}) {
+		t.Error("Expected no change for the same members")
+	}
+
+	moved := 0
+	for key, previous := range before {
+		owner, _ := ring.Owner(key)
+		if owner != previous {
+			if owner != "n4" {
+				t.Fatalf("Expected key %s to move only to the new node, got %s", key, owner)
+			}
+			moved++
+		}
+	}
+	if moved < 250 || moved > 750 {
+		t.Errorf("Expected about a quarter of keys to move, got %d", moved)
+	}
+}
+
+func TestRing_Empty(t *testing.T) {
+	if _, ok := shard.NewRing(0).Owner("default"); ok {
+		t.Error("Expected no owner in empty ring")
+	}
+	if shard.Key("", "") != "default" || shard.Key("emails", "") != "emails" || shard.Key("emails", "user-1") != "user-1" {
+		t.Error("Expected partition key to override queue")
+	}
+}
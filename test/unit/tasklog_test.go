@@ -0,0 +1,155 @@
+package unit
+
+import (
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/service"
+	"TaskQueue/internal/tasklog"
+	"TaskQueue/queue"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTaskLog_BufferEvictsOldLines(t *testing.T) {
+	store := tasklog.NewStore(2, 10)
+	buffer := store.Attempt("t1", 1)
+	for _, msg := range []string{"a", "b", "c"} {
+		buffer.Append(tasklog.Line{Message: msg})
+	}
+
+	lines, next, closed, _ := buffer.Read(0)
+	if len(lines) != 2 || lines[0].Message != "b" || next != 3 || closed {
+		t.Errorf("Expected last two lines, got %+v next=%d closed=%v", lines, next, closed)
+	}
+	if buffer.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped line, got %d", buffer.Dropped())
+	}
+
+	if _, attempt, ok := store.Get("t1", 0); !ok || attempt != 1 {
+		t.Errorf("Expected latest attempt 1, got %d %v", attempt, ok)
+	}
+	if _, _, ok := store.Get("t1", 2); ok {
+		t.Error("Expected missing attempt 2")
+	}
+}
+
+func TestTaskLog_CapturesAttempts(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 5, repo,
+		queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(10 * time.Millisecond)}, nil),
+	)
+	pool.RegisterHandler("logged", func(ctx context.Context, task *model.Task) error {
+		logger := queue.LoggerFromContext(ctx)
+		if task.GetRetries() == 0 {
+			logger.Info("first try", "rows", 10)
+			return errors.New("boom")
+		}
+		logger.Debug("second try")
+		return nil
+	})
+	pool.Start()
+	defer pool.Shutdown()
+
+	task := &model.Task{ID: "logged-task", Type: "logged", Queue: model.DefaultQueue, MaxRetries: 3, CreatedAt: time.Now()}
+	repo.Create(task)
+	pool.Enqueue(task)
+	waitForStatus(t, task, "done")
+
+	first, _, ok := pool.TaskLogs(task.ID, 1)
+	if !ok {
+		t.Fatal("Expected logs for attempt 1")
+	}
+	lines, _, closed, _ := first.Read(0)
+	if !closed || !hasLine(lines, "first try") || !hasLine(lines, "Task failed, scheduling retry") {
+		t.Errorf("Unexpected attempt 1 logs (closed=%v): %+v", closed, lines)
+	}
+	for _, line := range lines {
+		if line.Message == "first try" && (line.Attrs["rows"] != int64(10) || line.Attrs["task_id"] != task.ID) {
+			t.Errorf("Expected task fields and attrs, got %+v", line.Attrs)
+		}
+	}
+
+	latest, attempt, _ := pool.TaskLogs(task.ID, 0)
+	lines, _, _, _ = latest.Read(0)
+	if attempt != 2 || !hasLine(lines, "second try") || hasLine(lines, "first try") {
+		t.Errorf("Unexpected attempt %d logs: %+v", attempt, lines)
+	}
+}
+
+func TestTaskLog_FollowStreamsUntilAttemptEnds(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	queueService := service.NewQueueService(repo, 1, 5)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	queueService.RegisterHandler("slow", func(ctx context.Context, task *model.Task) error {
+		logger := queue.LoggerFromContext(ctx)
+		logger.Info("step 1")
+		close(started)
+		<-release
+		logger.Info("step 2")
+		return nil
+	})
+	queueService.StartWorkers()
+	defer queueService.Shutdown()
+
+	ctl := controller.NewHTTPController(queueService)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks/{id}/logs", ctl.LogsHandler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	queueService.Enqueue(&model.Task{ID: "follow", Type: "slow", Payload: json.RawMessage(`"x"`), MaxRetries: 1})
+	<-started
+
+	resp, err := http.Get(server.URL + "/tasks/follow/logs?attempt=1&follow=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	var messages []string
+	for scanner.Scan() {
+		var line tasklog.Line
+		json.Unmarshal(scanner.Bytes(), &line)
+		messages = append(messages, line.Message)
+		if line.Message == "step 1" {
+			close(release)
+		}
+	}
+
+	if !contains(messages, "step 1") || !contains(messages, "step 2") || !contains(messages, "Task completed") {
+		t.Errorf("Expected streamed attempt logs, got %v", messages)
+	}
+
+	resp, _ = http.Get(server.URL + "/tasks/follow/logs?attempt=5")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown attempt, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func hasLine(lines []tasklog.Line, message string) bool {
+	for _, line := range lines {
+		if line.Message == message {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package unit
+
+import (
+	"TaskQueue/internal/auth"
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/service"
+	"TaskQueue/queue"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTenant_ParseLimits(t *testing.T) {
+	limits, err := queue.ParseTenantLimits("weight=3,concurrency=2,queued=100,rate=5.5,burst=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := queue.TenantLimits{Weight: 3, MaxConcurrency: 2, MaxQueued: 100, Rate: 5.5, Burst: 10}
+	if limits != expected {
+		t.Errorf("Expected %+v, got %+v", expected, limits)
+	}
+
+	for _, spec := range []string{"weight=0", "speed=1", "queued=-1", "rate"} {
+		if _, err := queue.ParseTenantLimits(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+// runOrder выполняет задачи одним воркером и возвращает арендаторов в порядке выполнения
+func runOrder(t *testing.T, tenants map[string]queue.TenantLimits, enqueue []string) []string {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 100, repo, queue.WithTenantLimits(queue.DefaultTenantLimits(), tenants))
+
+	var mu sync.Mutex
+	var order []string
+	done := make(chan struct{}, len(enqueue))
+	pool.RegisterHandler("record", func(ctx context.Context, task *model.Task) error {
+		mu.Lock()
+		order = append(order, task.Tenant)
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	})
+
+	for i, tenant := range enqueue {
+		task := &model.Task{ID: tenant + string(rune('a'+i)), Type: "record", Tenant: tenant, MaxRetries: 1, CreatedAt: time.Now()}
+		repo.Create(task)
+		if err := pool.Enqueue(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool.Start()
+	defer pool.Shutdown()
+
+	for range enqueue {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Tasks were not processed")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return order
+}
+
+func TestTenant_FairScheduling(t *testing.T) {
+	order := runOrder(t, nil, []string{"a", "a", "a", "a", "b", "b"})
+	if got := strings.Join(order, ""); got != "ababaa" {
+		t.Errorf("Expected round-robin between tenants, got %s", got)
+	}
+}
+
+func TestTenant_WeightedScheduling(t *testing.T) {
+	order := runOrder(t, map[string]queue.TenantLimits{"a": {Weight: 3}},
+		[]string{"a", "a", "a", "a", "a", "a", "b", "b"})
+	if got := strings.Join(order, ""); got != "aaabaaab" {
+		t.Errorf("Expected 3:1 weighted order, got %s", got)
+	}
+}
+
+func TestTenant_ConcurrencyLimit(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(3, 10, repo, queue.WithTenantLimits(queue.DefaultTenantLimits(),
+		map[string]queue.TenantLimits{"batch": {Weight: 1, MaxConcurrency: 1}}))
+
+	gate := make(chan struct{})
+	pool.RegisterHandler("gated", func(ctx context.Context, task *model.Task) error {
+		<-gate
+		return nil
+	})
+	pool.Start()
+	defer pool.Shutdown()
+
+	var tasks []*model.Task
+	for _, id := range []string{"batch-1", "batch-2", "batch-3", "web-1"} {
+		tenant, _, _ := strings.Cut(id, "-")
+		task := &model.Task{ID: id, Type: "gated", Tenant: tenant, MaxRetries: 1, CreatedAt: time.Now()}
+		repo.Create(task)
+		pool.Enqueue(task)
+		tasks = append(tasks, task)
+	}
+	waitForStatus(t, tasks[3], "running")
+	time.Sleep(50 * time.Millisecond)
+
+	running := 0
+	for _, task := range tasks[:3] {
+		if task.GetStatus() == "running" {
+			running++
+		}
+	}
+	if running != 1 {
+		t.Errorf("Expected 1 running batch task, got %d", running)
+	}
+
+	close(gate)
+	for _, task := range tasks {
+		waitForStatus(t, task, "done")
+	}
+}
+
+func TestTenant_ConcurrencyLimitSharedWithRemoteLeases(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 10, repo, queue.WithRemoteTypes("remote"),
+		queue.WithTenantLimits(queue.DefaultTenantLimits(),
+			map[string]queue.TenantLimits{"batch": {Weight: 1, MaxConcurrency: 1}}))
+
+	gate := make(chan struct{})
+	pool.RegisterHandler("gated", func(ctx context.Context, task *model.Task) error {
+		<-gate
+		return nil
+	})
+	pool.Start()
+	defer pool.Shutdown()
+
+	enqueue := func(id, taskType string) *model.Task {
+		task := &model.Task{ID: id, Type: taskType, Tenant: "batch", MaxRetries: 1, Status: "queued", CreatedAt: time.Now()}
+		repo.Create(task)
+		pool.Enqueue(task)
+		return task
+	}
+	lease := func() []queue.LeasedTask {
+		return pool.Lease(queue.LeaseRequest{WorkerID: "w1", Types: []string{"remote"}, Max: 2})
+	}
+
+	// Локальная попытка занимает единственный слот арендатора
+	local := enqueue("local", "gated")
+	waitForStatus(t, local, "running")
+	remote := enqueue("remote-1", "remote")
+	enqueue("remote-2", "remote")
+	if leased := lease(); len(leased) != 0 {
+		t.Fatalf("Expected no lease while local task holds the slot, got %+v", leased)
+	}
+
+	close(gate)
+	waitForStatus(t, local, "done")
+	leased := lease()
+	if len(leased) != 1 || leased[0].ID != "remote-1" {
+		t.Fatalf("Expected one lease after local task finished, got %+v", leased)
+	}
+
+	// Удаленная аренда держит слот, пока не завершена
+	next := enqueue("local-2", "gated")
+	time.Sleep(50 * time.Millisecond)
+	if status := next.GetStatus(); status != "queued" {
+		t.Errorf("Expected local task to wait for remote lease, got %s", status)
+	}
+	if err := pool.Complete(remote.ID, queue.LeaseHolder{WorkerID: "w1"}); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, next, "done")
+}
+
+func TestTenant_QueuedAndRateLimits(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(0, 10, repo, queue.WithTenantLimits(queue.DefaultTenantLimits(),
+		map[string]queue.TenantLimits{
+			"small": {Weight: 1, MaxQueued: 2},
+			"slow":  {Weight: 1, Rate: 1, Burst: 2},
+		}))
+
+	enqueue := func(id, tenant string) error {
+		task := &model.Task{ID: id, Type: "x", Tenant: tenant, MaxRetries: 1, CreatedAt: time.Now()}
+		repo.Create(task)
+		return pool.Enqueue(task)
+	}
+
+	var limitErr *queue.TenantLimitError
+	enqueue("s1", "small")
+	enqueue("s2", "small")
+	if err := enqueue("s3", "small"); !errors.As(err, &limitErr) || limitErr.Limit != "queued" {
+		t.Errorf("Expected queued limit error, got %v", err)
+	}
+	if err := enqueue("o1", "other"); err != nil {
+		t.Errorf("Expected other tenant to be unaffected, got %v", err)
+	}
+
+	enqueue("r1", "slow")
+	enqueue("r2", "slow")
+	err := enqueue("r3", "slow")
+	if !errors.As(err, &limitErr) || limitErr.Limit != "rate" || limitErr.RetryAfter != time.Second {
+		t.Errorf("Expected rate limit error with 1s retry, got %v", err)
+	}
+}
+
+func TestTenant_IdentityAndScopedListing(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	queueService := service.NewQueueService(repo, 0, 10)
+	ctl := controller.NewHTTPController(queueService)
+	store, err := auth.LoadAPIKeys("team-a:enqueue,read::a;ops:admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /enqueue", ctl.EnqueueHandler)
+	mux.HandleFunc("GET /tasks", ctl.ListTasksHandler)
+	handler := auth.Middleware(store, mux)
+
+	enqueue := func(key, id, tenantHeader string) {
+		body, _ := json.Marshal(map[string]interface{}{"id": id, "payload": "p", "max_retries": 1, "tenant": "spoofed"})
+		req := httptest.NewRequest("POST", "/enqueue", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		if tenantHeader != "" {
+			req.Header.Set(controller.TenantHeader, tenantHeader)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected 202, got %d", w.Code)
+		}
+	}
+	// Арендатор ключа важнее заголовка
+	enqueue("team-a", "from-a", "b")
+	enqueue("ops", "from-ops", "b")
+	enqueue("ops", "default-tenant", "")
+
+	for id, tenant := range map[string]string{"from-a": "a", "from-ops": "b", "default-tenant": model.DefaultTenant} {
+		if task, _ := queueService.GetTask(id); task.Tenant != tenant {
+			t.Errorf("Expected %s to belong to tenant %s, got %s", id, tenant, task.Tenant)
+		}
+	}
+
+	list := func(key string) []model.TaskSnapshot {
+		req := httptest.NewRequest("GET", "/tasks", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		var result struct {
+			Tasks []model.TaskSnapshot `json:"tasks"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return result.Tasks
+	}
+	if tasks := list("team-a"); len(tasks) != 1 || tasks[0].ID != "from-a" {
+		t.Errorf("Expected tenant key to see only its tasks, got %+v", tasks)
+	}
+	if tasks := list("ops"); len(tasks) != 3 {
+		t.Errorf("Expected key without tenant to see all tasks, got %d", len(tasks))
+	}
+}
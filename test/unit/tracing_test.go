@@ -0,0 +1,87 @@
+package unit
+
+import (
+	"TaskQueue/internal/controller"
+	"TaskQueue/internal/model"
+	"TaskQueue/internal/repository"
+	"TaskQueue/internal/service"
+	"TaskQueue/internal/tracing"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing_EnqueueToAttempt(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), tracing.Config{Exporter: "none"}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	repo := repository.NewInMemoryTaskRepository()
+	queueService := service.NewQueueService(repo, 1, 5)
+
+	handlerSpans := make(chan trace.SpanContext, 1)
+	queueService.RegisterHandler("traced", func(ctx context.Context, task *model.Task) error {
+		handlerSpans <- trace.SpanContextFromContext(ctx)
+		return errors.New("boom")
+	})
+	queueService.StartWorkers()
+	defer queueService.Shutdown()
+
+	httpController := controller.NewHTTPController(queueService)
+	handler := tracing.Middleware(http.HandlerFunc(httpController.EnqueueHandler))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	body, _ := json.Marshal(map[string]interface{}{
+		"id": "traced-task", "type": "traced", "payload": "data", "max_retries": 3,
+	})
+	req := httptest.NewRequest("POST", "/enqueue", bytes.NewReader(body))
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", w.Code)
+	}
+
+	task, _ := repo.GetByID("traced-task")
+	if task.TraceContext["traceparent"] == "" {
+		t.Error("Expected trace context to be stored on the task")
+	}
+
+	select {
+	case sc := <-handlerSpans:
+		if sc.TraceID().String() != traceID {
+			t.Errorf("Expected handler context in trace %s, got %s", traceID, sc.TraceID())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Handler was not called")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	names := make(map[string]bool)
+	for _, span := range recorder.Started() {
+		if span.SpanContext().TraceID().String() != traceID {
+			t.Errorf("Span %s is not in trace %s", span.Name(), traceID)
+		}
+		names[span.Name()] = true
+	}
+	for _, name := range []string{"enqueue", "queue.wait", "task.attempt", "task.retry_backoff"} {
+		if !names[name] {
+			t.Errorf("Expected span %s, got %v", name, names)
+		}
+	}
+}
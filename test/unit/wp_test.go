@@ -4,7 +4,12 @@ import (
 	"TaskQueue/internal/model"
 	"TaskQueue/internal/repository"
 	"TaskQueue/queue"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestWorkerPool_Enqueue(t *testing.T) {
@@ -13,7 +18,7 @@ func TestWorkerPool_Enqueue(t *testing.T) {
 
 	task := &model.Task{
 		ID:         "test1",
-		Payload:    "test",
+		Payload:    json.RawMessage(`"test"`),
 		MaxRetries: 3,
 	}
 
@@ -30,7 +35,7 @@ func TestWorkerPool_QueueFull(t *testing.T) {
 	// Fill the queue
 	task1 := &model.Task{
 		ID:         "test1",
-		Payload:    "test",
+		Payload:    json.RawMessage(`"test"`),
 		MaxRetries: 3,
 	}
 	pool.Enqueue(task1)
@@ -38,7 +43,7 @@ func TestWorkerPool_QueueFull(t *testing.T) {
 	// Try to add another task (should fail)
 	task2 := &model.Task{
 		ID:         "test2",
-		Payload:    "test",
+		Payload:    json.RawMessage(`"test"`),
 		MaxRetries: 3,
 	}
 	err := pool.Enqueue(task2)
@@ -47,3 +52,78 @@ func TestWorkerPool_QueueFull(t *testing.T) {
 		t.Error("Expected error when queue is full")
 	}
 }
+
+func TestWorkerPool_QueueDepth(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 10, repo, queue.WithRemoteTypes("remote"))
+	pool.Start()
+	defer pool.Shutdown()
+
+	var tasks []*model.Task
+	for i, tenant := range []string{"a", "a", "b"} {
+		task := &model.Task{ID: string(rune('x' + i)), Type: "remote", Queue: "emails", Tenant: tenant, MaxRetries: 1}
+		repo.Create(task)
+		if err := pool.Enqueue(task); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+		tasks = append(tasks, task)
+	}
+	onlyA := func(q, tenant string) bool { return tenant == "a" }
+	if depth := pool.QueueDepth("emails", nil); depth != 3 {
+		t.Errorf("Expected depth 3, got %d", depth)
+	}
+	if depth := pool.QueueDepth("emails", onlyA); depth != 2 {
+		t.Errorf("Expected depth 2 for tenant a, got %d", depth)
+	}
+
+	pool.Lease(queue.LeaseRequest{WorkerID: "w1", Types: []string{"remote"}, Tenant: "b"})
+	pool.Cancel(tasks[0])
+	if depth := pool.QueueDepth("emails", nil); depth != 1 {
+		t.Errorf("Expected depth 1 after lease and cancel, got %d", depth)
+	}
+	if depth := pool.QueueDepth("other", nil); depth != 0 {
+		t.Errorf("Expected empty depth for unknown queue, got %d", depth)
+	}
+}
+
+func TestWorkerPool_ReplayDuringBackoffRunsOnce(t *testing.T) {
+	repo := repository.NewInMemoryTaskRepository()
+	pool := queue.NewWorkerPool(1, 10, repo,
+		queue.WithRetryPolicies(model.RetryPolicy{Strategy: model.RetryFixed, BaseDelay: model.Duration(200 * time.Millisecond)}, nil),
+	)
+	var attempts atomic.Int32
+	pool.RegisterHandler("flaky", func(ctx context.Context, task *model.Task) error {
+		if attempts.Add(1) == 1 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+	pool.Start()
+	defer pool.Shutdown()
+
+	task := &model.Task{ID: "backoff", Type: "flaky", Queue: model.DefaultQueue, MaxRetries: 3,
+		Status: "queued", CreatedAt: time.Now()}
+	repo.Create(task)
+	pool.Enqueue(task)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for task.GetRetryAt().IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected task to wait for retry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := pool.Cancel(task); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if err := pool.Replay(task); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	waitForStatus(t, task, "done")
+
+	// Таймер бэкоффа отмененной попытки не должен вернуть задачу второй раз
+	time.Sleep(300 * time.Millisecond)
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("Expected 2 attempts (failure and replay), got %d", got)
+	}
+}